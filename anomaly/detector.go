@@ -0,0 +1,284 @@
+package anomaly
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/strahe/suialert/config"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/rule"
+	"github.com/strahe/suialert/service"
+	"github.com/strahe/suialert/types"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	MetricOutflow        = "outflow"
+	MetricTransactions   = "transactions"
+	MetricCounterparties = "counterparties"
+
+	day = 24 * time.Hour
+	// lower standard deviations are rounded up, in units of the metrics
+	minStdDev = 1.0
+	// an anomaly of an address is not raised again before
+	cooldown = day
+)
+
+// seriesKey identifies a metric, the outflow is measured per coin type.
+type seriesKey struct {
+	metric   string
+	coinType string
+}
+
+type alertKey struct {
+	address types.Address
+	seriesKey
+}
+
+// Detector learns the daily activity of the watched addresses from the stored events,
+// and raises an alert when the last 24 hours are unusual compared to the previous days.
+// The alerts are sent to every user with a rule on the address.
+type Detector struct {
+	cfg      config.AnomalyConfig
+	db       *gorm.DB
+	rsv      *service.RuleService
+	notifier rule.Notifier
+
+	alerted map[alertKey]time.Time
+	done    chan struct{}
+}
+
+func NewDetector(cfg config.AnomalyConfig, db *gorm.DB, rsv *service.RuleService, notifier rule.Notifier) *Detector {
+	if notifier == nil {
+		notifier = rule.LogNotifier{}
+	}
+	if cfg.WindowDays < cfg.WarmupDays {
+		cfg.WindowDays = cfg.WarmupDays
+	}
+	return &Detector{
+		cfg:      cfg,
+		db:       db,
+		rsv:      rsv,
+		notifier: notifier,
+		alerted:  map[alertKey]time.Time{},
+		done:     make(chan struct{}),
+	}
+}
+
+func (d *Detector) Start(context.Context) error {
+	if !d.cfg.Enable {
+		return nil
+	}
+	go d.loop()
+	return nil
+}
+
+func (d *Detector) Close(context.Context) error {
+	close(d.done)
+	return nil
+}
+
+func (d *Detector) loop() {
+	ticker := time.NewTicker(d.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+			if err := d.Check(context.Background()); err != nil {
+				zap.S().Errorf("failed to check anomalies: %s", err)
+			}
+		}
+	}
+}
+
+// Check compares the activity of all the addresses watched by an active rule with their baseline.
+func (d *Detector) Check(ctx context.Context) error {
+	rules, err := d.rsv.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	watchers := map[types.Address][]uint{}
+	for _, r := range rules {
+		if !r.Active(now) {
+			continue
+		}
+		watchers[r.Address] = append(watchers[r.Address], r.UserID)
+	}
+	for addr, users := range watchers {
+		anomalies, err := d.checkAddress(ctx, addr, now)
+		if err != nil {
+			return err
+		}
+		for _, an := range anomalies {
+			key := alertKey{address: addr, seriesKey: seriesKey{metric: an.Metric, coinType: an.CoinType}}
+			if last, ok := d.alerted[key]; ok && now.Sub(last) < cooldown {
+				continue
+			}
+			d.alerted[key] = now
+			for _, uid := range lo.Uniq(users) {
+				alert := &model.Alert{
+					UserID:    uid,
+					Event:     types.EventTypeAnomaly,
+					Address:   addr,
+					Severity:  model.SeverityWarning,
+					Timestamp: uint64(now.UnixMilli()),
+					Data:      an,
+					CreatedAt: now,
+				}
+				err := d.notifier.Notify(ctx, alert)
+				if rule.IsFailure(err) {
+					zap.S().Errorf("failed to notify anomaly of %s to user %d: %s", addr, uid, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Detector) checkAddress(ctx context.Context, addr types.Address, now time.Time) ([]*types.Anomaly, error) {
+	series, first, err := d.dailySeries(ctx, addr, now)
+	if err != nil {
+		return nil, err
+	}
+	if !d.warm(first, now) {
+		return nil, nil
+	}
+	var anomalies []*types.Anomaly
+	for key, values := range series {
+		if an := d.unusual(values); an != nil {
+			an.Metric = key.metric
+			an.CoinType = key.coinType
+			anomalies = append(anomalies, an)
+		}
+	}
+	return anomalies, nil
+}
+
+// warm reports whether the history of an address, starting at its oldest event, is long enough to raise alerts.
+func (d *Detector) warm(first, now time.Time) bool {
+	return !first.IsZero() && now.Sub(first) >= time.Duration(d.cfg.WarmupDays)*day
+}
+
+// unusual returns the anomaly of the value of the last 24 hours, followed by the values of the previous days,
+// if its z-score reaches the threshold. Only increases are unusual.
+func (d *Detector) unusual(values []float64) *types.Anomaly {
+	current, history := values[0], values[1:]
+	mean, std := meanStdDev(history)
+	if current <= mean {
+		return nil
+	}
+	// a flat history would make any change unusual
+	z := (current - mean) / math.Max(std, minStdDev)
+	if z < d.cfg.Threshold {
+		return nil
+	}
+	return &types.Anomaly{
+		Value:  current,
+		Mean:   mean,
+		StdDev: std,
+		ZScore: z,
+		Window: d.cfg.WindowDays,
+	}
+}
+
+// dailySeries returns, for each metric, the value of the last 24 hours followed by
+// the values of the previous days of the window, and the time of the oldest event.
+func (d *Detector) dailySeries(ctx context.Context, addr types.Address, now time.Time) (map[seriesKey][]float64, time.Time, error) {
+	days := d.cfg.WindowDays + 1
+	since := uint64(now.Add(-time.Duration(days) * day).UnixMilli())
+
+	var changes []model.CoinBalanceChangeEvent
+	if err := d.db.WithContext(ctx).Where("sender = ? AND timestamp >= ?", addr, since).
+		Find(&changes).Error; err != nil {
+		return nil, time.Time{}, err
+	}
+	var transfers []model.TransferObjectEvent
+	if err := d.db.WithContext(ctx).Where("sender = ? AND timestamp >= ?", addr, since).
+		Find(&transfers).Error; err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var (
+		first          time.Time
+		outflow        = map[string][]float64{}
+		txs            = make([]map[string]bool, days)
+		counterparties = make([]map[string]bool, days)
+	)
+	for i := 0; i < days; i++ {
+		txs[i] = map[string]bool{}
+		counterparties[i] = map[string]bool{}
+	}
+	bucket := func(ts uint64) int {
+		t := time.UnixMilli(int64(ts))
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+		i := int(now.Sub(t) / day)
+		if i < 0 {
+			i = 0
+		}
+		if i >= days {
+			i = days - 1
+		}
+		return i
+	}
+
+	for _, c := range changes {
+		i := bucket(c.Timestamp)
+		txs[i][c.TransactionDigest] = true
+		if c.Amount != nil && (*big.Int)(c.Amount).Sign() < 0 {
+			ev := types.CoinBalanceChange{CoinType: c.CoinType, Amount: types.NewBigInt((*big.Int)(c.Amount))}
+			v, _ := ev.Value().Float64()
+			if outflow[c.CoinType] == nil {
+				outflow[c.CoinType] = make([]float64, days)
+			}
+			outflow[c.CoinType][i] -= v
+		}
+	}
+	self := types.OwnerToString(&types.ObjectOwner{ObjectOwnerInternal: &types.ObjectOwnerInternal{AddressOwner: &addr}})
+	for _, t := range transfers {
+		i := bucket(t.Timestamp)
+		txs[i][t.TransactionDigest] = true
+		if to := types.OwnerToString(&t.Recipient); to != "" && to != self {
+			counterparties[i][to] = true
+		}
+	}
+
+	transactions, peers := make([]float64, days), make([]float64, days)
+	for i := 0; i < days; i++ {
+		transactions[i] = float64(len(txs[i]))
+		peers[i] = float64(len(counterparties[i]))
+	}
+	series := map[seriesKey][]float64{
+		{metric: MetricTransactions}:   transactions,
+		{metric: MetricCounterparties}: peers,
+	}
+	for coinType, values := range outflow {
+		series[seriesKey{metric: MetricOutflow, coinType: coinType}] = values
+	}
+	return series, first, nil
+}
+
+func meanStdDev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(values)))
+}
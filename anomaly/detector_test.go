@@ -0,0 +1,162 @@
+package anomaly
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pgcontrib/bigint"
+	"github.com/strahe/suialert/config"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
+	"github.com/strahe/suialert/types"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func testConfig() config.AnomalyConfig {
+	return config.AnomalyConfig{
+		Enable:     true,
+		WindowDays: 7,
+		WarmupDays: 7,
+		Threshold:  3,
+		Interval:   time.Hour,
+	}
+}
+
+func TestUnusual(t *testing.T) {
+	d := NewDetector(testConfig(), nil, nil, nil)
+	for _, tc := range []struct {
+		name    string
+		values  []float64
+		unusual bool
+		z       float64
+	}{
+		{"flat history, small change", []float64{2, 0, 0, 0, 0}, false, 0},
+		// the standard deviation of a flat history is rounded up to minStdDev
+		{"flat history, at the threshold", []float64{3, 0, 0, 0, 0}, true, 3},
+		{"below the threshold", []float64{15, 8, 12, 8, 12}, false, 0},
+		{"at the threshold", []float64{16, 8, 12, 8, 12}, true, 3},
+		{"above the threshold", []float64{30, 8, 12, 8, 12}, true, 10},
+		{"decrease", []float64{0, 20, 30, 20, 30}, false, 0},
+		{"average", []float64{10, 8, 12, 8, 12}, false, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			an := d.unusual(tc.values)
+			if (an != nil) != tc.unusual {
+				t.Fatalf("unusual %+v, want %t", an, tc.unusual)
+			}
+			if an != nil && an.ZScore != tc.z {
+				t.Errorf("z-score %g, want %g", an.ZScore, tc.z)
+			}
+		})
+	}
+}
+
+func TestWarm(t *testing.T) {
+	d := NewDetector(testConfig(), nil, nil, nil)
+	now := time.Now()
+	for _, tc := range []struct {
+		name  string
+		first time.Time
+		warm  bool
+	}{
+		{"no history", time.Time{}, false},
+		{"one day", now.Add(-day), false},
+		{"almost the warmup", now.Add(-7*day + time.Minute), false},
+		{"the warmup", now.Add(-7 * day), true},
+		{"longer", now.Add(-30 * day), true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if warm := d.warm(tc.first, now); warm != tc.warm {
+				t.Errorf("warm %t, want %t", warm, tc.warm)
+			}
+		})
+	}
+}
+
+type recorder struct {
+	alerts []*model.Alert
+}
+
+func (r *recorder) Notify(_ context.Context, alert *model.Alert) error {
+	r.alerts = append(r.alerts, alert)
+	return nil
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	name := fmt.Sprintf("file:%s-%d?mode=memory&cache=shared", t.Name(), time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(name), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := model.Migration(db); err != nil {
+		t.Fatal(err)
+	}
+
+	const coin = "0x5::usdc::USDC"
+	var (
+		now     = time.Now()
+		expired = now.Add(-time.Hour)
+		watched = types.HexToAddress("0x1")
+		paused  = types.HexToAddress("0x2")
+		stale   = types.HexToAddress("0x3")
+		seq     int64
+	)
+	if err := db.Create(&model.User{ID: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	rules := []model.Rule{
+		{ID: 1, UserID: 1, Address: watched, Event: types.EventTypeCoinBalanceChange, Condition: "true"},
+		{ID: 2, UserID: 1, Address: paused, Event: types.EventTypeCoinBalanceChange, Condition: "true", Paused: true},
+		{ID: 3, UserID: 1, Address: stale, Event: types.EventTypeCoinBalanceChange, Condition: "true", ExpiresAt: &expired},
+	}
+	if err := db.Create(&rules).Error; err != nil {
+		t.Fatal(err)
+	}
+	spend := func(addr types.Address, coinType string, amount int64, ago time.Duration) {
+		seq++
+		err := db.Create(&model.CoinBalanceChangeEvent{
+			TransactionDigest: fmt.Sprintf("tx%d", seq),
+			Timestamp:         uint64(now.Add(-ago).UnixMilli()),
+			Sender:            addr,
+			CoinType:          coinType,
+			Amount:            bigint.FromInt64(-amount),
+		}).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the same daily outflow of SUI for a week, the first outflow of another coin today
+	for _, addr := range []types.Address{watched, paused, stale} {
+		for i := 0; i < 8; i++ {
+			spend(addr, types.SuiCoinType, 1e9, time.Duration(i)*day+time.Hour)
+		}
+		spend(addr, coin, 1000, time.Hour)
+	}
+
+	rec := &recorder{}
+	d := NewDetector(testConfig(), db, service.NewRuleService(db), rec)
+	if err := d.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// the outflows of the coins are apart, the addresses of inactive rules are not checked
+	if len(rec.alerts) != 1 {
+		t.Fatalf("%d alerts, want 1", len(rec.alerts))
+	}
+	an := rec.alerts[0].Data.(*types.Anomaly)
+	if rec.alerts[0].Address != watched || an.Metric != MetricOutflow || an.CoinType != coin || an.Value != 1000 {
+		t.Errorf("unexpected anomaly %+v of %s", an, rec.alerts[0].Address.Hex())
+	}
+
+	// an anomaly is not raised again before the cooldown
+	if err := d.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.alerts) != 1 {
+		t.Errorf("%d alerts after the second check, want 1", len(rec.alerts))
+	}
+}
@@ -27,7 +27,7 @@ func GetChangeType() string {
 //	CoinType          string       `json:"coinType"`
 //	CoinObjectId      string       `json:"coinObjectId"`
 //	Version           int64        `json:"version"`
-//	Amount            int64        `json:"amount"`
+//	Amount            *BigInt      `json:"amount"`
 //}
 
 // MakeRule make a single dummy rule
@@ -52,9 +52,11 @@ func MakeRule(name string, seq int) string {
 	buff.WriteString(lo.Sample[string]([]string{"Pay", "Gas", "Receive"}))
 	buff.WriteString("\"")
 	buff.WriteString(" && \n\t\t")
-	buff.WriteString("Event.Amount")
-	buff.WriteString(lo.Sample[string]([]string{" > ", " >= ", " == ", " < ", " <= "}))
+	buff.WriteString("Event.CompareAmount(\"")
 	buff.WriteString(strconv.Itoa(rand.Intn(100000000)))
+	buff.WriteString("\")")
+	buff.WriteString(lo.Sample[string]([]string{" > ", " >= ", " == ", " < ", " <= "}))
+	buff.WriteString("0")
 	buff.WriteString("\n\tthen\n\t\t")
 	buff.WriteString("Event.Matched(")
 	buff.WriteString(strconv.Itoa(rand.Intn(10000)))
@@ -79,3 +81,19 @@ func GenRandomRule(fileName string, count int) error {
 	}
 	return nil
 }
+
+// MakeCondition make a single dummy rule condition
+func MakeCondition() string {
+	buff := &bytes.Buffer{}
+	buff.WriteString("Event.ChangeType")
+	buff.WriteString(" == \"")
+	buff.WriteString(lo.Sample[string]([]string{"Pay", "Gas", "Receive"}))
+	buff.WriteString("\"")
+	buff.WriteString(" && ")
+	buff.WriteString("Event.CompareAmount(\"")
+	buff.WriteString(strconv.Itoa(rand.Intn(100000000)))
+	buff.WriteString("\")")
+	buff.WriteString(lo.Sample[string]([]string{" > ", " >= ", " == ", " < ", " <= "}))
+	buff.WriteString("0")
+	return buff.String()
+}
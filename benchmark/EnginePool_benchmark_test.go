@@ -0,0 +1,149 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/hyperjumptech/grule-rule-engine/ast"
+	"github.com/hyperjumptech/grule-rule-engine/builder"
+	"github.com/hyperjumptech/grule-rule-engine/engine"
+	"github.com/hyperjumptech/grule-rule-engine/pkg"
+	"github.com/samber/lo"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/rule"
+	"github.com/strahe/suialert/service"
+	"github.com/strahe/suialert/types"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+/**
+  Benchmarking the knowledge base instances cached by `rule.Engine` against
+  building a new instance for every event, as the engine used to do.
+*/
+
+var (
+	benchOwner = types.HexToAddress("0xfD9A15b8a0d7EBAB0AdB0c69F1c03b05cc2a5a32")
+	benchDBs   int
+)
+
+func Benchmark_Engine_KnowledgeBase_Instances(b *testing.B) {
+	for _, count := range []int{10, 100, 1000} {
+		rules := makeRules(count)
+
+		b.Run(fmt.Sprintf("%d rules/new instance per event", count), func(b *testing.B) {
+			lib := ast.NewKnowledgeLibrary()
+			rb := builder.NewRuleBuilder(lib)
+			for _, r := range rules {
+				grl, err := r.BuildGRL()
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := rb.BuildRuleFromResource("bench", "", pkg.NewBytesResource(grl)); err != nil {
+					b.Fatal(err)
+				}
+			}
+			eg := engine.NewGruleEngine()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				dataCtx := ast.NewDataContext()
+				if err := dataCtx.Add("Event", makeEvent()); err != nil {
+					b.Fatal(err)
+				}
+				kb := lib.NewKnowledgeBaseInstance("bench", "")
+				if _, err := eg.FetchMatchingRules(dataCtx, kb); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("%d rules/engine", count), func(b *testing.B) {
+			eng := newBenchEngine(b, rules)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := eng.ExecuteCoinBalanceChange(context.Background(), &types.EventResult{}, makeEvent()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("%d rules/engine parallel", count), func(b *testing.B) {
+			eng := newBenchEngine(b, rules)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := eng.ExecuteCoinBalanceChange(context.Background(), &types.EventResult{}, makeEvent()); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
+
+type discardNotifier struct{}
+
+func (discardNotifier) Notify(context.Context, *model.Alert) error {
+	return nil
+}
+
+func makeRules(count int) []model.Rule {
+	rules := make([]model.Rule, 0, count)
+	for i := 1; i <= count; i++ {
+		rules = append(rules, model.Rule{
+			ID:        uint(i),
+			Address:   benchOwner,
+			Event:     types.EventTypeCoinBalanceChange,
+			UserID:    uint(i),
+			Condition: MakeCondition(),
+			Salience:  rand.Intn(100) + 10,
+			Actions:   []model.Action{{Type: model.ActionNotify}},
+		})
+	}
+	return rules
+}
+
+func newBenchEngine(b *testing.B, rules []model.Rule) *rule.Engine {
+	benchDBs++
+	dsn := fmt.Sprintf("file:bench%d?mode=memory&cache=shared", benchDBs)
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := model.Migration(db); err != nil {
+		b.Fatal(err)
+	}
+	// skip the hooks, the users of the rules don't exist
+	if err := db.Session(&gorm.Session{SkipHooks: true}).CreateInBatches(rules, 100).Error; err != nil {
+		b.Fatal(err)
+	}
+	eng, err := rule.NewEngine(service.NewRuleService(db), nil, discardNotifier{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := eng.LoadRules(context.Background()); err != nil {
+		b.Fatal(err)
+	}
+	return eng
+}
+
+func makeEvent() *types.CoinBalanceChange {
+	return &types.CoinBalanceChange{
+		PackageId:         "0x0000000000000000000000000000000000000002",
+		TransactionModule: "transfer_object",
+		Sender:            "0x7bcb60878fb8e28d4412324842351e7261e072ec",
+		ChangeType:        lo.Sample[string]([]string{"Pay", "Gas", "Receive"}),
+		Owner: &types.ObjectOwner{
+			ObjectOwnerInternal: &types.ObjectOwnerInternal{
+				AddressOwner: &benchOwner,
+			},
+		},
+		CoinType:     "0x2::sui::SUI",
+		CoinObjectId: "0x7cf75ee1856a0ef9e6f262209420e6ea088d0edb",
+		Version:      rand.Int63n(1000000),
+		Amount:       types.BigIntFromInt64(rand.Int63n(10000000000)),
+	}
+}
@@ -62,7 +62,7 @@ func Benchmark_Grule_Execution_Engine(b *testing.B) {
 						CoinType:     "0x2::sui::SUI",
 						CoinObjectId: "0x7cf75ee1856a0ef9e6f262209420e6ea088d0edb",
 						Version:      rand.Int63n(1000000),
-						Amount:       rand.Int63n(10000000000),
+						Amount:       types.BigIntFromInt64(rand.Int63n(10000000000)),
 					}
 					e := engine.NewGruleEngine()
 					//Fact1
@@ -0,0 +1,235 @@
+package bots
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/types"
+)
+
+// Content is the layout of an alert, the notifiers render it in the format of their platform
+// so that an alert reads the same everywhere.
+type Content struct {
+	// Escalated is a note about the escalation of the alert, empty if not escalated
+	Escalated string
+	// Title is the severity and the event, e.g. "[WARNING] CoinBalanceChange"
+	Title string
+	// Emoji of the severity and the event
+	Emoji  string
+	Fields []Field
+	// Table is the result of a query rule, nil for the other alerts
+	Table *types.QueryResult
+	// Footer tells how to acknowledge the alert, empty if it needs no acknowledgement
+	Footer string
+	// TxURL is the page of the transaction in the explorer, empty if unknown
+	TxURL string
+	// Time of the event, or of the alert if unknown
+	Time time.Time
+}
+
+// Field is a named value of an alert, Code values are identifiers shown in a monospace font.
+// Link is the page of the value in the explorer, if any.
+type Field struct {
+	Name  string
+	Value string
+	Code  bool
+	Link  string
+}
+
+// maxDigestFields is the number of sources and of coins listed in a digest
+const maxDigestFields = 8
+
+// NewContent returns the layout of an alert, with links to the explorer.
+func NewContent(alert *model.Alert, explorer Explorer) *Content {
+	if d, ok := alert.Data.(*types.Summary); ok {
+		return newDigestContent(alert, d, explorer)
+	}
+	c := &Content{
+		Title: fmt.Sprintf("[%s] %s", strings.ToUpper(string(alert.Severity)), alert.Event),
+		Emoji: alert.Severity.Emoji() + " " + alert.Event.Emoji(),
+		TxURL: explorer.TxURL(alert.TxDigest),
+		Time:  alert.CreatedAt,
+	}
+	if alert.Timestamp != 0 {
+		c.Time = time.UnixMilli(int64(alert.Timestamp))
+	}
+	if alert.Escalated {
+		c.Escalated = fmt.Sprintf("Escalated, not acknowledged within %s", alert.Escalation.After)
+	}
+	if alert.Address != (types.Address{}) {
+		c.Fields = append(c.Fields, Field{Name: "Address", Value: alert.Address.Hex(), Code: true,
+			Link: explorer.AddressURL(alert.Address.Hex())})
+	}
+	c.Fields = append(c.Fields, Field{Name: "Source", Value: alert.Source()})
+	switch d := alert.Data.(type) {
+	case *types.CoinBalanceChange:
+		c.Fields = append(c.Fields, Field{Name: "Amount", Value: d.FormatAmount()})
+	case *types.Anomaly:
+		c.Fields = append(c.Fields, Field{Name: "Unusual " + d.Subject(),
+			Value: fmt.Sprintf("%.2f in the last 24h, %.2f on average over %d days (z-score %.1f)", d.Value, d.Mean, d.Window, d.ZScore)})
+	case *types.Inactivity:
+		last := "never"
+		if d.LastSeen != nil {
+			last = d.LastSeen.UTC().Format("2006-01-02 15:04 MST")
+		}
+		c.Fields = append(c.Fields, Field{Name: "No " + strings.ReplaceAll(d.Check, "_", " "),
+			Value: fmt.Sprintf("within %s, last seen %s", d.Period, last)})
+	case *types.QueryResult:
+		c.Fields = append(c.Fields, Field{Name: d.Name, Value: fmt.Sprintf("%g %s %g", d.Value, d.Op, d.Threshold)})
+		if len(d.Rows) > 1 {
+			c.Table = d
+		}
+	}
+	sender, recipient := parties(alert.Data)
+	if sender != "" {
+		c.Fields = append(c.Fields, Field{Name: "Sender", Value: sender, Code: true, Link: explorer.AddressURL(sender)})
+	}
+	if recipient != "" {
+		f := Field{Name: "Recipient", Value: recipient, Code: true}
+		if types.IsHexAddress(recipient) {
+			f.Link = explorer.AddressURL(recipient)
+		}
+		c.Fields = append(c.Fields, f)
+	}
+	if alert.TxDigest != "" {
+		c.Fields = append(c.Fields, Field{Name: "Transaction", Value: alert.TxDigest, Code: true, Link: c.TxURL})
+	}
+	if alert.AckID != 0 && !alert.Escalated {
+		c.Footer = fmt.Sprintf("Acknowledge with /ack %d within %s, or it is escalated", alert.AckID, alert.Escalation.After)
+	} else if alert.AckID != 0 {
+		c.Footer = fmt.Sprintf("Acknowledge with /ack %d", alert.AckID)
+	}
+	return c
+}
+
+// newDigestContent returns the layout of a digest: the alerts of each source, the net amount of each coin
+// and the transactions with the most alerts.
+func newDigestContent(alert *model.Alert, d *types.Summary, explorer Explorer) *Content {
+	c := &Content{
+		Title: fmt.Sprintf("[%s] %s of %s", strings.ToUpper(string(alert.Severity)), alert.Event, plural(d.Count, "alert")),
+		Emoji: alert.Severity.Emoji() + " " + alert.Event.Emoji(),
+		Time:  d.Until,
+		Fields: []Field{{Name: "Period", Value: fmt.Sprintf("%s, %s to %s", d.Mode,
+			d.Since.Format("2006-01-02 15:04"), d.Until.Format("2006-01-02 15:04 MST"))}},
+	}
+	for i, s := range d.Sources {
+		if i == maxDigestFields {
+			c.Fields = append(c.Fields, Field{Name: "Other sources", Value: fmt.Sprintf("%d more", len(d.Sources)-i)})
+			break
+		}
+		c.Fields = append(c.Fields, Field{Name: s.Source, Value: plural(s.Count, "alert")})
+	}
+	for i := range d.Coins {
+		if i == maxDigestFields {
+			c.Fields = append(c.Fields, Field{Name: "Other coins", Value: fmt.Sprintf("%d more", len(d.Coins)-i)})
+			break
+		}
+		coin := &d.Coins[i]
+		c.Fields = append(c.Fields, Field{Name: "Net " + types.LookupCoin(coin.CoinType).Symbol,
+			Value: fmt.Sprintf("%s in %s", coin.FormatAmount(), plural(coin.Count, "change"))})
+	}
+	for _, tx := range d.Top {
+		c.Fields = append(c.Fields, Field{Name: "Transaction, " + plural(tx.Count, "alert"), Value: tx.TxDigest,
+			Code: true, Link: explorer.TxURL(tx.TxDigest)})
+	}
+	return c
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// parties returns the sender and the recipient of the data of an event, empty if it has none.
+func parties(data interface{}) (sender, recipient string) {
+	switch d := data.(type) {
+	case *types.MoveEvent:
+		return d.Sender, ""
+	case *types.Publish:
+		return d.Sender, ""
+	case *types.CoinBalanceChange:
+		return d.Sender, ""
+	case *types.TransferObject:
+		return d.Sender, ownerString(d.Recipient)
+	case *types.NewObject:
+		return d.Sender, ownerString(d.Recipient)
+	case *types.MutateObject:
+		return d.Sender, ""
+	case *types.DeleteObject:
+		return d.Sender, ""
+	}
+	return "", ""
+}
+
+// ownerString returns the address of an owner, or the kind of ownership, e.g. "Immutable" or "Shared".
+func ownerString(o *types.ObjectOwner) string {
+	if o == nil {
+		return ""
+	}
+	if in := o.ObjectOwnerInternal; in != nil {
+		for _, a := range []*types.Address{in.AddressOwner, in.ObjectOwner, in.SingleOwner} {
+			if a != nil {
+				return a.Hex()
+			}
+		}
+		if in.Shared != nil {
+			return "Shared"
+		}
+	}
+	return strings.Trim(types.OwnerToString(o), `"`)
+}
+
+// Rows formats the first n rows of the table, with a header of the column names.
+func (c *Content) Rows(n int) string {
+	if c.Table == nil {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(strings.Join(c.Table.Columns, " | "))
+	sb.WriteString("\n")
+	for i, row := range c.Table.Rows {
+		if i == n {
+			sb.WriteString(fmt.Sprintf("... %d more\n", len(c.Table.Rows)-n))
+			break
+		}
+		cells := make([]string, len(row))
+		for j, v := range row {
+			cells[j] = fmt.Sprint(v)
+		}
+		sb.WriteString(strings.Join(cells, " | "))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// PostJSON posts v as json to an url, any status but 2xx is an error.
+func PostJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
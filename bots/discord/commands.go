@@ -2,11 +2,200 @@ package discord
 
 import (
 	"github.com/bwmarrin/discordgo"
+	"github.com/strahe/suialert/model"
 )
 
-var commands = []discordgo.ApplicationCommand{
-	{
-		Name:        "add-alert",
-		Description: "Add a new address to the alert list",
-	},
+var (
+	minDays = float64(1)
+	minHour = float64(0)
+
+	commands = []discordgo.ApplicationCommand{
+		{
+			Name:        "add-alert",
+			Description: "Add a new address to the alert list",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "severity",
+					Description: "Severity of the alerts, info by default",
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "info", Value: "info"},
+						{Name: "warning", Value: "warning"},
+						{Name: "critical", Value: "critical"},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionChannel,
+					Name:        "channel",
+					Description: "Channel or thread of this server to post the alerts in, instead of your direct messages",
+					ChannelTypes: []discordgo.ChannelType{
+						discordgo.ChannelTypeGuildText,
+						discordgo.ChannelTypeGuildNews,
+						discordgo.ChannelTypeGuildNewsThread,
+						discordgo.ChannelTypeGuildPublicThread,
+						discordgo.ChannelTypeGuildPrivateThread,
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionRole,
+					Name:        "role",
+					Description: "Role of the members who can manage the alert of a channel with you",
+				},
+			},
+		},
+		{
+			Name:        "alert-stats",
+			Description: "Show how often your alerts matched",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "rule",
+					Description: "ID of the alert, all of them if not set",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "days",
+					Description: "Number of days, 7 by default",
+					MinValue:    &minDays,
+					MaxValue:    90,
+				},
+			},
+		},
+		{
+			Name:        "ack",
+			Description: "Acknowledge an alert, so that it is not escalated",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "id",
+					Description: "ID of the alert to acknowledge",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:        "list-alerts",
+			Description: "List your alerts, and the alerts of this server you manage",
+		},
+		ruleCommand("remove-alert", "Remove one of your alerts"),
+		ruleCommand("edit-alert", "Edit the condition and the severity of one of your alerts"),
+		ruleCommand("pause-alert", "Pause one of your alerts, it does not match until resumed"),
+		ruleCommand("resume-alert", "Resume one of your paused alerts"),
+		ruleCommand("alert-history", "Show the changes of one of your alerts"),
+		{
+			Name:        "expire-alert",
+			Description: "Make one of your alerts stop matching after a number of days",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "id",
+					Description: "ID of the alert",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "days",
+					Description: "Number of days from now, the alert never expires if not set",
+					MinValue:    &minDays,
+					MaxValue:    365,
+				},
+			},
+		},
+		{
+			Name:        "schedule",
+			Description: "Choose the days your alerts are sent on and your quiet hours, or show them",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "weekdays",
+					Description: "Days your alerts are sent on, e.g. mon,tue,wed,thu,fri, or all",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "quiet",
+					Description: "Quiet hours, their alerts are sent when they end, e.g. 22:00-07:00, or off",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "timezone",
+					Description: "Your timezone, e.g. Europe/Paris, UTC by default",
+				},
+			},
+		},
+		{
+			Name:        "email",
+			Description: "Receive your alerts by email, a code is sent to verify the address",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "address",
+					Description: "Email address",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:        "verify",
+			Description: "Verify your email address with the code sent to it",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "code",
+					Description: "Verification code",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:        "digest",
+			Description: "Receive your alerts, or the alerts of one of them, in an hourly or daily summary",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "mode",
+					Description: "When the alerts are sent",
+					Required:    true,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "realtime", Value: string(model.DigestRealtime)},
+						{Name: "hourly", Value: string(model.DigestHourly)},
+						{Name: "daily", Value: string(model.DigestDaily)},
+						{Name: "default, for an alert to follow your mode", Value: "default"},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "hour",
+					Description: "Hour of the day daily summaries are sent at, 0 by default",
+					MinValue:    &minHour,
+					MaxValue:    23,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "timezone",
+					Description: "Your timezone, e.g. Europe/Paris, UTC by default",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "alert",
+					Description: "ID of the alert, all of your alerts if not set",
+				},
+			},
+		},
+	}
+)
+
+// ruleCommand is a command acting on one of the alerts of the user, chosen by its id.
+func ruleCommand(name, description string) discordgo.ApplicationCommand {
+	return discordgo.ApplicationCommand{
+		Name:        name,
+		Description: description,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "id",
+				Description: "ID of the alert",
+				Required:    true,
+			},
+		},
+	}
 }
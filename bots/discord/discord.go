@@ -3,9 +3,11 @@ package discord
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/allegro/bigcache/v3"
+	"github.com/strahe/suialert/bots"
 	"github.com/strahe/suialert/model"
 
 	"github.com/strahe/suialert/service"
@@ -16,30 +18,40 @@ import (
 )
 
 type Bot struct {
-	cfg     config.DiscordBotConfig
-	session *discordgo.Session
+	cfg      config.DiscordBotConfig
+	explorer bots.Explorer
+	session  *discordgo.Session
 
 	cmdIDs map[string]string
 
-	userService *service.UserService
-	ruleService *service.RuleService
+	userService  *service.UserService
+	ruleService  *service.RuleService
+	matchService *service.MatchService
+	ackService   *service.AckService
+	// verifier is nil when email is disabled
+	verifier bots.Verifier
 
 	cache *bigcache.BigCache
 }
 
-func NewDiscord(cfg config.DiscordBotConfig,
-	userService *service.UserService, ruleService *service.RuleService) (*Bot, error) {
+func NewDiscord(cfg config.DiscordBotConfig, explorer bots.Explorer, userService *service.UserService,
+	ruleService *service.RuleService, matchService *service.MatchService, ackService *service.AckService,
+	verifier bots.Verifier) (*Bot, error) {
 	ss, err := discordgo.New("Bot " + cfg.Token)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create bot: %s", err)
 	}
 
 	bot := &Bot{
-		cfg:         cfg,
-		session:     ss,
-		cmdIDs:      map[string]string{},
-		userService: userService,
-		ruleService: ruleService,
+		cfg:          cfg,
+		explorer:     explorer,
+		session:      ss,
+		cmdIDs:       map[string]string{},
+		userService:  userService,
+		ruleService:  ruleService,
+		matchService: matchService,
+		ackService:   ackService,
+		verifier:     verifier,
 	}
 	bot.addHandlers()
 	return bot, nil
@@ -78,16 +90,50 @@ func (b *Bot) addHandlers() {
 			switch i.ApplicationCommandData().Name {
 			case "add-alert":
 				b.handleAddAlert(s, i)
+			case "alert-stats":
+				b.handleAlertStats(s, i)
+			case "ack":
+				b.handleAck(s, i)
+			case "list-alerts":
+				b.handleListAlerts(s, i)
+			case "remove-alert":
+				b.handleRemoveAlert(s, i)
+			case "edit-alert":
+				b.handleEditAlert(s, i)
+			case "pause-alert", "resume-alert":
+				b.handlePauseAlert(s, i)
+			case "alert-history":
+				b.handleAlertHistory(s, i)
+			case "email":
+				b.handleEmail(s, i)
+			case "verify":
+				b.handleVerify(s, i)
+			case "digest":
+				b.handleDigest(s, i)
+			case "schedule":
+				b.handleSchedule(s, i)
+			case "expire-alert":
+				b.handleExpireAlert(s, i)
 			default:
 				zap.S().Errorf("Unknown slash command: %s", i.ApplicationCommandData().Name)
 			}
 		case discordgo.InteractionMessageComponent:
-			switch i.MessageComponentData().CustomID {
+			id, _, _ := strings.Cut(i.MessageComponentData().CustomID, ":")
+			switch id {
 			case "selected-event":
 				b.handSelectedEvent(s, i)
+			case "list-alerts":
+				b.handleListAlertsPage(s, i)
+			case "mute-rule":
+				b.handleMuteRule(s, i)
+			case "ack-alert":
+				b.handleAckButton(s, i)
 			}
 		case discordgo.InteractionModalSubmit:
-			// only one case for now
+			if strings.HasPrefix(i.ModalSubmitData().CustomID, "edit-alert:") {
+				b.handleEditAlertSubmitted(s, i)
+				return
+			}
 			b.handAddAlertFormSubmitted(s, i)
 		default:
 			zap.S().Errorf("Unknown slash command: %s", i.Type)
@@ -116,13 +162,32 @@ func (b *Bot) removeCommands() error {
 	return nil
 }
 
-func (b *Bot) findOrCreateUser(i *discordgo.InteractionCreate) (*model.User, error) {
-	var user *discordgo.User
+func interactionUser(i *discordgo.InteractionCreate) *discordgo.User {
 	if i.User != nil {
-		user = i.User
-	} else if i.Member != nil && i.Member.User != nil {
-		user = i.Member.User
+		return i.User
+	}
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User
 	}
+	return nil
+}
+
+// actorContext returns a context recording the user of the interaction as the author of changes,
+// in a server the rules managed by the roles of the user are also theirs.
+func (b *Bot) actorContext(i *discordgo.InteractionCreate) context.Context {
+	actor := model.Actor{Source: model.SourceDiscord}
+	if u := interactionUser(i); u != nil {
+		actor.Name = u.String()
+	}
+	ctx := service.WithActor(context.Background(), actor)
+	if i.GuildID != "" && i.Member != nil {
+		ctx = service.WithMember(ctx, service.Member{GuildID: i.GuildID, Roles: i.Member.Roles})
+	}
+	return ctx
+}
+
+func (b *Bot) findOrCreateUser(i *discordgo.InteractionCreate) (*model.User, error) {
+	user := interactionUser(i)
 	if user == nil {
 		return nil, fmt.Errorf("failed to find user id")
 	}
@@ -0,0 +1,72 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/strahe/suialert/service"
+	"go.uber.org/zap"
+)
+
+func (b *Bot) handleAck(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	u, err := b.findOrCreateUser(i)
+	if err != nil {
+		zap.S().Errorf("failed to find user: %s", err)
+		return
+	}
+	var id uint
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Name == "id" {
+			id = uint(opt.IntValue())
+		}
+	}
+	b.acknowledge(s, i, u.ID, id)
+}
+
+// handleAckButton acknowledges the alert of an "ack-alert:<id>" button.
+func (b *Bot) handleAckButton(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	u, err := b.findOrCreateUser(i)
+	if err != nil {
+		zap.S().Errorf("failed to find user: %s", err)
+		return
+	}
+	_, v, _ := strings.Cut(i.MessageComponentData().CustomID, ":")
+	id, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		b.respondError(s, i, "Invalid alert")
+		return
+	}
+	b.acknowledge(s, i, u.ID, uint(id))
+}
+
+func (b *Bot) acknowledge(s *discordgo.Session, i *discordgo.InteractionCreate, uid, id uint) {
+	start := time.Now()
+	ack, err := b.ackService.Acknowledge(b.actorContext(i), id, uid)
+	switch {
+	case errors.Is(err, service.ErrNotFound), errors.Is(err, service.ErrForbidden):
+		b.respondError(s, i, fmt.Sprintf("Alert %d not found", id))
+		return
+	case err != nil:
+		zap.S().Errorf("failed to acknowledge alert %d: %s", id, err)
+		b.respondError(s, i, "Failed to acknowledge the alert, please try again later")
+		return
+	}
+	content := fmt.Sprintf("Alert %d acknowledged", id)
+	if ack.AckedAt.Before(start) {
+		content = fmt.Sprintf("Alert %d was acknowledged by %s <t:%d:R>", id, ack.AckedBy, ack.AckedAt.Unix())
+	}
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	}, b.options()...)
+	if err != nil {
+		zap.S().Error(err)
+	}
+}
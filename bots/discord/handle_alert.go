@@ -14,7 +14,43 @@ import (
 	"go.uber.org/zap"
 )
 
+// alertOptions are the options of /add-alert, carried by the custom ids of the select menu and the modal
+// as "selected-event:<options>" and "add-alert-for-<event>:<options>".
+type alertOptions struct {
+	Severity model.Severity
+	// Channel to post the alerts in, and the Role managing them, empty for the alerts sent to the user
+	Channel string
+	Role    string
+}
+
+func (o alertOptions) String() string {
+	return strings.Join([]string{string(o.Severity), o.Channel, o.Role}, ":")
+}
+
+func parseAlertOptions(s string) alertOptions {
+	parts := strings.SplitN(s, ":", 3)
+	for len(parts) < 3 {
+		parts = append(parts, "")
+	}
+	return alertOptions{Severity: model.Severity(parts[0]), Channel: parts[1], Role: parts[2]}
+}
+
 func (b *Bot) handleAddAlert(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	opts := alertOptions{Severity: model.SeverityInfo}
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "severity":
+			opts.Severity = model.Severity(opt.StringValue())
+		case "channel":
+			opts.Channel = opt.Value.(string)
+		case "role":
+			opts.Role = opt.Value.(string)
+		}
+	}
+	if msg := b.checkChannelAlert(s, i, opts); msg != "" {
+		b.respondError(s, i, msg)
+		return
+	}
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
@@ -24,7 +60,7 @@ func (b *Bot) handleAddAlert(s *discordgo.Session, i *discordgo.InteractionCreat
 				discordgo.ActionsRow{
 					Components: []discordgo.MessageComponent{
 						discordgo.SelectMenu{
-							CustomID:    "selected-event",
+							CustomID:    "selected-event:" + opts.String(),
 							Placeholder: "Which type of event would you like to monitor?",
 							Options:     buildEventOptions(),
 						},
@@ -41,10 +77,11 @@ func (b *Bot) handleAddAlert(s *discordgo.Session, i *discordgo.InteractionCreat
 func (b *Bot) handSelectedEvent(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	data := i.MessageComponentData()
 	event := data.Values[0]
+	_, opts, _ := strings.Cut(data.CustomID, ":")
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseModal,
 		Data: &discordgo.InteractionResponseData{
-			CustomID: "add-alert-for-" + event,
+			CustomID: "add-alert-for-" + event + ":" + opts,
 			Title:    "Add an alarm of type " + event,
 			Content:  "hello",
 			Components: []discordgo.MessageComponent{
@@ -91,16 +128,34 @@ func (b *Bot) handAddAlertFormSubmitted(s *discordgo.Session, i *discordgo.Inter
 		return
 	}
 	// todo: check condition invalid
-	event := md.CustomID[len("add-alert-for-"):]
+	event, o, _ := strings.Cut(md.CustomID[len("add-alert-for-"):], ":")
+	opts := parseAlertOptions(o)
+	// the roles of the member may have changed since the command
+	if msg := b.checkChannelAlert(s, i, opts); msg != "" {
+		b.respondError(s, i, msg)
+		return
+	}
 	addr := md.Components[0].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
 	rule := md.Components[1].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
 
-	err = b.ruleService.Create(&model.Rule{
+	r := &model.Rule{
 		Address:   types.HexToAddress(addr),
 		Event:     types.EventType(event),
 		User:      *u,
 		Condition: rule,
-	})
+		Severity:  opts.Severity,
+	}
+	content := "Alert added"
+	if opts.Channel != "" {
+		r.Destination = "discord:" + opts.Channel
+		r.GuildID = i.GuildID
+		r.RoleID = opts.Role
+		content = fmt.Sprintf("Alert added, it is posted in <#%s>", opts.Channel)
+		if opts.Role != "" {
+			content += fmt.Sprintf(" and can be managed by <@&%s>", opts.Role)
+		}
+	}
+	err = b.ruleService.Create(b.actorContext(i), r)
 	if err != nil {
 		zap.S().Infof("failed to create rule: %v", err)
 		// todo
@@ -110,8 +165,9 @@ func (b *Bot) handAddAlertFormSubmitted(s *discordgo.Session, i *discordgo.Inter
 	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Content: "Alert added",
-			Flags:   discordgo.MessageFlagsEphemeral,
+			Content:         content,
+			Flags:           discordgo.MessageFlagsEphemeral,
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
 		},
 	}, b.options()...)
 	if err != nil {
@@ -119,6 +175,65 @@ func (b *Bot) handAddAlertFormSubmitted(s *discordgo.Session, i *discordgo.Inter
 	}
 }
 
+// checkChannelAlert checks that the member adding an alert in a channel of a server may manage it,
+// it returns the reason why not, empty if allowed.
+// The alerts of a channel are added by the members holding their role, or managing the server.
+// The channel must be in the server of the interaction, and the member must be allowed to post in it,
+// or to manage it for an alert managed by a role.
+func (b *Bot) checkChannelAlert(s *discordgo.Session, i *discordgo.InteractionCreate, opts alertOptions) string {
+	switch {
+	case opts.Channel == "" && opts.Role == "":
+		return ""
+	case i.GuildID == "" || i.Member == nil || i.Member.User == nil:
+		return "Alerts can only be posted in a channel when added in its server"
+	case opts.Channel == "":
+		return "A role can only be set on the alerts posted in a channel"
+	}
+	if i.Member.Permissions&(discordgo.PermissionAdministrator|discordgo.PermissionManageServer) == 0 &&
+		(opts.Role == "" || !lo.Contains(i.Member.Roles, opts.Role)) {
+		return "Only the members managing this server, or holding the role of the alert, can post alerts in its channels"
+	}
+
+	ch, err := b.channel(s, opts.Channel)
+	if err != nil {
+		zap.S().Warnf("failed to get channel %s: %s", opts.Channel, err)
+		return "The channel of the alert cannot be found"
+	}
+	if ch.GuildID != i.GuildID {
+		return "Alerts can only be posted in the channels of this server"
+	}
+	// the permissions in a thread are the ones of its channel
+	need, id := int64(discordgo.PermissionSendMessages), ch.ID
+	if ch.IsThread() {
+		need, id = discordgo.PermissionSendMessagesInThreads, ch.ParentID
+	}
+	if opts.Role != "" {
+		need = discordgo.PermissionManageChannels
+	}
+	perms, err := s.State.UserChannelPermissions(i.Member.User.ID, id)
+	if err != nil {
+		if perms, err = s.UserChannelPermissions(i.Member.User.ID, id, b.options()...); err != nil {
+			zap.S().Warnf("failed to get the permissions of %s in channel %s: %s", i.Member.User.ID, id, err)
+			return "Your permissions in the channel of the alert cannot be checked"
+		}
+	}
+	if perms&need != need {
+		if opts.Role != "" {
+			return "Only the members managing the channel can add alerts managed by a role in it"
+		}
+		return "Only the members allowed to send messages in the channel can add alerts in it"
+	}
+	return ""
+}
+
+// channel returns a channel from the state, or from the api if not cached.
+func (b *Bot) channel(s *discordgo.Session, id string) (*discordgo.Channel, error) {
+	if ch, err := s.State.Channel(id); err == nil {
+		return ch, nil
+	}
+	return s.Channel(id, b.options()...)
+}
+
 func (b *Bot) returnError(s *discordgo.Session, i *discordgo.InteractionCreate, msgs map[discordgo.Locale]string) error {
 	if len(msgs) == 0 {
 		return fmt.Errorf("no messages")
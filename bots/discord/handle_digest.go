@@ -0,0 +1,69 @@
+package discord
+
+import (
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/strahe/suialert/model"
+	"go.uber.org/zap"
+)
+
+// handleDigest sets the digest mode of the user, or of one of their alerts when the alert option is set.
+func (b *Bot) handleDigest(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	u, err := b.findOrCreateUser(i)
+	if err != nil {
+		zap.S().Errorf("failed to find user: %s", err)
+		return
+	}
+	var (
+		mode     model.DigestMode
+		rule     uint
+		schedule = u.Schedule
+		timezone = u.Timezone
+	)
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "mode":
+			if v := opt.StringValue(); v != "default" {
+				mode = model.DigestMode(v)
+			}
+		case "hour":
+			schedule.DigestHour = int(opt.IntValue())
+		case "timezone":
+			timezone = opt.StringValue()
+		case "alert":
+			rule = uint(opt.IntValue())
+		}
+	}
+
+	if rule != 0 {
+		if _, err := b.ruleService.SetDigestOwned(b.actorContext(i), u.ID, rule, mode); err != nil {
+			b.respondRuleError(s, i, rule, "set the digest of", err)
+			return
+		}
+		if mode == model.DigestDefault {
+			b.respondMessage(s, i, fmt.Sprintf("Alert %d follows your digest mode", rule))
+		} else {
+			b.respondMessage(s, i, fmt.Sprintf("Alert %d is sent %s", rule, mode))
+		}
+		return
+	}
+	if mode == model.DigestDefault {
+		b.respondError(s, i, "The default mode is for an alert, choose realtime, hourly or daily")
+		return
+	}
+	schedule.Digest = mode
+	if err := b.userService.UpdateSchedule(u, timezone, schedule); err != nil {
+		b.respondError(s, i, err.Error())
+		return
+	}
+	switch mode {
+	case model.DigestRealtime:
+		b.respondMessage(s, i, "Your alerts are sent as they happen")
+	case model.DigestHourly:
+		b.respondMessage(s, i, "Your alerts are sent in a summary every hour")
+	case model.DigestDaily:
+		b.respondMessage(s, i, fmt.Sprintf("Your alerts are sent in a summary every day at %02d:00 %s",
+			schedule.DigestHour, u.Location()))
+	}
+}
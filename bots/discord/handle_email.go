@@ -0,0 +1,85 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/strahe/suialert/service"
+	"go.uber.org/zap"
+)
+
+func (b *Bot) handleEmail(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if b.verifier == nil {
+		b.respondError(s, i, "Email alerts are not enabled")
+		return
+	}
+	u, err := b.findOrCreateUser(i)
+	if err != nil {
+		zap.S().Errorf("failed to find user: %s", err)
+		return
+	}
+	var address string
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Name == "address" {
+			address = opt.StringValue()
+		}
+	}
+	code, err := b.userService.SetEmail(u, address)
+	if err != nil {
+		b.respondError(s, i, err.Error())
+		return
+	}
+	// sending the email may take longer than the time to respond
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+	}, b.options()...)
+	if err != nil {
+		zap.S().Error(err)
+		return
+	}
+	content := fmt.Sprintf("A verification code was sent to %s, use `/verify` with it within an hour", *u.Email)
+	if err := b.verifier.SendVerification(context.Background(), *u.Email, code); err != nil {
+		zap.S().Errorf("failed to send the verification code to %s: %s", *u.Email, err)
+		content = "Failed to send the verification code, please check the address or try again later"
+	}
+	if _, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{Content: &content}, b.options()...); err != nil {
+		zap.S().Error(err)
+	}
+}
+
+func (b *Bot) handleVerify(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	u, err := b.findOrCreateUser(i)
+	if err != nil {
+		zap.S().Errorf("failed to find user: %s", err)
+		return
+	}
+	var code string
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Name == "code" {
+			code = opt.StringValue()
+		}
+	}
+	err = b.userService.VerifyEmail(u, code)
+	switch {
+	case errors.Is(err, service.ErrInvalidCode):
+		b.respondError(s, i, "Invalid or expired code, use `/email` to get a new one")
+		return
+	case err != nil:
+		zap.S().Errorf("failed to verify the email of user %d: %s", u.ID, err)
+		b.respondError(s, i, "Failed to verify the email, please try again later")
+		return
+	}
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("Your alerts are also sent to %s", *u.Email),
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	}, b.options()...)
+	if err != nil {
+		zap.S().Error(err)
+	}
+}
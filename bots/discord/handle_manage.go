@@ -0,0 +1,320 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/strahe/suialert/bots"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
+	"go.uber.org/zap"
+)
+
+const (
+	// alertsPerPage is the number of alerts listed per page of /list-alerts
+	alertsPerPage = 5
+	// historyVersions is the number of versions shown by /alert-history
+	historyVersions = 10
+)
+
+// The pages of /list-alerts are browsed with buttons of custom id "list-alerts:<page>".
+func (b *Bot) handleListAlerts(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	b.respondAlertsPage(s, i, 0, discordgo.InteractionResponseChannelMessageWithSource)
+}
+
+func (b *Bot) handleListAlertsPage(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	_, p, _ := strings.Cut(i.MessageComponentData().CustomID, ":")
+	page, _ := strconv.Atoi(p)
+	b.respondAlertsPage(s, i, page, discordgo.InteractionResponseUpdateMessage)
+}
+
+func (b *Bot) respondAlertsPage(s *discordgo.Session, i *discordgo.InteractionCreate, page int, typ discordgo.InteractionResponseType) {
+	u, err := b.findOrCreateUser(i)
+	if err != nil {
+		zap.S().Errorf("failed to find user: %s", err)
+		return
+	}
+	rules, total, err := b.ruleService.ListByUser(b.actorContext(i), u.ID, page*alertsPerPage, alertsPerPage)
+	if err != nil {
+		zap.S().Errorf("failed to list the rules of user %d: %s", u.ID, err)
+		b.respondError(s, i, "Failed to list your alerts, please try again later")
+		return
+	}
+	pages := int((total + alertsPerPage - 1) / alertsPerPage)
+
+	data := &discordgo.InteractionResponseData{
+		Content: formatAlertsPage(rules, page, pages, total),
+		Flags:   discordgo.MessageFlagsEphemeral,
+	}
+	if pages > 1 {
+		data.Components = []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    "Previous",
+						Style:    discordgo.SecondaryButton,
+						CustomID: fmt.Sprintf("list-alerts:%d", page-1),
+						Disabled: page <= 0,
+					},
+					discordgo.Button{
+						Label:    "Next",
+						Style:    discordgo.SecondaryButton,
+						CustomID: fmt.Sprintf("list-alerts:%d", page+1),
+						Disabled: page >= pages-1,
+					},
+				},
+			},
+		}
+	}
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{Type: typ, Data: data}, b.options()...)
+	if err != nil {
+		zap.S().Error(err)
+	}
+}
+
+func formatAlertsPage(rules []model.Rule, page, pages int, total int64) string {
+	if total == 0 {
+		return "You have no alerts, use `/add-alert` to add one"
+	}
+	if len(rules) == 0 {
+		return "No more alerts, use `/list-alerts` to see the first ones"
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("**Your alerts** (page %d/%d, %d alerts)\n", page+1, pages, total))
+	for _, r := range rules {
+		sb.WriteString(fmt.Sprintf("%s **%d** %s on `%s`", r.Severity.Emoji(), r.ID, r.Event, r.Address.Hex()))
+		if name, channel := bots.ParseDestination(r.Destination); name == "discord" {
+			sb.WriteString(fmt.Sprintf(" in <#%s>", channel))
+		}
+		if r.Digest.Batched() {
+			sb.WriteString(fmt.Sprintf(", sent %s", r.Digest))
+		}
+		if r.Paused {
+			sb.WriteString(" (paused)")
+		}
+		if r.ExpiresAt != nil {
+			sb.WriteString(fmt.Sprintf(", expires <t:%d:R>", r.ExpiresAt.Unix()))
+		}
+		sb.WriteString(fmt.Sprintf("\n`%s`\n", truncate(r.Condition, 150)))
+	}
+	return sb.String()
+}
+
+func (b *Bot) handleRemoveAlert(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	u, err := b.findOrCreateUser(i)
+	if err != nil {
+		zap.S().Errorf("failed to find user: %s", err)
+		return
+	}
+	id := ruleOption(i)
+	if _, err := b.ruleService.DeleteOwned(b.actorContext(i), u.ID, id); err != nil {
+		b.respondRuleError(s, i, id, "remove", err)
+		return
+	}
+	b.respondMessage(s, i, fmt.Sprintf("Alert %d removed", id))
+}
+
+func (b *Bot) handlePauseAlert(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	u, err := b.findOrCreateUser(i)
+	if err != nil {
+		zap.S().Errorf("failed to find user: %s", err)
+		return
+	}
+	id := ruleOption(i)
+	pause := i.ApplicationCommandData().Name == "pause-alert"
+	if pause {
+		_, err = b.ruleService.PauseOwned(b.actorContext(i), u.ID, id)
+	} else {
+		_, err = b.ruleService.ResumeOwned(b.actorContext(i), u.ID, id)
+	}
+	if err != nil {
+		b.respondRuleError(s, i, id, strings.TrimSuffix(i.ApplicationCommandData().Name, "-alert"), err)
+		return
+	}
+	if pause {
+		b.respondMessage(s, i, fmt.Sprintf("Alert %d paused, use `/resume-alert` to resume it", id))
+	} else {
+		b.respondMessage(s, i, fmt.Sprintf("Alert %d resumed", id))
+	}
+}
+
+// handleMuteRule mutes the rule of a "mute-rule:<id>" button of an alert for muteFor.
+func (b *Bot) handleMuteRule(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	u, err := b.findOrCreateUser(i)
+	if err != nil {
+		zap.S().Errorf("failed to find user: %s", err)
+		return
+	}
+	_, v, _ := strings.Cut(i.MessageComponentData().CustomID, ":")
+	id, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		b.respondError(s, i, "Invalid alert")
+		return
+	}
+	until := time.Now().Add(muteFor)
+	if _, err := b.ruleService.MuteOwned(b.actorContext(i), u.ID, uint(id), until); err != nil {
+		b.respondRuleError(s, i, uint(id), "mute", err)
+		return
+	}
+	b.respondMessage(s, i, fmt.Sprintf("Alert %d muted until <t:%d:t>", id, until.Unix()))
+}
+
+// The modal of /edit-alert has the custom id "edit-alert:<id>", and is filled with the current values.
+func (b *Bot) handleEditAlert(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	u, err := b.findOrCreateUser(i)
+	if err != nil {
+		zap.S().Errorf("failed to find user: %s", err)
+		return
+	}
+	id := ruleOption(i)
+	r, err := b.ruleService.FindOwned(b.actorContext(i), u.ID, id)
+	if err != nil {
+		b.respondRuleError(s, i, id, "edit", err)
+		return
+	}
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: fmt.Sprintf("edit-alert:%d", r.ID),
+			Title:    truncate(fmt.Sprintf("Edit alert %d on %s", r.ID, r.Event), 45),
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:  "rules",
+							Label:     "Monitoring rules",
+							Style:     discordgo.TextInputParagraph,
+							Value:     r.Condition,
+							Required:  true,
+							MaxLength: 1000,
+						},
+					},
+				},
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:  "severity",
+							Label:     "Severity: info, warning or critical",
+							Style:     discordgo.TextInputShort,
+							Value:     string(r.Severity),
+							Required:  true,
+							MaxLength: 8,
+						},
+					},
+				},
+			},
+		},
+	}, b.options()...)
+	if err != nil {
+		zap.S().Error(err)
+	}
+}
+
+func (b *Bot) handleEditAlertSubmitted(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	u, err := b.findOrCreateUser(i)
+	if err != nil {
+		zap.S().Errorf("failed to find user: %s", err)
+		return
+	}
+	md := i.ModalSubmitData()
+	id, err := strconv.ParseUint(strings.TrimPrefix(md.CustomID, "edit-alert:"), 10, 64)
+	if err != nil {
+		b.respondError(s, i, "Invalid alert")
+		return
+	}
+	condition := md.Components[0].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
+	severity := model.Severity(strings.ToLower(strings.TrimSpace(
+		md.Components[1].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value)))
+	if !severity.Valid() {
+		b.respondError(s, i, fmt.Sprintf("Unknown severity %q, expected info, warning or critical", severity))
+		return
+	}
+	if _, err := b.ruleService.EditOwned(b.actorContext(i), u.ID, uint(id), condition, severity); err != nil {
+		b.respondRuleError(s, i, uint(id), "edit", err)
+		return
+	}
+	b.respondMessage(s, i, fmt.Sprintf("Alert %d updated", id))
+}
+
+func (b *Bot) handleAlertHistory(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	u, err := b.findOrCreateUser(i)
+	if err != nil {
+		zap.S().Errorf("failed to find user: %s", err)
+		return
+	}
+	id := ruleOption(i)
+	history, err := b.ruleService.HistoryOwned(b.actorContext(i), u.ID, id)
+	if err != nil {
+		b.respondRuleError(s, i, id, "show the history of", err)
+		return
+	}
+	b.respondMessage(s, i, formatHistory(id, history))
+}
+
+func formatHistory(id uint, history []model.RuleHistory) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("**History of alert %d**", id))
+	if len(history) > historyVersions {
+		sb.WriteString(fmt.Sprintf(" (last %d of %d versions)", historyVersions, len(history)))
+		history = history[len(history)-historyVersions:]
+	}
+	sb.WriteString("\n")
+	for _, h := range history {
+		actor := model.Actor{Source: h.Source, Name: h.Actor}
+		sb.WriteString(fmt.Sprintf("**v%d** %s by %s <t:%d:R>\n", h.Version, h.Change, actor, h.CreatedAt.Unix()))
+		// creations and deletions change every field, only the edits are detailed
+		if h.Change == model.ChangeCreated || h.Change == model.ChangeDeleted {
+			continue
+		}
+		for _, d := range h.Diff {
+			sb.WriteString(fmt.Sprintf("  %s: `%s` → `%s`\n", d.Field, truncate(d.Old, 80), truncate(d.New, 80)))
+		}
+	}
+	return truncate(sb.String(), 2000)
+}
+
+// ruleOption returns the id option of a rule command.
+func ruleOption(i *discordgo.InteractionCreate) uint {
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Name == "id" {
+			return uint(opt.IntValue())
+		}
+	}
+	return 0
+}
+
+// respondRuleError responds to a failed action on a rule of the user.
+func (b *Bot) respondRuleError(s *discordgo.Session, i *discordgo.InteractionCreate, id uint, action string, err error) {
+	if errors.Is(err, service.ErrNotFound) {
+		b.respondError(s, i, fmt.Sprintf("Alert %d not found", id))
+		return
+	}
+	zap.S().Errorf("failed to %s rule %d: %s", action, id, err)
+	b.respondError(s, i, fmt.Sprintf("Failed to %s the alert: %s", action, err))
+}
+
+func (b *Bot) respondMessage(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	}, b.options()...)
+	if err != nil {
+		zap.S().Error(err)
+	}
+}
+
+// truncate shortens s to at most n characters.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,77 @@
+package discord
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/strahe/suialert/model"
+	"go.uber.org/zap"
+)
+
+// handleSchedule sets the days and the quiet hours of the alerts of the user, the options not set are kept.
+func (b *Bot) handleSchedule(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	u, err := b.findOrCreateUser(i)
+	if err != nil {
+		zap.S().Errorf("failed to find user: %s", err)
+		return
+	}
+	var (
+		schedule = u.Schedule
+		timezone = u.Timezone
+		changed  bool
+	)
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "weekdays":
+			schedule.Weekdays, err = model.ParseWeekdays(opt.StringValue())
+		case "quiet":
+			schedule.QuietStart, schedule.QuietEnd, err = model.ParseQuietHours(opt.StringValue())
+		case "timezone":
+			timezone = opt.StringValue()
+		}
+		if err != nil {
+			b.respondError(s, i, err.Error())
+			return
+		}
+		changed = true
+	}
+
+	if changed {
+		if err := b.userService.UpdateSchedule(u, timezone, schedule); err != nil {
+			b.respondError(s, i, err.Error())
+			return
+		}
+	}
+	b.respondMessage(s, i, fmt.Sprintf("Your alerts are sent %s (%s)", u.Schedule.String(), u.Location()))
+}
+
+func (b *Bot) handleExpireAlert(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	u, err := b.findOrCreateUser(i)
+	if err != nil {
+		zap.S().Errorf("failed to find user: %s", err)
+		return
+	}
+	var (
+		id uint
+		at *time.Time
+	)
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "id":
+			id = uint(opt.IntValue())
+		case "days":
+			t := time.Now().AddDate(0, 0, int(opt.IntValue())).UTC()
+			at = &t
+		}
+	}
+	if _, err := b.ruleService.SetExpiryOwned(b.actorContext(i), u.ID, id, at); err != nil {
+		b.respondRuleError(s, i, id, "set the expiry of", err)
+		return
+	}
+	if at == nil {
+		b.respondMessage(s, i, fmt.Sprintf("Alert %d never expires", id))
+	} else {
+		b.respondMessage(s, i, fmt.Sprintf("Alert %d expires <t:%d:R>", id, at.Unix()))
+	}
+}
@@ -0,0 +1,100 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/strahe/suialert/model"
+	"go.uber.org/zap"
+)
+
+func (b *Bot) handleAlertStats(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	u, err := b.findOrCreateUser(i)
+	if err != nil {
+		zap.S().Errorf("failed to find user: %s", err)
+		return
+	}
+	var (
+		ruleID uint
+		days   int64 = 7
+	)
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "rule":
+			ruleID = uint(opt.IntValue())
+		case "days":
+			days = opt.IntValue()
+		}
+	}
+	ctx := context.Background()
+	since := time.Now().AddDate(0, 0, -int(days))
+
+	var stats []model.RuleStats
+	if ruleID != 0 {
+		r, err := b.ruleService.FindByID(ctx, ruleID)
+		if err != nil || r.UserID != u.ID {
+			b.respondError(s, i, fmt.Sprintf("Alert %d not found", ruleID))
+			return
+		}
+		st, err := b.matchService.Stats(ctx, ruleID, since)
+		if err != nil {
+			zap.S().Errorf("failed to get stats of rule %d: %s", ruleID, err)
+			b.respondError(s, i, "Failed to get the stats, please try again later")
+			return
+		}
+		stats = append(stats, *st)
+	} else {
+		stats, err = b.matchService.StatsByUser(ctx, u.ID, since)
+		if err != nil {
+			zap.S().Errorf("failed to get stats of user %d: %s", u.ID, err)
+			b.respondError(s, i, "Failed to get the stats, please try again later")
+			return
+		}
+	}
+
+	content := formatStats(stats, days)
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	}, b.options()...)
+	if err != nil {
+		zap.S().Error(err)
+	}
+}
+
+func (b *Bot) respondError(s *discordgo.Session, i *discordgo.InteractionCreate, msg string) {
+	if err := b.returnError(s, i, map[discordgo.Locale]string{discordgo.EnglishUS: msg}); err != nil {
+		zap.S().Error(err)
+	}
+}
+
+func formatStats(stats []model.RuleStats, days int64) string {
+	if len(stats) == 0 {
+		return "You have no alerts"
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("**Matches of the last %d days**\n", days))
+	for _, st := range stats {
+		last := "never"
+		if st.LastMatch != nil {
+			last = fmt.Sprintf("<t:%d:R>", st.LastMatch.Unix())
+		}
+		sb.WriteString(fmt.Sprintf("Alert %d: %d matches, last %s\n", st.RuleID, st.Total, last))
+		if st.Total > 0 {
+			sb.WriteString(fmt.Sprintf("  delivered %d, failed %d, suppressed %d, held %d, queued %d\n",
+				st.Delivered, st.Failed, st.Suppressed, st.Held, st.Queued))
+		}
+	}
+	// discord limits messages to 2000 characters
+	content := sb.String()
+	if len(content) > 2000 {
+		content = content[:1990] + "\n…"
+	}
+	return content
+}
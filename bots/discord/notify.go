@@ -0,0 +1,123 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/strahe/suialert/bots"
+	"github.com/strahe/suialert/model"
+)
+
+// muteFor is how long the "Mute 1h" button of an alert suppresses the notifications of its rule.
+const muteFor = time.Hour
+
+func (b *Bot) Name() string {
+	return "discord"
+}
+
+func (b *Bot) Capabilities() bots.Capability {
+	return bots.RichFormatting | bots.Buttons | bots.Attachments
+}
+
+// Notify sends the alert to its destination channel,
+// or as a direct message to the owner of the rule.
+func (b *Bot) Notify(_ context.Context, alert *model.Alert) error {
+	channelID := alert.Destination
+	if channelID == "" {
+		u, err := b.userService.FindByID(alert.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to find user %d: %w", alert.UserID, err)
+		}
+		if u.DiscordID == nil {
+			return fmt.Errorf("user %d has no discord account: %w", u.ID, bots.ErrUnreachable)
+		}
+		ch, err := b.session.UserChannelCreate(*u.DiscordID, b.options()...)
+		if err != nil {
+			return fmt.Errorf("failed to create dm channel: %w", err)
+		}
+		channelID = ch.ID
+	}
+	_, err := b.session.ChannelMessageSendComplex(channelID, formatAlert(alert, b.explorer), b.options()...)
+	return err
+}
+
+// formatAlert formats an alert as an embed, with buttons to view the transaction,
+// mute the rule and acknowledge the alert.
+func formatAlert(alert *model.Alert, explorer bots.Explorer) *discordgo.MessageSend {
+	c := bots.NewContent(alert, explorer)
+	embed := &discordgo.MessageEmbed{
+		Title:     truncate(c.Emoji+" "+c.Title, 256),
+		URL:       c.TxURL,
+		Color:     color(alert.Severity),
+		Timestamp: c.Time.UTC().Format(time.RFC3339),
+	}
+	var desc strings.Builder
+	if c.Escalated != "" {
+		desc.WriteString("**" + c.Escalated + "**\n")
+	}
+	if c.Table != nil {
+		desc.WriteString("```\n" + c.Rows(5) + "```")
+	}
+	embed.Description = truncate(desc.String(), 4096)
+	for _, f := range c.Fields {
+		v := f.Value
+		if f.Code {
+			v = "`" + v + "`"
+		}
+		if f.Link != "" {
+			v = "[" + v + "](" + f.Link + ")"
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  f.Name,
+			Value: truncate(v, 1024),
+			// identifiers are too long to be shown side by side
+			Inline: !f.Code,
+		})
+	}
+	if c.Footer != "" {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: c.Footer}
+	}
+
+	var buttons []discordgo.MessageComponent
+	if c.TxURL != "" {
+		buttons = append(buttons, discordgo.Button{
+			Label: "View transaction",
+			Style: discordgo.LinkButton,
+			URL:   c.TxURL,
+		})
+	}
+	if alert.RuleID != 0 {
+		buttons = append(buttons, discordgo.Button{
+			Label:    "Mute 1h",
+			Style:    discordgo.SecondaryButton,
+			CustomID: fmt.Sprintf("mute-rule:%d", alert.RuleID),
+		})
+	}
+	if alert.AckID != 0 {
+		buttons = append(buttons, discordgo.Button{
+			Label:    "Acknowledge",
+			Style:    discordgo.PrimaryButton,
+			CustomID: fmt.Sprintf("ack-alert:%d", alert.AckID),
+		})
+	}
+	msg := &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{embed}}
+	if len(buttons) > 0 {
+		msg.Components = []discordgo.MessageComponent{discordgo.ActionsRow{Components: buttons}}
+	}
+	return msg
+}
+
+// color returns the color of the embeds of a severity.
+func color(sev model.Severity) int {
+	switch sev {
+	case model.SeverityCritical:
+		return 0xd32f2f
+	case model.SeverityWarning:
+		return 0xf9a825
+	default:
+		return 0x1976d2
+	}
+}
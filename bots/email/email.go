@@ -0,0 +1,192 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/strahe/suialert/bots"
+	"github.com/strahe/suialert/config"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
+	"github.com/strahe/suialert/types"
+	"go.uber.org/zap"
+)
+
+// Notifier sends the alerts by email to the verified address of their user, or to the address of their destination.
+// With a digest interval, the alerts of a recipient are batched into one email per interval.
+type Notifier struct {
+	cfg         config.EmailBotConfig
+	explorer    bots.Explorer
+	from        *mail.Address
+	userService *service.UserService
+
+	// alerts waiting for the next digest, by recipient
+	lk      sync.Mutex
+	pending map[string][]*model.Alert
+	since   time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewEmail(cfg config.EmailBotConfig, explorer bots.Explorer, userService *service.UserService) (*Notifier, error) {
+	switch cfg.TLS {
+	case "starttls", "tls", "none":
+	default:
+		return nil, fmt.Errorf("invalid email tls %q, expected starttls, tls or none", cfg.TLS)
+	}
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid email sender %q: %s", cfg.From, err)
+	}
+	return &Notifier{
+		cfg:         cfg,
+		explorer:    explorer,
+		from:        from,
+		userService: userService,
+		pending:     map[string][]*model.Alert{},
+		since:       time.Now(),
+	}, nil
+}
+
+// Run starts sending the digests.
+func (n *Notifier) Run(context.Context) error {
+	if n.cfg.Digest <= 0 {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	n.cancel = cancel
+	n.done = make(chan struct{})
+	go func() {
+		defer close(n.done)
+		ticker := time.NewTicker(n.cfg.Digest)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				n.Flush(ctx)
+			}
+		}
+	}()
+	return nil
+}
+
+// Close sends the pending digests.
+func (n *Notifier) Close(ctx context.Context) error {
+	if n.cancel == nil {
+		return nil
+	}
+	n.cancel()
+	<-n.done
+	n.Flush(ctx)
+	return nil
+}
+
+func (n *Notifier) Name() string {
+	return "email"
+}
+
+func (n *Notifier) Capabilities() bots.Capability {
+	return bots.RichFormatting
+}
+
+// Notify sends the alert, or adds it to the next digest of its recipient.
+func (n *Notifier) Notify(ctx context.Context, alert *model.Alert) error {
+	to := alert.Destination
+	if to == "" {
+		u, err := n.userService.FindByID(alert.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to find user %d: %w", alert.UserID, err)
+		}
+		if to = u.VerifiedEmail(); to == "" {
+			return fmt.Errorf("user %d has no verified email: %w", u.ID, bots.ErrUnreachable)
+		}
+	}
+	if n.cfg.Digest > 0 {
+		a := *alert
+		n.lk.Lock()
+		n.pending[to] = append(n.pending[to], &a)
+		n.lk.Unlock()
+		return nil
+	}
+	return n.SendAlert(ctx, to, alert)
+}
+
+// Flush sends the pending digests.
+func (n *Notifier) Flush(ctx context.Context) {
+	n.lk.Lock()
+	pending, since := n.pending, n.since
+	n.pending = map[string][]*model.Alert{}
+	n.since = time.Now()
+	n.lk.Unlock()
+	for to, alerts := range pending {
+		if err := n.SendDigest(ctx, to, since, alerts); err != nil {
+			zap.S().Errorf("failed to send a digest of %d alerts to %s: %s", len(alerts), to, err)
+		}
+	}
+}
+
+// SendAlert sends an email about an alert.
+func (n *Notifier) SendAlert(ctx context.Context, to string, alert *model.Alert) error {
+	c := bots.NewContent(alert, n.explorer)
+	subject := c.Title
+	if alert.Address != (types.Address{}) {
+		subject += " on " + alert.Address.Hex()
+	}
+	msg, err := n.message(to, subject, "alert", c)
+	if err != nil {
+		return err
+	}
+	return n.send(ctx, to, msg)
+}
+
+// SendDigest sends an email about several alerts received since a time.
+func (n *Notifier) SendDigest(ctx context.Context, to string, since time.Time, alerts []*model.Alert) error {
+	if len(alerts) == 0 {
+		return nil
+	}
+	d := &digest{Count: len(alerts), Since: since}
+	counts := map[model.Severity]int{}
+	for _, a := range alerts {
+		d.Alerts = append(d.Alerts, bots.NewContent(a, n.explorer))
+		counts[a.Severity]++
+	}
+	var summary []string
+	for _, s := range []model.Severity{model.SeverityCritical, model.SeverityWarning, model.SeverityInfo} {
+		if counts[s] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[s], s))
+		}
+	}
+	d.Summary = strings.Join(summary, ", ")
+	msg, err := n.message(to, fmt.Sprintf("%d alerts: %s", d.Count, d.Summary), "digest", d)
+	if err != nil {
+		return err
+	}
+	return n.send(ctx, to, msg)
+}
+
+// SendVerification sends the code to verify an email address with.
+func (n *Notifier) SendVerification(ctx context.Context, to, code string) error {
+	msg, err := n.plainMessage(to, "Verify your email address", "verify.txt", map[string]string{
+		"Code":    code,
+		"Address": to,
+	})
+	if err != nil {
+		return err
+	}
+	return n.send(ctx, to, msg)
+}
+
+// digest is the data of the digest templates.
+type digest struct {
+	Count   int
+	Since   time.Time
+	Summary string
+	Alerts  []*bots.Content
+}
@@ -0,0 +1,324 @@
+package email_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/strahe/suialert/bots"
+	"github.com/strahe/suialert/bots/email"
+	"github.com/strahe/suialert/config"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
+	"github.com/strahe/suialert/types"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// received is a mail accepted by the smtp stand-in.
+type received struct {
+	from, to string
+	msg      *mail.Message
+}
+
+// smtpServer is a local smtp stand-in accepting the mails of an authenticated user.
+type smtpServer struct {
+	t          *testing.T
+	ln         net.Listener
+	user, pass string
+
+	lk    sync.Mutex
+	mails []received
+}
+
+func newSMTPServer(t *testing.T, user, pass string) *smtpServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{t: t, ln: ln, user: user, pass: pass}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { _ = ln.Close() })
+	return s
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close() // nolint: errcheck
+	tp := textproto.NewConn(conn)
+	reply := func(lines ...string) {
+		for _, l := range lines {
+			_ = tp.PrintfLine("%s", l)
+		}
+	}
+	reply("220 localhost ESMTP stand-in")
+	var (
+		authed   bool
+		from, to string
+	)
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			reply("250-localhost", "250 AUTH PLAIN")
+		case "AUTH":
+			mech, b64, _ := strings.Cut(arg, " ")
+			cred, _ := base64.StdEncoding.DecodeString(b64)
+			if mech == "PLAIN" && string(cred) == "\x00"+s.user+"\x00"+s.pass {
+				authed = true
+				reply("235 authenticated")
+			} else {
+				reply("535 invalid credentials")
+			}
+		case "MAIL":
+			if !authed {
+				reply("530 authentication required")
+				continue
+			}
+			from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			reply("250 ok")
+		case "RCPT":
+			to = strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>")
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg, err := mail.ReadMessage(bytes.NewReader(data))
+			if err != nil {
+				s.t.Errorf("invalid message: %s", err)
+			}
+			s.lk.Lock()
+			s.mails = append(s.mails, received{from: from, to: to, msg: msg})
+			s.lk.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func (s *smtpServer) received() []received {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return append([]received(nil), s.mails...)
+}
+
+func (s *smtpServer) config() config.EmailBotConfig {
+	_, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	cfg := config.EmailBotConfig{
+		Host:     "127.0.0.1",
+		Username: s.user,
+		Password: s.pass,
+		From:     "suialert <alerts@example.com>",
+		TLS:      "none",
+		Timeout:  5 * time.Second,
+	}
+	cfg.Port, _ = net.LookupPort("tcp", port)
+	return cfg
+}
+
+// parts returns the content of the parts of a multipart message, by content type.
+func parts(t *testing.T, msg *mail.Message) map[string]string {
+	mt, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mt != "multipart/alternative" {
+		t.Fatalf("unexpected content type %s: %v", msg.Header.Get("Content-Type"), err)
+	}
+	res := map[string]string{}
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			return res
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ct, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		res[ct] = string(b)
+	}
+}
+
+func newUserService(t *testing.T) *service.UserService {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := model.Migration(db); err != nil {
+		t.Fatal(err)
+	}
+	return service.NewUserService(db)
+}
+
+func testAlert(uid uint, digest string) *model.Alert {
+	return &model.Alert{
+		RuleID:   7,
+		UserID:   uid,
+		Event:    types.EventTypeCoinBalanceChange,
+		Address:  types.HexToAddress("0x2"),
+		Severity: model.SeverityCritical,
+		TxDigest: digest,
+	}
+}
+
+func TestNotifyVerifiedUser(t *testing.T) {
+	ctx := context.Background()
+	srv := newSMTPServer(t, "bob", "pa55")
+	usv := newUserService(t)
+	u := &model.User{Name: "bob"}
+	if err := usv.Create(u); err != nil {
+		t.Fatal(err)
+	}
+	n, err := email.NewEmail(srv.config(), bots.Explorer{}, usv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// unverified addresses are not used
+	code, err := usv.SetEmail(u, "Bob <bob@example.com>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(ctx, testAlert(u.ID, "tx1")); !errors.Is(err, bots.ErrUnreachable) {
+		t.Fatalf("err = %v, want %v", err, bots.ErrUnreachable)
+	}
+	if err := usv.VerifyEmail(u, "not the code"); !errors.Is(err, service.ErrInvalidCode) {
+		t.Fatalf("err = %v, want %v", err, service.ErrInvalidCode)
+	}
+	if err := usv.VerifyEmail(u, code); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.Notify(ctx, testAlert(u.ID, "<tx2>")); err != nil {
+		t.Fatalf("notify: %s", err)
+	}
+	mails := srv.received()
+	if len(mails) != 1 {
+		t.Fatalf("got %d mails, want 1", len(mails))
+	}
+	m := mails[0]
+	if m.from != "alerts@example.com" || m.to != "bob@example.com" {
+		t.Errorf("unexpected envelope from %s to %s", m.from, m.to)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(m.msg.Header.Get("Subject"))
+	if err != nil || subject != "[CRITICAL] CoinBalanceChange on "+types.HexToAddress("0x2").Hex() {
+		t.Errorf("unexpected subject %q: %v", subject, err)
+	}
+	ps := parts(t, m.msg)
+	if !strings.Contains(ps["text/plain"], "Transaction: <tx2>") {
+		t.Errorf("unexpected text part:\n%s", ps["text/plain"])
+	}
+	if !strings.Contains(ps["text/html"], "<code>&lt;tx2&gt;</code>") {
+		t.Errorf("unexpected html part:\n%s", ps["text/html"])
+	}
+}
+
+func TestDigest(t *testing.T) {
+	ctx := context.Background()
+	srv := newSMTPServer(t, "bob", "pa55")
+	cfg := srv.config()
+	cfg.Digest = time.Hour
+	n, err := email.NewEmail(cfg, bots.Explorer{}, newUserService(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, to := range []string{"ops@example.com", "ops@example.com", "dev@example.com"} {
+		alert := testAlert(0, "tx"+string(rune('a'+i)))
+		alert.Destination = to
+		if i == 1 {
+			alert.Severity = model.SeverityInfo
+		}
+		if err := n.Notify(ctx, alert); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(srv.received()) != 0 {
+		t.Fatal("alerts sent before the digest")
+	}
+	// the pending digests are sent when closed
+	if err := n.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	mails := srv.received()
+	if len(mails) != 2 {
+		t.Fatalf("got %d mails, want 2", len(mails))
+	}
+	for _, m := range mails {
+		if m.to != "ops@example.com" {
+			continue
+		}
+		if subject := m.msg.Header.Get("Subject"); subject != "2 alerts: 1 critical, 1 info" {
+			t.Errorf("unexpected subject %q", subject)
+		}
+		text := parts(t, m.msg)["text/plain"]
+		if !strings.Contains(text, "Transaction: txa") || !strings.Contains(text, "Transaction: txb") {
+			t.Errorf("unexpected text part:\n%s", text)
+		}
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	ctx := context.Background()
+	srv := newSMTPServer(t, "bob", "pa55")
+
+	cfg := srv.config()
+	cfg.Password = "wrong"
+	n, err := email.NewEmail(cfg, bots.Explorer{}, newUserService(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.SendVerification(ctx, "bob@example.com", "123456"); err == nil {
+		t.Error("sent with wrong credentials")
+	}
+
+	// the stand-in does not support STARTTLS
+	cfg = srv.config()
+	cfg.TLS = "starttls"
+	if n, err = email.NewEmail(cfg, bots.Explorer{}, newUserService(t)); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.SendVerification(ctx, "bob@example.com", "123456"); err == nil {
+		t.Error("sent without STARTTLS")
+	}
+	if len(srv.received()) != 0 {
+		t.Errorf("got %d mails, want none", len(srv.received()))
+	}
+}
@@ -0,0 +1,109 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var (
+	//go:embed templates
+	templates embed.FS
+
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templates, "templates/*.html"))
+	textTemplates = template.Must(template.ParseFS(templates, "templates/*.txt"))
+)
+
+// message renders the html and the text templates of a name into a multipart email.
+func (n *Notifier) message(to, subject, name string, data any) ([]byte, error) {
+	var text, html bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s.txt: %w", name, err)
+	}
+	if err := htmlTemplates.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s.html: %w", name, err)
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		// the last part is the preferred one
+		{"text/plain; charset=utf-8", text.Bytes()},
+		{"text/html; charset=utf-8", html.Bytes()},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return n.envelope(to, subject, "multipart/alternative; boundary="+mw.Boundary(), body.Bytes())
+}
+
+// plainMessage renders a text template into an email.
+func (n *Notifier) plainMessage(to, subject, name string, data any) ([]byte, error) {
+	var text, body bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&text, name, data); err != nil {
+		return nil, fmt.Errorf("failed to render %s: %w", name, err)
+	}
+	if err := writeQuotedPrintable(&body, text.Bytes()); err != nil {
+		return nil, err
+	}
+	return n.envelope(to, subject, "text/plain; charset=utf-8", body.Bytes(), "Content-Transfer-Encoding", "quoted-printable")
+}
+
+// envelope adds the headers of an email to its body, extra is a list of header names and values.
+func (n *Notifier) envelope(to, subject, contentType string, body []byte, extra ...string) ([]byte, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	domain := n.from.Address[strings.LastIndex(n.from.Address, "@")+1:]
+	headers := []string{
+		"From", n.from.String(),
+		"To", to,
+		"Subject", mime.QEncoding.Encode("utf-8", subject),
+		"Date", time.Now().Format(time.RFC1123Z),
+		"Message-ID", fmt.Sprintf("<%s@%s>", hex.EncodeToString(id), domain),
+		"MIME-Version", "1.0",
+		"Content-Type", contentType,
+	}
+	headers = append(headers, extra...)
+
+	var msg bytes.Buffer
+	for i := 0; i < len(headers); i += 2 {
+		msg.WriteString(headers[i] + ": " + headers[i+1] + "\r\n")
+	}
+	msg.WriteString("\r\n")
+	msg.Write(body)
+	return msg.Bytes(), nil
+}
+
+func writeQuotedPrintable(w interface{ Write([]byte) (int, error) }, content []byte) error {
+	qw := quotedprintable.NewWriter(w)
+	if _, err := qw.Write(content); err != nil {
+		return err
+	}
+	return qw.Close()
+}
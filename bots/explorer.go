@@ -0,0 +1,35 @@
+package bots
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Explorer links the transactions and the addresses of the alerts to a Sui explorer.
+// The zero value has no links.
+type Explorer struct {
+	URL     string
+	Network string
+}
+
+// TxURL returns the page of a transaction, empty without a digest.
+func (e Explorer) TxURL(digest string) string {
+	return e.link("txblock", digest)
+}
+
+// AddressURL returns the page of an address.
+func (e Explorer) AddressURL(addr string) string {
+	return e.link("address", addr)
+}
+
+func (e Explorer) link(kind, id string) string {
+	if e.URL == "" || id == "" {
+		return ""
+	}
+	u := fmt.Sprintf("%s/%s/%s", strings.TrimRight(e.URL, "/"), kind, url.PathEscape(id))
+	if e.Network != "" {
+		u += "?network=" + url.QueryEscape(e.Network)
+	}
+	return u
+}
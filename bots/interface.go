@@ -2,7 +2,10 @@ package bots
 
 import (
 	"context"
+	"errors"
 	"time"
+
+	"github.com/strahe/suialert/model"
 )
 
 type Bot interface {
@@ -10,6 +13,46 @@ type Bot interface {
 	Close(ctx context.Context) error
 }
 
+// Capability is a feature of the messages of a notifier.
+type Capability uint
+
+const (
+	// RichFormatting is markdown or html in messages
+	RichFormatting Capability = 1 << iota
+	// Buttons are interactive components attached to messages
+	Buttons
+	// Attachments are files attached to messages
+	Attachments
+)
+
+// Has reports whether all the capabilities of f are in c.
+func (c Capability) Has(f Capability) bool {
+	return c&f == f
+}
+
+// ErrUnreachable is returned by notifiers which have no way to reach the recipient of an alert,
+// e.g. a user without an account on their platform.
+var ErrUnreachable = errors.New("recipient unreachable")
+
+// Notifier delivers alerts to their user, or to their destination channel when set.
+type Notifier interface {
+	// Name of the notifier, it prefixes the channels of its platform in the destinations, e.g. "discord:1234"
+	Name() string
+	Capabilities() Capability
+	Notify(ctx context.Context, alert *model.Alert) error
+}
+
+// Verifier sends the codes the users verify their email address with.
+type Verifier interface {
+	SendVerification(ctx context.Context, to, code string) error
+}
+
+// Backend is a bot delivering alerts.
+type Backend interface {
+	Bot
+	Notifier
+}
+
 type T struct {
 	Id            string `json:"id"`
 	ApplicationId string `json:"application_id"`
@@ -0,0 +1,134 @@
+package bots
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/strahe/suialert/model"
+	"go.uber.org/zap"
+)
+
+// Registry runs several notifier backends at once and routes the alerts to them.
+// Alerts to a user are sent by every backend the user can be reached with.
+// Alerts to a destination are sent by the backend named by its prefix, e.g. "telegram:-1001234",
+// or by the first backend without a prefix.
+type Registry struct {
+	backends []Backend
+}
+
+func NewRegistry(backends ...Backend) *Registry {
+	return &Registry{backends: backends}
+}
+
+// Register adds a backend, the first one receives the destinations without a prefix.
+func (r *Registry) Register(b Backend) {
+	r.backends = append(r.backends, b)
+}
+
+// Backends returns the registered backends.
+func (r *Registry) Backends() []Backend {
+	return r.backends
+}
+
+// Backend returns the backend with a name, nil if not registered.
+func (r *Registry) Backend(name string) Backend {
+	for _, b := range r.backends {
+		if b.Name() == name {
+			return b
+		}
+	}
+	return nil
+}
+
+func (r *Registry) Run(ctx context.Context) error {
+	for i, b := range r.backends {
+		if err := b.Run(ctx); err != nil {
+			for _, started := range r.backends[:i] {
+				if err := started.Close(ctx); err != nil {
+					zap.S().Errorf("failed to close %s: %s", started.Name(), err)
+				}
+			}
+			return fmt.Errorf("%s: %w", b.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (r *Registry) Close(ctx context.Context) error {
+	var errs []error
+	for _, b := range r.backends {
+		if err := b.Close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", b.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Registry) Name() string {
+	return "registry"
+}
+
+// Capabilities returns the capabilities shared by all the backends.
+func (r *Registry) Capabilities() Capability {
+	if len(r.backends) == 0 {
+		return 0
+	}
+	c := r.backends[0].Capabilities()
+	for _, b := range r.backends[1:] {
+		c &= b.Capabilities()
+	}
+	return c
+}
+
+func (r *Registry) Notify(ctx context.Context, alert *model.Alert) error {
+	if len(r.backends) == 0 {
+		return fmt.Errorf("no notifier enabled")
+	}
+	if alert.Destination != "" {
+		name, channel := ParseDestination(alert.Destination)
+		b := r.backends[0]
+		if name != "" {
+			if b = r.Backend(name); b == nil {
+				return fmt.Errorf("unknown notifier %q in destination %s", name, alert.Destination)
+			}
+		}
+		routed := *alert
+		routed.Destination = channel
+		return b.Notify(ctx, &routed)
+	}
+
+	var (
+		delivered bool
+		errs      []error
+	)
+	for _, b := range r.backends {
+		err := b.Notify(ctx, alert)
+		switch {
+		case err == nil:
+			delivered = true
+		case errors.Is(err, ErrUnreachable):
+		default:
+			errs = append(errs, fmt.Errorf("%s: %w", b.Name(), err))
+		}
+	}
+	if delivered {
+		for _, err := range errs {
+			zap.S().Errorf("failed to notify user %d: %s", alert.UserID, err)
+		}
+		return nil
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("user %d: %w by any notifier", alert.UserID, ErrUnreachable)
+	}
+	return errors.Join(errs...)
+}
+
+// ParseDestination splits a destination into the name of its backend, empty if not set, and its channel.
+func ParseDestination(dest string) (backend, channel string) {
+	if name, ch, ok := strings.Cut(dest, ":"); ok {
+		return name, ch
+	}
+	return "", dest
+}
@@ -0,0 +1,121 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/strahe/suialert/bots"
+	"github.com/strahe/suialert/config"
+	"github.com/strahe/suialert/model"
+)
+
+// https://api.slack.com/reference/block-kit/blocks
+type block map[string]any
+
+type message struct {
+	// Text is shown in the notifications of slack
+	Text   string  `json:"text"`
+	Blocks []block `json:"blocks"`
+}
+
+// Notifier posts the alerts to slack incoming webhooks formatted with Block Kit.
+// Slack has no user accounts known by suialert, so only the alerts to a channel are delivered.
+type Notifier struct {
+	cfg      config.IncomingBotConfig
+	explorer bots.Explorer
+	client   *http.Client
+}
+
+func NewSlack(cfg config.IncomingBotConfig, explorer bots.Explorer) *Notifier {
+	return &Notifier{
+		cfg:      cfg,
+		explorer: explorer,
+		client:   &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+func (n *Notifier) Run(context.Context) error {
+	return nil
+}
+
+func (n *Notifier) Close(context.Context) error {
+	return nil
+}
+
+func (n *Notifier) Name() string {
+	return "slack"
+}
+
+func (n *Notifier) Capabilities() bots.Capability {
+	return bots.RichFormatting
+}
+
+// Notify posts the alert to the incoming webhook of its destination channel.
+func (n *Notifier) Notify(ctx context.Context, alert *model.Alert) error {
+	if alert.Destination == "" {
+		return fmt.Errorf("slack has no direct messages: %w", bots.ErrUnreachable)
+	}
+	url, ok := n.cfg.Channels[strings.ToLower(alert.Destination)]
+	if !ok {
+		return fmt.Errorf("unknown slack channel %s: %w", alert.Destination, bots.ErrUnreachable)
+	}
+	return bots.PostJSON(ctx, n.client, url, formatAlert(alert, n.explorer))
+}
+
+func formatAlert(alert *model.Alert, explorer bots.Explorer) *message {
+	c := bots.NewContent(alert, explorer)
+	msg := &message{Text: c.Emoji + " " + c.Title}
+	if c.Escalated != "" {
+		msg.Blocks = append(msg.Blocks, contextBlock("*"+escape(c.Escalated)+"*"))
+	}
+	msg.Blocks = append(msg.Blocks, block{
+		"type": "header",
+		"text": block{"type": "plain_text", "text": c.Emoji + " " + c.Title, "emoji": true},
+	})
+	var fields []block
+	for _, f := range c.Fields {
+		v := escape(f.Value)
+		if f.Code {
+			v = "`" + v + "`"
+		}
+		if f.Link != "" {
+			v = "<" + f.Link + "|" + v + ">"
+		}
+		fields = append(fields, block{"type": "mrkdwn", "text": fmt.Sprintf("*%s*\n%s", escape(f.Name), v)})
+	}
+	// a section has at most 10 fields
+	for len(fields) > 0 {
+		k := len(fields)
+		if k > 10 {
+			k = 10
+		}
+		msg.Blocks = append(msg.Blocks, block{"type": "section", "fields": fields[:k]})
+		fields = fields[k:]
+	}
+	if c.Table != nil {
+		msg.Blocks = append(msg.Blocks, block{
+			"type": "section",
+			"text": block{"type": "mrkdwn", "text": "```" + escape(c.Rows(5)) + "```"},
+		})
+	}
+	if c.Footer != "" {
+		msg.Blocks = append(msg.Blocks, contextBlock(escape(c.Footer)))
+	}
+	return msg
+}
+
+// contextBlock is a block of small text.
+func contextBlock(text string) block {
+	return block{
+		"type":     "context",
+		"elements": []block{{"type": "mrkdwn", "text": text}},
+	}
+}
+
+// escape escapes the control characters of slack mrkdwn.
+// https://api.slack.com/reference/surfaces/formatting#escaping
+func escape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
@@ -0,0 +1,122 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/strahe/suialert/bots"
+	"github.com/strahe/suialert/config"
+	"github.com/strahe/suialert/model"
+)
+
+// https://adaptivecards.io/explorer/
+type element map[string]any
+
+type attachment struct {
+	ContentType string  `json:"contentType"`
+	Content     element `json:"content"`
+}
+
+type message struct {
+	Type        string       `json:"type"`
+	Attachments []attachment `json:"attachments"`
+}
+
+// Notifier posts the alerts to microsoft teams incoming webhooks as Adaptive Cards.
+// Teams has no user accounts known by suialert, so only the alerts to a channel are delivered.
+type Notifier struct {
+	cfg      config.IncomingBotConfig
+	explorer bots.Explorer
+	client   *http.Client
+}
+
+func NewTeams(cfg config.IncomingBotConfig, explorer bots.Explorer) *Notifier {
+	return &Notifier{
+		cfg:      cfg,
+		explorer: explorer,
+		client:   &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+func (n *Notifier) Run(context.Context) error {
+	return nil
+}
+
+func (n *Notifier) Close(context.Context) error {
+	return nil
+}
+
+func (n *Notifier) Name() string {
+	return "teams"
+}
+
+func (n *Notifier) Capabilities() bots.Capability {
+	return bots.RichFormatting
+}
+
+// Notify posts the alert to the incoming webhook of its destination channel.
+func (n *Notifier) Notify(ctx context.Context, alert *model.Alert) error {
+	if alert.Destination == "" {
+		return fmt.Errorf("teams has no direct messages: %w", bots.ErrUnreachable)
+	}
+	url, ok := n.cfg.Channels[strings.ToLower(alert.Destination)]
+	if !ok {
+		return fmt.Errorf("unknown teams channel %s: %w", alert.Destination, bots.ErrUnreachable)
+	}
+	return bots.PostJSON(ctx, n.client, url, formatAlert(alert, n.explorer))
+}
+
+func formatAlert(alert *model.Alert, explorer bots.Explorer) *message {
+	c := bots.NewContent(alert, explorer)
+	var body []element
+	if c.Escalated != "" {
+		body = append(body, element{"type": "TextBlock", "text": c.Escalated, "weight": "Bolder", "color": "Attention", "wrap": true})
+	}
+	body = append(body, element{
+		"type":   "TextBlock",
+		"text":   c.Emoji + " " + c.Title,
+		"size":   "Medium",
+		"weight": "Bolder",
+		"color":  color(alert.Severity),
+		"wrap":   true,
+	})
+	var facts []element
+	for _, f := range c.Fields {
+		v := f.Value
+		if f.Link != "" {
+			v = "[" + v + "](" + f.Link + ")"
+		}
+		facts = append(facts, element{"title": f.Name, "value": v})
+	}
+	body = append(body, element{"type": "FactSet", "facts": facts})
+	if c.Table != nil {
+		body = append(body, element{"type": "TextBlock", "text": c.Rows(5), "fontType": "Monospace", "wrap": true})
+	}
+	if c.Footer != "" {
+		body = append(body, element{"type": "TextBlock", "text": c.Footer, "isSubtle": true, "size": "Small", "wrap": true})
+	}
+	return &message{
+		Type: "message",
+		Attachments: []attachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content: element{
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type":    "AdaptiveCard",
+				"version": "1.4",
+				"body":    body,
+			},
+		}},
+	}
+}
+
+func color(s model.Severity) string {
+	switch s {
+	case model.SeverityWarning:
+		return "Warning"
+	case model.SeverityCritical:
+		return "Attention"
+	}
+	return "Accent"
+}
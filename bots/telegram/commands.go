@@ -0,0 +1,304 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
+	"github.com/strahe/suialert/types"
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v3"
+)
+
+var (
+	commands = []tele.Command{
+		{Text: "start", Description: "Register to receive alerts"},
+		{Text: "add", Description: "Add a new address to the alert list"},
+		{Text: "list", Description: "List and remove your alerts"},
+		{Text: "cancel", Description: "Cancel adding an alert"},
+		{Text: "ack", Description: "Acknowledge an alert, so that it is not escalated"},
+		{Text: "email", Description: "Receive your alerts by email"},
+		{Text: "verify", Description: "Verify your email address with the code sent to it"},
+	}
+
+	events = []types.EventType{
+		types.EventTypeMove,
+		types.EventTypePublish,
+		types.EventTypeCoinBalanceChange,
+		types.EventTypeTransferObject,
+		types.EventTypeNewObject,
+		types.EventTypeDeleteObject,
+		types.EventTypeMutateObject,
+	}
+
+	severities = []model.Severity{model.SeverityInfo, model.SeverityWarning, model.SeverityCritical}
+
+	// unique ids of the inline buttons, their data is the chosen value
+	btnEvent    = tele.Btn{Unique: "event"}
+	btnSeverity = tele.Btn{Unique: "severity"}
+	btnRemove   = tele.Btn{Unique: "remove"}
+)
+
+const help = `Commands:
+/add - add a new address to the alert list
+/list - list and remove your alerts
+/cancel - cancel adding an alert
+/ack &lt;id&gt; - acknowledge an alert, so that it is not escalated
+/email &lt;address&gt; - receive your alerts by email
+/verify &lt;code&gt; - verify your email address with the code sent to it`
+
+type step int
+
+const (
+	stepAddress step = iota
+	stepCondition
+	stepSeverity
+)
+
+// draft is an alert being added, it is created step by step from the messages of the user.
+type draft struct {
+	step      step
+	event     types.EventType
+	address   types.Address
+	condition string
+}
+
+func (b *Bot) addHandlers() {
+	b.bot.Handle("/start", b.handleStart)
+	b.bot.Handle("/add", b.handleAdd)
+	b.bot.Handle("/list", b.handleList)
+	b.bot.Handle("/cancel", b.handleCancel)
+	b.bot.Handle("/ack", b.handleAck)
+	b.bot.Handle("/email", b.handleEmail)
+	b.bot.Handle("/verify", b.handleVerify)
+	b.bot.Handle(&btnEvent, b.handleSelectedEvent)
+	b.bot.Handle(&btnSeverity, b.handleSelectedSeverity)
+	b.bot.Handle(&btnRemove, b.handleRemove)
+	b.bot.Handle(tele.OnText, b.handleText)
+}
+
+func (b *Bot) handleStart(c tele.Context) error {
+	if _, err := b.findOrCreateUser(c); err != nil {
+		zap.S().Errorf("failed to register telegram user: %s", err)
+		return c.Send("Failed to register, please try again later")
+	}
+	return c.Send("You are registered, alerts will be sent to this chat.\n\n" + help)
+}
+
+func (b *Bot) handleAdd(c tele.Context) error {
+	if _, err := b.findOrCreateUser(c); err != nil {
+		zap.S().Errorf("failed to register telegram user: %s", err)
+		return c.Send("Failed to register, please try again later")
+	}
+	menu := &tele.ReplyMarkup{}
+	var rows []tele.Row
+	for _, e := range events {
+		rows = append(rows, menu.Row(menu.Data(e.Emoji()+" "+string(e), btnEvent.Unique, string(e))))
+	}
+	menu.Inline(rows...)
+	return c.Send("Which type of event would you like to monitor?", menu)
+}
+
+func (b *Bot) handleSelectedEvent(c tele.Context) error {
+	event := types.EventType(c.Data())
+	if !event.Known() {
+		return c.Respond(&tele.CallbackResponse{Text: "Unknown event"})
+	}
+	b.lk.Lock()
+	b.drafts[c.Chat().ID] = &draft{step: stepAddress, event: event}
+	b.lk.Unlock()
+	if err := c.Respond(); err != nil {
+		return err
+	}
+	return c.Edit(fmt.Sprintf("Alert on <b>%s</b>\nWhat is the SUI address you like to monitor?", event))
+}
+
+// handleText reads the next field of the draft of the chat.
+func (b *Bot) handleText(c tele.Context) error {
+	text := strings.TrimSpace(c.Text())
+	b.lk.Lock()
+	d, ok := b.drafts[c.Chat().ID]
+	var reply string
+	var menu *tele.ReplyMarkup
+	switch {
+	case !ok:
+		reply = help
+	case d.step == stepAddress:
+		if !types.IsHexAddress(text) {
+			reply = "Invalid address, please send a hex address like 0x2"
+			break
+		}
+		d.address = types.HexToAddress(text)
+		d.step = stepCondition
+		reply = "Please enter the monitoring rules!\nFor example <code>Event.AmountGreaterThan(\"100\")</code>"
+	case d.step == stepCondition:
+		d.condition = text
+		d.step = stepSeverity
+		reply = "How severe are the alerts?"
+		menu = &tele.ReplyMarkup{}
+		var btns []tele.Btn
+		for _, s := range severities {
+			btns = append(btns, menu.Data(s.Emoji()+" "+string(s), btnSeverity.Unique, string(s)))
+		}
+		menu.Inline(menu.Row(btns...))
+	default:
+		reply = "Please choose the severity of the alerts"
+	}
+	b.lk.Unlock()
+	if menu != nil {
+		return c.Send(reply, menu)
+	}
+	return c.Send(reply)
+}
+
+func (b *Bot) handleSelectedSeverity(c tele.Context) error {
+	b.lk.Lock()
+	d, ok := b.drafts[c.Chat().ID]
+	if ok && d.step == stepSeverity {
+		delete(b.drafts, c.Chat().ID)
+	}
+	b.lk.Unlock()
+	if !ok || d.step != stepSeverity {
+		return c.Respond(&tele.CallbackResponse{Text: "Use /add to add an alert"})
+	}
+	u, err := b.findOrCreateUser(c)
+	if err != nil {
+		return err
+	}
+	r := &model.Rule{
+		Address:   d.address,
+		Event:     d.event,
+		User:      *u,
+		Condition: d.condition,
+		Severity:  model.Severity(c.Data()),
+	}
+	if err := b.ruleService.Create(b.actorContext(c), r); err != nil {
+		zap.S().Infof("failed to create rule: %v", err)
+		_ = c.Respond()
+		return c.Edit("Failed to add the alert: " + html.EscapeString(err.Error()))
+	}
+	if err := c.Respond(); err != nil {
+		return err
+	}
+	return c.Edit(fmt.Sprintf("Alert %d added", r.ID))
+}
+
+func (b *Bot) handleCancel(c tele.Context) error {
+	b.lk.Lock()
+	delete(b.drafts, c.Chat().ID)
+	b.lk.Unlock()
+	return c.Send("Cancelled")
+}
+
+func (b *Bot) handleList(c tele.Context) error {
+	u, err := b.findOrCreateUser(c)
+	if err != nil {
+		return err
+	}
+	rules, err := b.ruleService.FindByUser(b.actorContext(c), u.ID)
+	if err != nil {
+		zap.S().Errorf("failed to find rules of user %d: %s", u.ID, err)
+		return c.Send("Failed to list your alerts, please try again later")
+	}
+	if len(rules) == 0 {
+		return c.Send("You have no alerts, use /add to add one")
+	}
+	var sb strings.Builder
+	menu := &tele.ReplyMarkup{}
+	var rows []tele.Row
+	for _, r := range rules {
+		sb.WriteString(fmt.Sprintf("%s <b>%d</b> %s on <code>%s</code>\n<code>%s</code>\n",
+			r.Severity.Emoji(), r.ID, r.Event, r.Address.Hex(), html.EscapeString(r.Condition)))
+		if r.Paused {
+			sb.WriteString("paused\n")
+		}
+		rows = append(rows, menu.Row(menu.Data(fmt.Sprintf("Remove %d", r.ID), btnRemove.Unique, fmt.Sprint(r.ID))))
+	}
+	menu.Inline(rows...)
+	return c.Send(sb.String(), menu)
+}
+
+func (b *Bot) handleRemove(c tele.Context) error {
+	u, err := b.findOrCreateUser(c)
+	if err != nil {
+		return err
+	}
+	id, err := strconv.ParseUint(c.Data(), 10, 64)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "Invalid alert"})
+	}
+	_, err = b.ruleService.DeleteOwned(b.actorContext(c), u.ID, uint(id))
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return c.Respond(&tele.CallbackResponse{Text: fmt.Sprintf("Alert %d not found", id)})
+	case err != nil:
+		zap.S().Errorf("failed to delete rule %d: %s", id, err)
+		return c.Respond(&tele.CallbackResponse{Text: "Failed to remove the alert, please try again later"})
+	}
+	return c.Respond(&tele.CallbackResponse{Text: fmt.Sprintf("Alert %d removed", id)})
+}
+
+func (b *Bot) handleAck(c tele.Context) error {
+	u, err := b.findOrCreateUser(c)
+	if err != nil {
+		return err
+	}
+	id, err := strconv.ParseUint(c.Message().Payload, 10, 64)
+	if err != nil {
+		return c.Send("Usage: /ack &lt;id&gt;")
+	}
+	ack, err := b.ackService.Acknowledge(b.actorContext(c), uint(id), u.ID)
+	switch {
+	case errors.Is(err, service.ErrNotFound), errors.Is(err, service.ErrForbidden):
+		return c.Send(fmt.Sprintf("Alert %d not found", id))
+	case err != nil:
+		zap.S().Errorf("failed to acknowledge alert %d: %s", id, err)
+		return c.Send("Failed to acknowledge the alert, please try again later")
+	}
+	return c.Send(fmt.Sprintf("Alert %d acknowledged by %s", id, html.EscapeString(ack.AckedBy)))
+}
+
+func (b *Bot) handleEmail(c tele.Context) error {
+	if b.verifier == nil {
+		return c.Send("Email alerts are not enabled")
+	}
+	u, err := b.findOrCreateUser(c)
+	if err != nil {
+		return err
+	}
+	address := strings.TrimSpace(c.Message().Payload)
+	if address == "" {
+		return c.Send("Usage: /email &lt;address&gt;")
+	}
+	code, err := b.userService.SetEmail(u, address)
+	if err != nil {
+		return c.Send(html.EscapeString(err.Error()))
+	}
+	if err := b.verifier.SendVerification(context.Background(), *u.Email, code); err != nil {
+		zap.S().Errorf("failed to send the verification code to %s: %s", *u.Email, err)
+		return c.Send("Failed to send the verification code, please check the address or try again later")
+	}
+	return c.Send(fmt.Sprintf("A verification code was sent to %s, send /verify &lt;code&gt; within an hour",
+		html.EscapeString(*u.Email)))
+}
+
+func (b *Bot) handleVerify(c tele.Context) error {
+	u, err := b.findOrCreateUser(c)
+	if err != nil {
+		return err
+	}
+	err = b.userService.VerifyEmail(u, c.Message().Payload)
+	switch {
+	case errors.Is(err, service.ErrInvalidCode):
+		return c.Send("Invalid or expired code, use /email to get a new one")
+	case err != nil:
+		zap.S().Errorf("failed to verify the email of user %d: %s", u.ID, err)
+		return c.Send("Failed to verify the email, please try again later")
+	}
+	return c.Send(fmt.Sprintf("Your alerts are also sent to %s", html.EscapeString(*u.Email)))
+}
@@ -0,0 +1,103 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/strahe/suialert/bots"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/types"
+	tele "gopkg.in/telebot.v3"
+)
+
+func (b *Bot) Name() string {
+	return "telegram"
+}
+
+func (b *Bot) Capabilities() bots.Capability {
+	return bots.RichFormatting | bots.Buttons | bots.Attachments
+}
+
+// Notify sends the alert to its destination chat,
+// or to the private chat of the owner of the rule.
+func (b *Bot) Notify(_ context.Context, alert *model.Alert) error {
+	var chat tele.ChatID
+	if alert.Destination != "" {
+		id, err := strconv.ParseInt(alert.Destination, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid telegram chat id: %s", alert.Destination)
+		}
+		chat = tele.ChatID(id)
+	} else {
+		u, err := b.userService.FindByID(alert.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to find user %d: %w", alert.UserID, err)
+		}
+		if u.TelegramID == nil {
+			return fmt.Errorf("user %d has no telegram account: %w", u.ID, bots.ErrUnreachable)
+		}
+		// the id of the private chat with a user is the id of the user
+		chat = tele.ChatID(*u.TelegramID)
+	}
+	_, err := b.bot.Send(chat, formatAlert(alert), tele.NoPreview)
+	return err
+}
+
+func formatAlert(alert *model.Alert) string {
+	if _, ok := alert.Data.(*types.Summary); ok {
+		return formatDigest(alert)
+	}
+	var sb strings.Builder
+	if alert.Escalated {
+		sb.WriteString(fmt.Sprintf("<b>Escalated</b>, not acknowledged within %s\n", alert.Escalation.After))
+	}
+	sb.WriteString(fmt.Sprintf("%s %s <b>[%s] %s</b>", alert.Severity.Emoji(), alert.Event.Emoji(),
+		strings.ToUpper(string(alert.Severity)), alert.Event))
+	if alert.Address != (types.Address{}) {
+		sb.WriteString(fmt.Sprintf(" on <code>%s</code>", alert.Address.Hex()))
+	}
+	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("Source: %s\n", alert.Source()))
+	switch d := alert.Data.(type) {
+	case *types.CoinBalanceChange:
+		sb.WriteString(fmt.Sprintf("Amount: %s\n", html.EscapeString(d.FormatAmount())))
+	case *types.Anomaly:
+		sb.WriteString(fmt.Sprintf("Unusual %s: %.2f in the last 24h, %.2f on average over %d days (z-score %.1f)\n",
+			d.Subject(), d.Value, d.Mean, d.Window, d.ZScore))
+	case *types.Inactivity:
+		last := "never"
+		if d.LastSeen != nil {
+			last = d.LastSeen.UTC().Format("2006-01-02 15:04 MST")
+		}
+		sb.WriteString(fmt.Sprintf("No %s within %s, last seen %s\n", strings.ReplaceAll(d.Check, "_", " "), d.Period, last))
+	case *types.QueryResult:
+		sb.WriteString(fmt.Sprintf("%s: %g %s %g\n", html.EscapeString(d.Name), d.Value, html.EscapeString(d.Op), d.Threshold))
+	}
+	if alert.TxDigest != "" {
+		sb.WriteString(fmt.Sprintf("Transaction: <code>%s</code>\n", alert.TxDigest))
+	}
+	if alert.AckID != 0 && !alert.Escalated {
+		sb.WriteString(fmt.Sprintf("Acknowledge with /ack %d within %s, or it is escalated\n", alert.AckID, alert.Escalation.After))
+	} else if alert.AckID != 0 {
+		sb.WriteString(fmt.Sprintf("Acknowledge with /ack %d\n", alert.AckID))
+	}
+	return sb.String()
+}
+
+// formatDigest formats a digest in the layout shared by the notifiers.
+func formatDigest(alert *model.Alert) string {
+	c := bots.NewContent(alert, bots.Explorer{})
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s <b>%s</b>\n", c.Emoji, html.EscapeString(c.Title)))
+	for _, f := range c.Fields {
+		v := html.EscapeString(f.Value)
+		if f.Code {
+			v = "<code>" + v + "</code>"
+		}
+		sb.WriteString(fmt.Sprintf("%s: %s\n", html.EscapeString(f.Name), v))
+	}
+	return sb.String()
+}
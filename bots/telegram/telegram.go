@@ -0,0 +1,108 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/strahe/suialert/bots"
+	"github.com/strahe/suialert/config"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
+	"go.uber.org/zap"
+	tele "gopkg.in/telebot.v3"
+)
+
+type Bot struct {
+	cfg     config.TelegramBotConfig
+	bot     *tele.Bot
+	running bool
+
+	userService *service.UserService
+	ruleService *service.RuleService
+	ackService  *service.AckService
+	// verifier is nil when email is disabled
+	verifier bots.Verifier
+
+	// alerts being added, by chat
+	lk     sync.Mutex
+	drafts map[int64]*draft
+}
+
+func NewTelegram(cfg config.TelegramBotConfig, userService *service.UserService,
+	ruleService *service.RuleService, ackService *service.AckService, verifier bots.Verifier) (*Bot, error) {
+	// the token is checked when the bot runs
+	tb, err := tele.NewBot(tele.Settings{
+		Token:     cfg.Token,
+		Poller:    &tele.LongPoller{Timeout: 10 * time.Second},
+		ParseMode: tele.ModeHTML,
+		Offline:   true,
+		OnError: func(err error, c tele.Context) {
+			zap.S().Errorf("telegram: %s", err)
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bot: %s", err)
+	}
+	bot := &Bot{
+		cfg:         cfg,
+		bot:         tb,
+		userService: userService,
+		ruleService: ruleService,
+		ackService:  ackService,
+		verifier:    verifier,
+		drafts:      map[int64]*draft{},
+	}
+	bot.addHandlers()
+	return bot, nil
+}
+
+func (b *Bot) Run(context.Context) error {
+	data, err := b.bot.Raw("getMe", nil)
+	if err != nil {
+		return fmt.Errorf("failed to get bot info: %s", err)
+	}
+	var resp struct {
+		Result *tele.User `json:"result"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return fmt.Errorf("failed to get bot info: %s", err)
+	}
+	b.bot.Me = resp.Result
+	if err := b.bot.SetCommands(commands); err != nil {
+		return fmt.Errorf("failed to set commands: %s", err)
+	}
+	go b.bot.Start()
+	b.running = true
+	return nil
+}
+
+// Close closes the bot.
+func (b *Bot) Close(_ context.Context) error {
+	zap.S().Info("closing telegram bot")
+	if b.running {
+		b.bot.Stop()
+	}
+	return nil
+}
+
+func (b *Bot) findOrCreateUser(c tele.Context) (*model.User, error) {
+	if c.Sender() == nil {
+		return nil, fmt.Errorf("failed to find user id")
+	}
+	return b.userService.FindOrCreateByTelegramUser(c.Sender())
+}
+
+// actorContext returns a context recording the sender as the author of changes.
+func (b *Bot) actorContext(c tele.Context) context.Context {
+	actor := model.Actor{Source: model.SourceTelegram}
+	if u := c.Sender(); u != nil {
+		actor.Name = u.Username
+		if actor.Name == "" {
+			actor.Name = fmt.Sprint(u.ID)
+		}
+	}
+	return service.WithActor(context.Background(), actor)
+}
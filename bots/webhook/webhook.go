@@ -0,0 +1,219 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/strahe/suialert/bots"
+	"github.com/strahe/suialert/config"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
+	"go.uber.org/zap"
+)
+
+const (
+	HeaderDelivery  = "X-Suialert-Delivery"
+	HeaderEvent     = "X-Suialert-Event"
+	HeaderTimestamp = "X-Suialert-Timestamp"
+	// HeaderSignature is "sha256=" followed by the hex HMAC-SHA256 of the timestamp, a dot and the body,
+	// keyed with the secret of the webhook.
+	HeaderSignature = "X-Suialert-Signature"
+
+	// maxResponse is the size of the responses kept in the deliveries
+	maxResponse = 1024
+)
+
+// Payload is the body posted to the webhooks.
+type Payload struct {
+	DeliveryID string `json:"delivery_id"`
+	Network    string `json:"network"`
+	Source     string `json:"source"`
+	*model.Alert
+}
+
+// Notifier posts the alerts to the webhooks of their user, or to the webhook of their destination.
+// The requests are signed with the secret of the webhook, and every attempt is recorded.
+// Each webhook gets its own message of the outbox, which retries the failed deliveries
+// on network errors, 429 and 5xx responses.
+type Notifier struct {
+	cfg     config.WebhookBotConfig
+	network string
+	wsv     *service.WebhookService
+	osv     *service.OutboxService
+	client  *http.Client
+}
+
+func NewWebhook(cfg config.WebhookBotConfig, network string, wsv *service.WebhookService, osv *service.OutboxService) *Notifier {
+	return &Notifier{
+		cfg:     cfg,
+		network: network,
+		wsv:     wsv,
+		osv:     osv,
+		client:  &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+func (n *Notifier) Run(context.Context) error {
+	return nil
+}
+
+func (n *Notifier) Close(context.Context) error {
+	return nil
+}
+
+func (n *Notifier) Name() string {
+	return "webhook"
+}
+
+func (n *Notifier) Capabilities() bots.Capability {
+	return 0
+}
+
+// Notify posts the alert to the webhook of its destination, its id.
+// The alerts of a user are stored in the outbox once for each of their webhooks, routed to "webhook:<id>",
+// so that a webhook failing does not delay nor repeat the deliveries to the others.
+func (n *Notifier) Notify(ctx context.Context, alert *model.Alert) error {
+	if alert.Destination != "" {
+		id, err := strconv.ParseUint(alert.Destination, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid webhook id %s: %w", alert.Destination, bots.ErrUnreachable)
+		}
+		w, err := n.wsv.FindByID(ctx, uint(id))
+		if errors.Is(err, service.ErrNotFound) {
+			return fmt.Errorf("webhook %d: %w", id, bots.ErrUnreachable)
+		}
+		if err != nil {
+			return fmt.Errorf("webhook %d: %w", id, err)
+		}
+		if w.UserID != 0 && w.UserID != alert.UserID {
+			return fmt.Errorf("webhook %d is not owned by user %d: %w", w.ID, alert.UserID, bots.ErrUnreachable)
+		}
+		if w.Paused {
+			return fmt.Errorf("webhook %d is paused: %w", w.ID, bots.ErrUnreachable)
+		}
+		return n.Deliver(ctx, w, alert)
+	}
+
+	var hooks []model.Webhook
+	if alert.UserID != 0 {
+		ws, err := n.wsv.FindByUser(ctx, alert.UserID)
+		if err != nil {
+			return err
+		}
+		hooks = lo.Filter(ws, func(w model.Webhook, _ int) bool { return !w.Paused })
+	}
+	if len(hooks) == 0 {
+		return fmt.Errorf("user %d has no webhook: %w", alert.UserID, bots.ErrUnreachable)
+	}
+	for _, w := range hooks {
+		routed := *alert
+		routed.Destination = fmt.Sprintf("%s:%d", n.Name(), w.ID)
+		if _, err := n.osv.Enqueue(ctx, &routed); err != nil {
+			return fmt.Errorf("webhook %d: %w", w.ID, err)
+		}
+	}
+	return nil
+}
+
+// Deliver posts an alert to a webhook once, the outbox retries the failed deliveries.
+// The delivery id is the id of the outbox message being delivered, if any, so that it is the same
+// for the retries of an alert. The responses which are not worth retrying are ErrUnreachable.
+func (n *Notifier) Deliver(ctx context.Context, w *model.Webhook, alert *model.Alert) error {
+	id, attempt := "", 1
+	if m, ok := service.MessageFrom(ctx); ok {
+		id, attempt = strconv.FormatUint(uint64(m.ID), 10), m.Attempts+1
+	} else {
+		var err error
+		if id, err = newDeliveryID(); err != nil {
+			return err
+		}
+	}
+	body, err := json.Marshal(&Payload{
+		DeliveryID: id,
+		Network:    n.network,
+		Source:     alert.Source(),
+		Alert:      alert,
+	})
+	if err != nil {
+		return err
+	}
+
+	d := n.post(ctx, w, id, string(alert.Event), body)
+	d.Attempt = attempt
+	d.Source = alert.Source()
+	if err := n.wsv.RecordDelivery(ctx, d); err != nil {
+		zap.S().Errorf("failed to record delivery to webhook %d: %s", w.ID, err)
+	}
+	switch {
+	case d.Succeeded():
+		return nil
+	case d.Error != "":
+		return errors.New(d.Error)
+	case !retryable(d):
+		return fmt.Errorf("webhook %d rejected the alert with status %d: %w", w.ID, d.StatusCode, bots.ErrUnreachable)
+	}
+	return fmt.Errorf("unexpected status: %d", d.StatusCode)
+}
+
+func (n *Notifier) post(ctx context.Context, w *model.Webhook, id, event string, body []byte) *model.WebhookDelivery {
+	d := &model.WebhookDelivery{WebhookID: w.ID, DeliveryID: id}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "suialert-webhook")
+	req.Header.Set(HeaderDelivery, id)
+	req.Header.Set(HeaderEvent, event)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderSignature, Sign(w.Secret, ts, body))
+
+	start := time.Now()
+	resp, err := n.client.Do(req)
+	d.Duration = time.Since(start)
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	d.StatusCode = resp.StatusCode
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponse))
+	d.Response = string(b)
+	return d
+}
+
+// retryable reports whether a failed attempt may succeed later.
+func retryable(d *model.WebhookDelivery) bool {
+	return d.StatusCode == 0 || d.StatusCode == http.StatusTooManyRequests || d.StatusCode >= 500
+}
+
+// Sign returns the signature of a body sent at ts, the value of HeaderSignature.
+func Sign(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(ts, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newDeliveryID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
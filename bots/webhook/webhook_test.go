@@ -0,0 +1,216 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/strahe/suialert/bots"
+	"github.com/strahe/suialert/bots/webhook"
+	"github.com/strahe/suialert/config"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
+	"github.com/strahe/suialert/types"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// receiver is a local endpoint answering with the given statuses in turn, then 200.
+type receiver struct {
+	t        *testing.T
+	secret   string
+	statuses []int
+
+	lk       sync.Mutex
+	payloads []webhook.Payload
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		r.t.Error(err)
+		return
+	}
+	ts, err := strconv.ParseInt(req.Header.Get(webhook.HeaderTimestamp), 10, 64)
+	if err != nil {
+		r.t.Errorf("invalid timestamp: %s", err)
+	}
+	if got, want := req.Header.Get(webhook.HeaderSignature), webhook.Sign(r.secret, ts, body); got != want {
+		r.t.Errorf("signature = %s, want %s", got, want)
+	}
+	var p webhook.Payload
+	if err := json.Unmarshal(body, &p); err != nil {
+		r.t.Errorf("invalid payload: %s", err)
+	}
+	if req.Header.Get(webhook.HeaderDelivery) != p.DeliveryID {
+		r.t.Errorf("delivery header = %s, want %s", req.Header.Get(webhook.HeaderDelivery), p.DeliveryID)
+	}
+
+	r.lk.Lock()
+	r.payloads = append(r.payloads, p)
+	n := len(r.payloads)
+	r.lk.Unlock()
+	if n <= len(r.statuses) {
+		w.WriteHeader(r.statuses[n-1])
+		_, _ = w.Write([]byte("try again"))
+		return
+	}
+	_, _ = w.Write([]byte("ok"))
+}
+
+func newServices(t *testing.T) (*service.WebhookService, *service.OutboxService) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := model.Migration(db); err != nil {
+		t.Fatal(err)
+	}
+	return service.NewWebhookService(db), service.NewOutboxService(db)
+}
+
+func newNotifier(wsv *service.WebhookService, osv *service.OutboxService) *webhook.Notifier {
+	return webhook.NewWebhook(config.WebhookBotConfig{Timeout: time.Second}, "testnet", wsv, osv)
+}
+
+func testAlert(uid uint) *model.Alert {
+	return &model.Alert{
+		RuleID:   7,
+		UserID:   uid,
+		Event:    types.EventTypeCoinBalanceChange,
+		Address:  types.HexToAddress("0x2"),
+		Severity: model.SeverityWarning,
+		TxDigest: "digest",
+	}
+}
+
+// deliver sends the alert to a webhook as the outbox does, with the message storing it.
+func deliver(n *webhook.Notifier, m *model.OutboxMessage) error {
+	return n.Notify(service.WithMessage(context.Background(), m), &m.Alert)
+}
+
+func TestNotifySigns(t *testing.T) {
+	wsv, osv := newServices(t)
+	rcv := &receiver{t: t, secret: "s3cret", statuses: []int{http.StatusInternalServerError}}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+	w := &model.Webhook{UserID: 1, URL: srv.URL, Secret: rcv.secret}
+	if err := wsv.Create(context.Background(), w); err != nil {
+		t.Fatal(err)
+	}
+	n := newNotifier(wsv, osv)
+
+	// the outbox retries a failed delivery with the same message
+	m := &model.OutboxMessage{ID: 42, Alert: *testAlert(1)}
+	m.Alert.Destination = strconv.Itoa(int(w.ID))
+	if err := deliver(n, m); err == nil || errors.Is(err, bots.ErrUnreachable) {
+		t.Fatalf("err = %v, want a retryable error", err)
+	}
+	if len(rcv.payloads) != 1 {
+		t.Fatalf("got %d requests, want 1, the outbox retries", len(rcv.payloads))
+	}
+	m.Attempts++
+	if err := deliver(n, m); err != nil {
+		t.Fatalf("notify: %s", err)
+	}
+	p := rcv.payloads[1]
+	if p.Network != "testnet" || p.RuleID != 7 || p.TxDigest != "digest" || p.Event != types.EventTypeCoinBalanceChange {
+		t.Errorf("unexpected payload: %+v", p)
+	}
+	if p.DeliveryID != "42" || rcv.payloads[0].DeliveryID != "42" {
+		t.Errorf("delivery ids %s and %s, want the id of the message", rcv.payloads[0].DeliveryID, p.DeliveryID)
+	}
+
+	ds, err := wsv.Deliveries(context.Background(), w.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds) != 2 {
+		t.Fatalf("got %d deliveries, want 2", len(ds))
+	}
+	// the latest first
+	for i, want := range []int{http.StatusOK, http.StatusInternalServerError} {
+		if ds[i].StatusCode != want || ds[i].Attempt != 2-i {
+			t.Errorf("delivery %d: status %d attempt %d, want %d attempt %d", i, ds[i].StatusCode, ds[i].Attempt, want, 2-i)
+		}
+	}
+	if ds[0].Response != "ok" || ds[0].Source != "rule 7" {
+		t.Errorf("unexpected delivery: %+v", ds[0])
+	}
+}
+
+func TestNotifyQueuesEachWebhook(t *testing.T) {
+	ctx := context.Background()
+	wsv, osv := newServices(t)
+	rcv := &receiver{t: t, secret: "s3cret"}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+	var ids []uint
+	for _, paused := range []bool{false, true, false} {
+		w := &model.Webhook{UserID: 1, URL: srv.URL, Secret: rcv.secret, Paused: paused}
+		if err := wsv.Create(ctx, w); err != nil {
+			t.Fatal(err)
+		}
+		if !paused {
+			ids = append(ids, w.ID)
+		}
+	}
+
+	if err := newNotifier(wsv, osv).Notify(ctx, testAlert(1)); err != nil {
+		t.Fatalf("notify: %s", err)
+	}
+	if len(rcv.payloads) != 0 {
+		t.Fatalf("got %d requests, want the alert stored for each webhook", len(rcv.payloads))
+	}
+	msgs, err := osv.List(ctx, model.OutboxPending, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != len(ids) {
+		t.Fatalf("got %d messages, want %d", len(msgs), len(ids))
+	}
+	for i, m := range msgs {
+		if want := "webhook:" + strconv.Itoa(int(ids[i])); m.Destination != want || m.Alert.RuleID != 7 {
+			t.Errorf("message %d to %s of %s, want %s", i, m.Destination, m.Alert.Source(), want)
+		}
+	}
+}
+
+func TestNotifyDeadLettersClientErrors(t *testing.T) {
+	ctx := context.Background()
+	wsv, osv := newServices(t)
+	rcv := &receiver{t: t, secret: "s3cret", statuses: []int{http.StatusBadRequest}}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+	w := &model.Webhook{UserID: 1, URL: srv.URL, Secret: rcv.secret}
+	if err := wsv.Create(ctx, w); err != nil {
+		t.Fatal(err)
+	}
+
+	alert := testAlert(1)
+	alert.Destination = strconv.Itoa(int(w.ID))
+	if err := newNotifier(wsv, osv).Notify(ctx, alert); !errors.Is(err, bots.ErrUnreachable) {
+		t.Fatalf("err = %v, want %v", err, bots.ErrUnreachable)
+	}
+	if len(rcv.payloads) != 1 {
+		t.Fatalf("got %d requests, want 1", len(rcv.payloads))
+	}
+}
+
+func TestNotifyUnreachable(t *testing.T) {
+	wsv, osv := newServices(t)
+	err := newNotifier(wsv, osv).Notify(context.Background(), testAlert(2))
+	if !errors.Is(err, bots.ErrUnreachable) {
+		t.Fatalf("err = %v, want %v", err, bots.ErrUnreachable)
+	}
+}
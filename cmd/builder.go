@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/strahe/suialert/anomaly"
 	"github.com/strahe/suialert/rule"
 	"github.com/strahe/suialert/service"
 	"gorm.io/driver/mysql"
@@ -14,12 +15,20 @@ import (
 
 	"github.com/strahe/suialert/bots"
 	"github.com/strahe/suialert/bots/discord"
+	"github.com/strahe/suialert/bots/email"
+	"github.com/strahe/suialert/bots/slack"
+	"github.com/strahe/suialert/bots/teams"
+	"github.com/strahe/suialert/bots/telegram"
+	"github.com/strahe/suialert/bots/webhook"
 	"github.com/strahe/suialert/client"
 	"github.com/strahe/suialert/config"
+	"github.com/strahe/suialert/dispatcher"
 	"github.com/strahe/suialert/handlers"
 	"github.com/strahe/suialert/model"
 	"github.com/strahe/suialert/processors"
+	"github.com/strahe/suialert/types"
 	"go.uber.org/fx"
+	"go.uber.org/zap"
 )
 
 func (c *command) Config() (*config.Config, error) {
@@ -30,37 +39,202 @@ func (c *command) Config() (*config.Config, error) {
 	return &cfg, nil
 }
 
-func NewEngine(lc fx.Lifecycle, ruleService *service.RuleService) (*rule.Engine, error) {
-	eng, err := rule.NewEngine(ruleService)
+// RegisterCoins makes the configured coins known to the formatting of amounts.
+func RegisterCoins(cfg *config.Config) {
+	for _, c := range cfg.Sui.Coins {
+		types.RegisterCoin(c.Type, types.CoinInfo{Symbol: c.Symbol, Decimals: c.Decimals, PriceUSD: c.PriceUSD})
+	}
+}
+
+// RegisterWatchlists makes the configured watchlists known to the conditions of the rules.
+func RegisterWatchlists(cfg *config.Config) {
+	for _, w := range cfg.Watchlists {
+		rule.RegisterWatchlist(w.Name, w.Addresses)
+	}
+}
+
+func NewEngine(lc fx.Lifecycle, cfg *config.Config, ruleService *service.RuleService, matchService *service.MatchService,
+	dp *dispatcher.Dispatcher) (*rule.Engine, error) {
+	eng, err := rule.NewEngine(ruleService, matchService, dp)
 	if err != nil {
 		return nil, err
 	}
+	eng.SetDryRun(cfg.DryRun)
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
-			return eng.LoadRules(ctx)
+			return eng.Start(ctx)
+		},
+		OnStop: func(ctx context.Context) error {
+			return eng.Close(ctx)
 		},
 	})
 	return eng, nil
 }
 
-func NewBot(lc fx.Lifecycle, cfg *config.Config, userService *service.UserService, ruleService *service.RuleService) (bots.Bot, error) {
-	bot, err := discord.NewDiscord(cfg.Bots.Discord, userService, ruleService)
-	if err != nil {
-		return nil, err
+func NewStaticLoader(lc fx.Lifecycle, cfg *config.Config, eng *rule.Engine) *rule.StaticLoader {
+	l := rule.NewStaticLoader(cfg.Rules, eng)
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			return l.Start(ctx)
+		},
+		OnStop: func(ctx context.Context) error {
+			return l.Close(ctx)
+		},
+	})
+	return l
+}
+
+// NewDispatcher passes the alerts to the bots through the outbox.
+func NewDispatcher(lc fx.Lifecycle, cfg *config.Config, reg *bots.Registry, db *gorm.DB, userService *service.UserService,
+	ackService *service.AckService, outboxService *service.OutboxService) *dispatcher.Dispatcher {
+	var notifier rule.Notifier
+	if len(reg.Backends()) > 0 {
+		notifier = reg
+	}
+	ob := dispatcher.NewOutbox(notifier, outboxService, cfg.Alerts.Outbox)
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			return ob.Start(ctx)
+		},
+		OnStop: func(ctx context.Context) error {
+			return ob.Close(ctx)
+		},
+	})
+	dp := dispatcher.NewDispatcher(ob, db, userService, ackService, cfg.Alerts.RateLimit)
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			return dp.Start(ctx)
+		},
+		OnStop: func(ctx context.Context) error {
+			return dp.Close(ctx)
+		},
+	})
+	return dp
+}
+
+// NewBot registers the notifier backends enabled in the config, alerts are only logged if there is none.
+func NewBot(lc fx.Lifecycle, cfg *config.Config, userService *service.UserService, ruleService *service.RuleService,
+	matchService *service.MatchService, ackService *service.AckService, webhookService *service.WebhookService,
+	outboxService *service.OutboxService) (*bots.Registry, error) {
+	reg := bots.NewRegistry()
+	explorer := bots.Explorer{URL: cfg.Sui.Explorer, Network: cfg.Sui.Network}
+	// the email notifier also verifies the addresses the users set with the other bots
+	var (
+		mailer   *email.Notifier
+		verifier bots.Verifier
+	)
+	if cfg.Bots.Email.Enable {
+		var err error
+		if mailer, err = email.NewEmail(cfg.Bots.Email, explorer, userService); err != nil {
+			return nil, err
+		}
+		verifier = mailer
+	}
+	if cfg.Bots.Discord.Enable {
+		bot, err := discord.NewDiscord(cfg.Bots.Discord, explorer, userService, ruleService, matchService, ackService, verifier)
+		if err != nil {
+			return nil, err
+		}
+		reg.Register(bot)
+	}
+	if cfg.Bots.Telegram.Enable {
+		bot, err := telegram.NewTelegram(cfg.Bots.Telegram, userService, ruleService, ackService, verifier)
+		if err != nil {
+			return nil, err
+		}
+		reg.Register(bot)
+	}
+	if cfg.Bots.Webhook.Enable {
+		reg.Register(webhook.NewWebhook(cfg.Bots.Webhook, cfg.Sui.Network, webhookService, outboxService))
+	}
+	if cfg.Bots.Slack.Enable {
+		reg.Register(slack.NewSlack(cfg.Bots.Slack, explorer))
+	}
+	if cfg.Bots.Teams.Enable {
+		reg.Register(teams.NewTeams(cfg.Bots.Teams, explorer))
+	}
+	if mailer != nil {
+		reg.Register(mailer)
+	}
+	if len(reg.Backends()) == 0 {
+		zap.S().Warn("no bot enabled, alerts are only logged")
 	}
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
-			return bot.Run(ctx)
+			return reg.Run(ctx)
 		},
 		OnStop: func(ctx context.Context) error {
-			return bot.Close(ctx)
+			return reg.Close(ctx)
 		},
 	})
-	return bot, nil
+	return reg, nil
 }
 
-func NewHandler(lc fx.Lifecycle, bot bots.Bot, db *gorm.DB, eng *rule.Engine) *handlers.SubHandler {
-	hd := handlers.NewSubHandler(bot, db, eng)
+func NewCorrelator(lc fx.Lifecycle, cfg *config.Config, sequenceService *service.SequenceService,
+	matchService *service.MatchService, dp *dispatcher.Dispatcher) *rule.Correlator {
+	cor := rule.NewCorrelator(sequenceService, matchService, dp)
+	cor.SetDryRun(cfg.DryRun)
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			return cor.Start(ctx)
+		},
+		OnStop: func(ctx context.Context) error {
+			return cor.Close(ctx)
+		},
+	})
+	return cor
+}
+
+func NewChecker(lc fx.Lifecycle, cfg *config.Config, checkService *service.CheckService,
+	matchService *service.MatchService, dp *dispatcher.Dispatcher) *rule.Checker {
+	chk := rule.NewChecker(checkService, matchService, dp, cfg.Checks.Interval)
+	chk.SetDryRun(cfg.DryRun)
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			return chk.Start(ctx)
+		},
+		OnStop: func(ctx context.Context) error {
+			return chk.Close(ctx)
+		},
+	})
+	return chk
+}
+
+func NewQueryRunner(lc fx.Lifecycle, cfg *config.Config, queryService *service.QueryService,
+	matchService *service.MatchService, dp *dispatcher.Dispatcher) *rule.QueryRunner {
+	qr := rule.NewQueryRunner(queryService, matchService, dp, cfg.Queries.Timeout, cfg.Queries.MaxRows)
+	qr.SetDryRun(cfg.DryRun)
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			return qr.Start(ctx)
+		},
+		OnStop: func(ctx context.Context) error {
+			return qr.Close(ctx)
+		},
+	})
+	return qr
+}
+
+func NewDetector(lc fx.Lifecycle, cfg *config.Config, db *gorm.DB, ruleService *service.RuleService, dp *dispatcher.Dispatcher) *anomaly.Detector {
+	var notifier rule.Notifier = dp
+	if cfg.DryRun {
+		notifier = rule.LogNotifier{}
+	}
+	d := anomaly.NewDetector(cfg.Anomaly, db, ruleService, notifier)
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			return d.Start(ctx)
+		},
+		OnStop: func(ctx context.Context) error {
+			return d.Close(ctx)
+		},
+	})
+	return d
+}
+
+func NewHandler(lc fx.Lifecycle, bot *bots.Registry, db *gorm.DB, eng *rule.Engine, cor *rule.Correlator,
+	chk *rule.Checker) *handlers.SubHandler {
+	hd := handlers.NewSubHandler(bot, db, eng, cor, chk)
 	lc.Append(fx.Hook{
 		OnStop: func(context.Context) error {
 			return hd.Close()
@@ -104,6 +278,34 @@ func NewRuleService(db *gorm.DB) *service.RuleService {
 	return service.NewRuleService(db)
 }
 
+func NewCheckService(db *gorm.DB) *service.CheckService {
+	return service.NewCheckService(db)
+}
+
+func NewQueryService(db *gorm.DB) *service.QueryService {
+	return service.NewQueryService(db)
+}
+
+func NewAckService(db *gorm.DB) *service.AckService {
+	return service.NewAckService(db)
+}
+
+func NewWebhookService(db *gorm.DB) *service.WebhookService {
+	return service.NewWebhookService(db)
+}
+
+func NewOutboxService(db *gorm.DB) *service.OutboxService {
+	return service.NewOutboxService(db)
+}
+
+func NewMatchService(db *gorm.DB) *service.MatchService {
+	return service.NewMatchService(db)
+}
+
+func NewSequenceService(db *gorm.DB) *service.SequenceService {
+	return service.NewSequenceService(db)
+}
+
 func NewUserService(db *gorm.DB) *service.UserService {
 	return service.NewUserService(db)
 }
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
+	"github.com/strahe/suialert/types"
+	"gorm.io/gorm"
+)
+
+func (c *command) initChecksCmd() {
+	cmd := &cobra.Command{
+		Use:   "checks",
+		Short: "Manage the scheduled checks, alerts on missing activity",
+	}
+	cmd.AddCommand(c.checksAddCmd(), c.checksListCmd(), c.checksRemoveCmd())
+	c.root.AddCommand(cmd)
+}
+
+func (c *command) checksAddCmd() *cobra.Command {
+	var (
+		r       model.CheckRule
+		address string
+		kind    string
+		event   string
+	)
+	cmd := &cobra.Command{
+		Use:   "add",
+		Short: "Add a check",
+		Example: "  checks add --user 1 --address 0x... --kind inactivity --period 24h\n" +
+			"  checks add --user 1 --address 0x... --kind no_inflow --period 168h",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !types.IsHexAddress(address) {
+				return fmt.Errorf("invalid address: %s", address)
+			}
+			r.Address = types.HexToAddress(address)
+			r.Kind = model.CheckKind(kind)
+			r.Event = types.EventType(event)
+			return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+				if _, err := service.NewUserService(db).FindByID(r.UserID); err != nil {
+					return fmt.Errorf("user %d: %w", r.UserID, err)
+				}
+				if err := service.NewCheckService(db).Create(&r); err != nil {
+					return err
+				}
+				fmt.Printf("check %d added\n", r.ID)
+				return nil
+			})
+		},
+	}
+	cmd.Flags().UintVarP(&r.UserID, "user", "u", 0, "id of the user to alert")
+	cmd.Flags().StringVar(&r.Name, "name", "", "name of the check")
+	cmd.Flags().StringVarP(&address, "address", "a", "", "address to check")
+	cmd.Flags().StringVarP(&kind, "kind", "k", string(model.CheckInactivity), "inactivity or no_inflow")
+	cmd.Flags().StringVarP(&event, "event", "e", "", "event type expected by an inactivity check, any if not set")
+	cmd.Flags().StringVar(&r.CoinType, "coin", "", "coin type expected by a no_inflow check, SUI if not set")
+	cmd.Flags().DurationVarP(&r.Period, "period", "p", 24*time.Hour, "period the activity is expected within")
+	_ = cmd.MarkFlagRequired("user")
+	_ = cmd.MarkFlagRequired("address")
+	return cmd
+}
+
+func (c *command) checksListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the checks",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+				checks, err := service.NewCheckService(db).FindAll(ctx)
+				if err != nil {
+					return err
+				}
+				for _, r := range checks {
+					expected := string(r.Kind)
+					if r.Event != "" {
+						expected += " of " + string(r.Event)
+					}
+					if r.CoinType != "" {
+						expected += " of " + r.CoinType
+					}
+					fmt.Printf("%d  user %d  %s  %s within %s  %s\n", r.ID, r.UserID, r.Address.Hex(), expected, r.Period, r.Name)
+				}
+				return nil
+			})
+		},
+	}
+}
+
+func (c *command) checksRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove <check-id>",
+		Short: "Remove a check",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid check id: %s", args[0])
+			}
+			return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+				csv := service.NewCheckService(db)
+				r, err := csv.FindByID(uint(id))
+				if err != nil {
+					return err
+				}
+				if err := csv.Delete(r); err != nil {
+					return err
+				}
+				fmt.Printf("check %d removed\n", r.ID)
+				return nil
+			})
+		},
+	}
+}
@@ -45,6 +45,12 @@ func newCommand(opts ...option) (c *command, err error) {
 
 	c.initGlobalFlags()
 	c.initRunCmd()
+	c.initRulesCmd()
+	c.initChecksCmd()
+	c.initQueriesCmd()
+	c.initWebhooksCmd()
+	c.initOutboxCmd()
+	c.initUsersCmd()
 	c.initVersionCmd()
 
 	return c, nil
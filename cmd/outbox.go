@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
+	"gorm.io/gorm"
+)
+
+func (c *command) initOutboxCmd() {
+	cmd := &cobra.Command{
+		Use:   "outbox",
+		Short: "Inspect and re-drive the notifications waiting to be delivered",
+	}
+	cmd.AddCommand(c.outboxListCmd(), c.outboxShowCmd(), c.outboxRedriveCmd())
+	c.root.AddCommand(cmd)
+}
+
+func (c *command) outboxListCmd() *cobra.Command {
+	var (
+		all   bool
+		limit int
+	)
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the dead-lettered notifications, or all the notifications not delivered yet",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			status := model.OutboxDead
+			if all {
+				status = ""
+			}
+			return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+				msgs, err := service.NewOutboxService(db).List(ctx, status, limit)
+				if err != nil {
+					return err
+				}
+				for _, m := range msgs {
+					fmt.Printf("%d  %s  %-7s  %s  %s  attempts %d  %s\n", m.ID, m.CreatedAt.Local().Format(time.DateTime),
+						m.Status, m.Destination, m.Alert.Source(), m.Attempts, m.LastError)
+				}
+				return nil
+			})
+		},
+	}
+	cmd.Flags().BoolVarP(&all, "all", "a", false, "also list the pending notifications")
+	cmd.Flags().IntVarP(&limit, "limit", "n", 50, "number of notifications")
+	return cmd
+}
+
+func (c *command) outboxShowCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show <id>",
+		Short: "Show a notification of the outbox with its alert, as json",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid id: %s", args[0])
+			}
+			return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+				m, err := service.NewOutboxService(db).FindByID(ctx, uint(id))
+				if err != nil {
+					return err
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(m)
+			})
+		},
+	}
+}
+
+func (c *command) outboxRedriveCmd() *cobra.Command {
+	var all bool
+	cmd := &cobra.Command{
+		Use:   "redrive [id...]",
+		Short: "Deliver dead-lettered notifications again",
+		Long: "Deliver dead-lettered notifications again, with the attempts reset.\n" +
+			"They keep their place in the order of their destination, a running instance sends them within its interval.",
+		Example: "  outbox redrive 12 13\n" +
+			"  outbox redrive --all",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if all == (len(args) > 0) {
+				return fmt.Errorf("either ids or --all is required")
+			}
+			ids := make([]uint, len(args))
+			for i, arg := range args {
+				id, err := strconv.ParseUint(arg, 10, 64)
+				if err != nil {
+					return fmt.Errorf("invalid id: %s", arg)
+				}
+				ids[i] = uint(id)
+			}
+			return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+				n, err := service.NewOutboxService(db).Redrive(ctx, ids...)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("%d notifications re-driven\n", n)
+				return nil
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&all, "all", false, "re-drive all the dead-lettered notifications")
+	return cmd
+}
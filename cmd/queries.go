@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
+	"gorm.io/gorm"
+)
+
+func (c *command) initQueriesCmd() {
+	cmd := &cobra.Command{
+		Use:   "queries",
+		Short: "Manage the query rules, SQL queries over the stored events run on a schedule",
+	}
+	cmd.AddCommand(c.queriesAddCmd(), c.queriesListCmd(), c.queriesRemoveCmd(), c.queriesTestCmd())
+	c.root.AddCommand(cmd)
+}
+
+func (c *command) queriesAddCmd() *cobra.Command {
+	var (
+		r  model.QueryRule
+		op string
+	)
+	cmd := &cobra.Command{
+		Use:   "add <query>",
+		Short: "Add a query rule",
+		Long: "Add a query rule, it fires when the first column of the first row of the query meets the threshold.\n" +
+			"The query can use the named parameters @since and @now, the window of the run,\n" +
+			"@since_ms and @now_ms in milliseconds like the timestamps of the events, and those set with --param.",
+		Example: `  queries add --user 1 --name "many senders" --interval 10m --window 1h --op ">" --threshold 100 \
+    --param package=0x2 \
+    "SELECT COUNT(DISTINCT sender) FROM move_events WHERE package_id = @package AND timestamp >= @since_ms"`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			r.Query = args[0]
+			r.Op = model.CompareOp(op)
+			return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+				if _, err := service.NewUserService(db).FindByID(r.UserID); err != nil {
+					return fmt.Errorf("user %d: %w", r.UserID, err)
+				}
+				if err := service.NewQueryService(db).Create(&r); err != nil {
+					return err
+				}
+				fmt.Printf("query %d added\n", r.ID)
+				return nil
+			})
+		},
+	}
+	cmd.Flags().UintVarP(&r.UserID, "user", "u", 0, "id of the user to alert")
+	cmd.Flags().StringVar(&r.Name, "name", "", "name of the query")
+	cmd.Flags().StringToStringVarP(&r.Params, "param", "p", nil, "named parameter of the query, name=value")
+	cmd.Flags().DurationVarP(&r.Interval, "interval", "i", time.Hour, "how often the query runs")
+	cmd.Flags().DurationVarP(&r.Window, "window", "w", time.Hour, "time covered by the query, from @since to @now")
+	cmd.Flags().StringVar(&op, "op", ">", "operator comparing the result with the threshold: >, >=, <, <=, == or !=")
+	cmd.Flags().Float64VarP(&r.Threshold, "threshold", "t", 0, "threshold of the result")
+	_ = cmd.MarkFlagRequired("user")
+	return cmd
+}
+
+func (c *command) queriesListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the query rules",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+				rules, err := service.NewQueryService(db).FindAll(ctx)
+				if err != nil {
+					return err
+				}
+				for _, r := range rules {
+					fmt.Printf("%d  user %d  every %s over %s  %s %g  %s\n  %s\n",
+						r.ID, r.UserID, r.Interval, r.Window, r.Op, r.Threshold, r.Name, r.Query)
+				}
+				return nil
+			})
+		},
+	}
+}
+
+func (c *command) queriesRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove <query-id>",
+		Short: "Remove a query rule",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid query id: %s", args[0])
+			}
+			return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+				qsv := service.NewQueryService(db)
+				r, err := qsv.FindByID(uint(id))
+				if err != nil {
+					return err
+				}
+				if err := qsv.Delete(r); err != nil {
+					return err
+				}
+				fmt.Printf("query %d removed\n", r.ID)
+				return nil
+			})
+		},
+	}
+}
+
+func (c *command) queriesTestCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "test <query-id>",
+		Short: "Run a query rule now and print its result, without alerting",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid query id: %s", args[0])
+			}
+			cfg, err := c.Config()
+			if err != nil {
+				return err
+			}
+			return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+				qsv := service.NewQueryService(db)
+				r, err := qsv.FindByID(uint(id))
+				if err != nil {
+					return err
+				}
+				res, err := qsv.Run(ctx, r, time.Now(), cfg.Queries.Timeout, cfg.Queries.MaxRows)
+				if err != nil {
+					return err
+				}
+				fmt.Println(strings.Join(res.Columns, "\t"))
+				for _, row := range res.Rows {
+					cells := make([]string, len(row))
+					for i, v := range row {
+						cells[i] = fmt.Sprint(v)
+					}
+					fmt.Println(strings.Join(cells, "\t"))
+				}
+				fired := "would not fire"
+				if r.Op.Compare(res.Value, r.Threshold) {
+					fired = "would fire"
+				}
+				fmt.Printf("\nvalue %g %s %g: %s\n", res.Value, r.Op, r.Threshold, fired)
+				return nil
+			})
+		},
+	}
+}
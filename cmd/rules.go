@@ -0,0 +1,584 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/rule"
+	"github.com/strahe/suialert/service"
+	"go.uber.org/fx"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+func (c *command) initRulesCmd() {
+	cmd := &cobra.Command{
+		Use:   "rules",
+		Short: "Manage the alert rules",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "history <rule-id>",
+		Short: "Show the versions of a rule",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid rule id: %s", args[0])
+			}
+			return c.withRuleService(func(ctx context.Context, rsv *service.RuleService) error {
+				history, err := rsv.History(ctx, uint(id))
+				if err != nil {
+					return err
+				}
+				if len(history) == 0 {
+					return fmt.Errorf("no history for rule %d", id)
+				}
+				printHistory(history)
+				return nil
+			})
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "restore <rule-id> <version>",
+		Short: "Restore a rule to one of its versions",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid rule id: %s", args[0])
+			}
+			version, err := strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid version: %s", args[1])
+			}
+			return c.withRuleService(func(ctx context.Context, rsv *service.RuleService) error {
+				r, err := rsv.Restore(cliContext(ctx), uint(id), version)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("rule %d restored to version %d\n", r.ID, version)
+				return nil
+			})
+		},
+	})
+	cmd.AddCommand(c.rulesExportCmd(), c.rulesImportCmd(), c.rulesShadowCmd(), c.rulesSeverityCmd(), c.rulesDigestCmd(), c.rulesExpireCmd(),
+		c.rulesRouteCmd(), c.rulesMatchesCmd(), c.rulesStatsCmd(), c.rulesSequenceCmd())
+	c.root.AddCommand(cmd)
+}
+
+func (c *command) rulesExportCmd() *cobra.Command {
+	var (
+		userID uint
+		format string
+		output string
+	)
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the rules of a user, or of the whole instance, as yaml or json",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format == "" {
+				format = formatOf(output)
+			}
+			return c.withRuleService(func(ctx context.Context, rsv *service.RuleService) error {
+				var (
+					rules []model.Rule
+					err   error
+				)
+				if userID != 0 {
+					rules, err = rsv.FindByUser(ctx, userID)
+				} else {
+					rules, err = rsv.FindAll(ctx)
+				}
+				if err != nil {
+					return err
+				}
+				var file model.RuleFile
+				for i := range rules {
+					file.Rules = append(file.Rules, model.NewRuleSpec(&rules[i]))
+				}
+				data, err := marshalRules(&file, format)
+				if err != nil {
+					return err
+				}
+				if output == "" {
+					_, err = os.Stdout.Write(data)
+					return err
+				}
+				return os.WriteFile(output, data, 0o644)
+			})
+		},
+	}
+	cmd.Flags().UintVarP(&userID, "user", "u", 0, "only export the rules of this user id")
+	cmd.Flags().StringVarP(&format, "format", "f", "", "yaml or json (default from the output file, or yaml)")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "file to write (default stdout)")
+	return cmd
+}
+
+func (c *command) rulesImportCmd() *cobra.Command {
+	var (
+		format string
+		dryRun bool
+	)
+	cmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Create or update rules from a yaml or json file",
+		Long: "Create or update rules from a yaml or json file. Rules are matched by user, event and address, " +
+			"all of them are validated before anything is written.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format == "" {
+				format = formatOf(args[0])
+			}
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			var file model.RuleFile
+			if err := unmarshalRules(data, format, &file); err != nil {
+				return fmt.Errorf("failed to parse %s: %w", args[0], err)
+			}
+			var (
+				rules []*model.Rule
+				errs  []string
+			)
+			for i := range file.Rules {
+				r, err := file.Rules[i].Rule()
+				if err == nil {
+					err = rule.Check(r)
+				}
+				if err != nil {
+					errs = append(errs, fmt.Sprintf("rule %d: %s", i, err))
+					continue
+				}
+				rules = append(rules, r)
+			}
+			if len(errs) > 0 {
+				return fmt.Errorf("invalid rules:\n  %s", strings.Join(errs, "\n  "))
+			}
+			return c.withRuleService(func(ctx context.Context, rsv *service.RuleService) error {
+				var plan []service.RuleImport
+				if dryRun {
+					plan, err = rsv.PlanImport(ctx, rules)
+				} else {
+					plan, err = rsv.Import(cliContext(ctx), rules)
+				}
+				if err != nil {
+					return err
+				}
+				printImport(plan, dryRun)
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVarP(&format, "format", "f", "", "yaml or json (default from the file extension)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "only show the changes")
+	return cmd
+}
+
+func (c *command) rulesShadowCmd() *cobra.Command {
+	var off bool
+	cmd := &cobra.Command{
+		Use:   "shadow <rule-id>",
+		Short: "Make a rule only record its matches, without executing its actions",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid rule id: %s", args[0])
+			}
+			return c.withRuleService(func(ctx context.Context, rsv *service.RuleService) error {
+				r, err := rsv.FindByID(ctx, uint(id))
+				if err != nil {
+					return err
+				}
+				if err := rsv.SetShadow(cliContext(ctx), r, !off); err != nil {
+					return err
+				}
+				if off {
+					fmt.Printf("rule %d is live\n", r.ID)
+				} else {
+					fmt.Printf("rule %d is in shadow mode\n", r.ID)
+				}
+				return nil
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&off, "off", false, "make the rule live again")
+	return cmd
+}
+
+func (c *command) rulesSeverityCmd() *cobra.Command {
+	var (
+		esc  model.Escalation
+		none bool
+	)
+	cmd := &cobra.Command{
+		Use:   "severity <rule-id> <info|warning|critical>",
+		Short: "Set the severity and the escalation policy of a rule",
+		Example: "  rules severity 12 critical --escalate-after 15m --escalate-channel 1234567890\n" +
+			"  rules severity 12 info --no-escalation",
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid rule id: %s", args[0])
+			}
+			return c.withRuleService(func(ctx context.Context, rsv *service.RuleService) error {
+				r, err := rsv.FindByID(ctx, uint(id))
+				if err != nil {
+					return err
+				}
+				r.Severity = model.Severity(args[1])
+				switch {
+				case none:
+					r.Escalation = nil
+				case esc.After != 0 || esc.Channel != "" || esc.UserID != 0:
+					r.Escalation = &esc
+				}
+				if err := rsv.Update(cliContext(ctx), r); err != nil {
+					return err
+				}
+				fmt.Printf("rule %d is %s", r.ID, r.Severity)
+				if r.Escalation != nil {
+					fmt.Printf(", escalated to %s", r.Escalation)
+				}
+				fmt.Println()
+				return nil
+			})
+		},
+	}
+	cmd.Flags().DurationVar(&esc.After, "escalate-after", 0, "escalate the alerts not acknowledged within this time")
+	cmd.Flags().StringVar(&esc.Channel, "escalate-channel", "", "channel the alerts are escalated to")
+	cmd.Flags().UintVar(&esc.UserID, "escalate-user", 0, "user the alerts are escalated to")
+	cmd.Flags().BoolVar(&none, "no-escalation", false, "remove the escalation policy")
+	return cmd
+}
+
+func (c *command) rulesDigestCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "digest <rule-id> <default|realtime|hourly|daily>",
+		Short: "Send the alerts of a rule in an hourly or daily summary",
+		Long: "Send the alerts of a rule in an hourly or daily summary, or in real time.\n" +
+			"Rules in the default mode follow the mode of their owner, see users digest.",
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid rule id: %s", args[0])
+			}
+			mode := model.DigestMode(args[1])
+			if args[1] == "default" {
+				mode = model.DigestDefault
+			}
+			return c.withRuleService(func(ctx context.Context, rsv *service.RuleService) error {
+				r, err := rsv.FindByID(ctx, uint(id))
+				if err != nil {
+					return err
+				}
+				if err := rsv.SetDigest(cliContext(ctx), r, mode); err != nil {
+					return err
+				}
+				if mode == model.DigestDefault {
+					fmt.Printf("alerts of rule %d follow the digest mode of its owner\n", r.ID)
+				} else {
+					fmt.Printf("alerts of rule %d are sent %s\n", r.ID, mode)
+				}
+				return nil
+			})
+		},
+	}
+}
+
+func (c *command) rulesExpireCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "expire <rule-id> <duration|time|never>",
+		Short: "Make a rule stop matching after a duration or at a time",
+		Example: "  rules expire 12 72h\n" +
+			"  rules expire 12 2023-06-30T18:00:00Z\n" +
+			"  rules expire 12 never",
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid rule id: %s", args[0])
+			}
+			var at *time.Time
+			if args[1] != "never" {
+				t, err := parseExpiry(args[1])
+				if err != nil {
+					return err
+				}
+				at = &t
+			}
+			return c.withRuleService(func(ctx context.Context, rsv *service.RuleService) error {
+				r, err := rsv.FindByID(ctx, uint(id))
+				if err != nil {
+					return err
+				}
+				if err := rsv.SetExpiry(cliContext(ctx), r, at); err != nil {
+					return err
+				}
+				if at == nil {
+					fmt.Printf("rule %d never expires\n", r.ID)
+				} else {
+					fmt.Printf("rule %d expires at %s\n", r.ID, at.Format(time.RFC3339))
+				}
+				return nil
+			})
+		},
+	}
+}
+
+// parseExpiry parses a duration from now, or a time in RFC 3339.
+func parseExpiry(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		if d <= 0 {
+			return time.Time{}, fmt.Errorf("the duration must be positive: %s", s)
+		}
+		return time.Now().Add(d).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid expiry %q, expected a duration, a time in RFC 3339 or never", s)
+	}
+	return t, nil
+}
+
+func (c *command) rulesRouteCmd() *cobra.Command {
+	var owner bool
+	cmd := &cobra.Command{
+		Use:   "route <rule-id> [destination]",
+		Short: "Send the alerts of a rule to a channel instead of its owner",
+		Example: "  rules route 12 slack:ops\n" +
+			"  rules route 12 teams:security\n" +
+			"  rules route 12 --owner",
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid rule id: %s", args[0])
+			}
+			if owner == (len(args) == 2) {
+				return fmt.Errorf("either a destination or --owner is required")
+			}
+			return c.withRuleService(func(ctx context.Context, rsv *service.RuleService) error {
+				r, err := rsv.FindByID(ctx, uint(id))
+				if err != nil {
+					return err
+				}
+				dest := ""
+				if !owner {
+					dest = args[1]
+				}
+				if err := rsv.SetDestination(cliContext(ctx), r, dest); err != nil {
+					return err
+				}
+				if owner {
+					fmt.Printf("alerts of rule %d are sent to its owner\n", r.ID)
+				} else {
+					fmt.Printf("alerts of rule %d are sent to %s\n", r.ID, dest)
+				}
+				return nil
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&owner, "owner", false, "send the alerts to the owner of the rule again")
+	return cmd
+}
+
+func (c *command) rulesMatchesCmd() *cobra.Command {
+	var limit int
+	cmd := &cobra.Command{
+		Use:   "matches <rule-id>",
+		Short: "Show the recorded matches of a rule",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid rule id: %s", args[0])
+			}
+			return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+				matches, err := service.NewMatchService(db).FindByRule(ctx, uint(id), limit)
+				if err != nil {
+					return err
+				}
+				for _, m := range matches {
+					fmt.Printf("%s  %-7s  %s  %s\n", m.CreatedAt.Format("2006-01-02 15:04:05"), m.Mode, m.Address.Hex(), m.TxDigest)
+				}
+				fmt.Printf("%d matches\n", len(matches))
+				return nil
+			})
+		},
+	}
+	cmd.Flags().IntVarP(&limit, "limit", "n", 20, "number of matches to show")
+	return cmd
+}
+
+func (c *command) rulesStatsCmd() *cobra.Command {
+	var (
+		days   int
+		userID uint
+	)
+	cmd := &cobra.Command{
+		Use:   "stats [rule-id]",
+		Short: "Show the match statistics of a rule, or of all the rules of a user",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && userID == 0 {
+				return fmt.Errorf("a rule id or a user is required")
+			}
+			since := time.Now().AddDate(0, 0, -days)
+			return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+				msv := service.NewMatchService(db)
+				if len(args) == 0 {
+					stats, err := msv.StatsByUser(ctx, userID, since)
+					if err != nil {
+						return err
+					}
+					for i := range stats {
+						printStats(&stats[i])
+					}
+					return nil
+				}
+				id, err := strconv.ParseUint(args[0], 10, 64)
+				if err != nil {
+					return fmt.Errorf("invalid rule id: %s", args[0])
+				}
+				st, err := msv.Stats(ctx, uint(id), since)
+				if err != nil {
+					return err
+				}
+				printStats(st)
+				return nil
+			})
+		},
+	}
+	cmd.Flags().IntVarP(&days, "days", "d", 7, "number of days of the statistics")
+	cmd.Flags().UintVarP(&userID, "user", "u", 0, "show the rules of this user id")
+	return cmd
+}
+
+// withDB runs fn with the configured database.
+func (c *command) withDB(fn func(ctx context.Context, db *gorm.DB) error) error {
+	var db *gorm.DB
+	app := fx.New(
+		fx.NopLogger,
+		fx.Provide(c.Config),
+		fx.Provide(NewDB),
+		fx.Populate(&db),
+	)
+	ctx := context.Background()
+	if err := app.Start(ctx); err != nil {
+		return err
+	}
+	defer app.Stop(ctx) // nolint: errcheck
+	return fn(ctx, db)
+}
+
+// withRuleService runs fn with a rule service on the configured database.
+func (c *command) withRuleService(fn func(ctx context.Context, rsv *service.RuleService) error) error {
+	return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+		return fn(ctx, service.NewRuleService(db))
+	})
+}
+
+// cliContext records the local user as the author of the changes.
+func cliContext(ctx context.Context) context.Context {
+	actor := model.Actor{Source: model.SourceCLI}
+	if u, err := user.Current(); err == nil {
+		actor.Name = u.Username
+	}
+	return service.WithActor(ctx, actor)
+}
+
+func formatOf(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return "json"
+	}
+	return "yaml"
+}
+
+func marshalRules(file *model.RuleFile, format string) ([]byte, error) {
+	switch format {
+	case "json":
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case "yaml", "yml":
+		return yaml.Marshal(file)
+	}
+	return nil, fmt.Errorf("unsupported format: %s", format)
+}
+
+func unmarshalRules(data []byte, format string, file *model.RuleFile) error {
+	switch format {
+	case "json":
+		return json.Unmarshal(data, file)
+	case "yaml", "yml":
+		return yaml.Unmarshal(data, file)
+	}
+	return fmt.Errorf("unsupported format: %s", format)
+}
+
+func printImport(plan []service.RuleImport, dryRun bool) {
+	var created, updated, unchanged int
+	for _, p := range plan {
+		switch p.Change {
+		case model.ChangeCreated:
+			created++
+		case model.ChangeUpdated:
+			updated++
+		default:
+			unchanged++
+			continue
+		}
+		fmt.Printf("%s rule of user %d for %s on %s\n", p.Change, p.Rule.UserID, p.Rule.Event, p.Rule.Address.Hex())
+		for _, d := range p.Diff {
+			fmt.Printf("  %s: %q -> %q\n", d.Field, d.Old, d.New)
+		}
+	}
+	prefix := ""
+	if dryRun {
+		prefix = "dry run: "
+	}
+	fmt.Printf("%s%d created, %d updated, %d unchanged\n", prefix, created, updated, unchanged)
+}
+
+func printStats(st *model.RuleStats) {
+	last := "never"
+	if st.LastMatch != nil {
+		last = st.LastMatch.Format("2006-01-02 15:04:05")
+	}
+	fmt.Printf("rule %d: %d matches since %s, last match %s\n", st.RuleID, st.Total, st.Since.Format("2006-01-02"), last)
+	fmt.Printf("  delivered %d, failed %d, suppressed %d, held %d, queued %d\n",
+		st.Delivered, st.Failed, st.Suppressed, st.Held, st.Queued)
+	for _, d := range st.PerDay {
+		fmt.Printf("  %s  %d\n", d.Day, d.Count)
+	}
+}
+
+func printHistory(history []model.RuleHistory) {
+	for _, h := range history {
+		actor := model.Actor{Source: h.Source, Name: h.Actor}
+		fmt.Printf("version %d  %s  by %s  at %s\n", h.Version, h.Change, actor, h.CreatedAt.Format("2006-01-02 15:04:05"))
+		for _, d := range h.Diff {
+			fmt.Printf("  %s: %q -> %q\n", d.Field, d.Old, d.New)
+		}
+	}
+}
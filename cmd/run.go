@@ -4,8 +4,10 @@ import (
 	"fmt"
 
 	"github.com/spf13/cobra"
+	"github.com/strahe/suialert/anomaly"
 	"github.com/strahe/suialert/build"
 	"github.com/strahe/suialert/processors"
+	"github.com/strahe/suialert/rule"
 	"go.uber.org/fx"
 )
 
@@ -20,11 +22,29 @@ func (c *command) initRunCmd() {
 				fx.Provide(NewDB),
 				fx.Provide(NewRuleService),
 				fx.Provide(NewUserService),
+				fx.Provide(NewSequenceService),
+				fx.Provide(NewMatchService),
+				fx.Provide(NewAckService),
+				fx.Provide(NewWebhookService),
+				fx.Provide(NewOutboxService),
+				fx.Provide(NewCheckService),
+				fx.Provide(NewQueryService),
 				fx.Provide(NewPRCClient),
 				fx.Provide(NewProcessor),
 				fx.Provide(NewHandler),
 				fx.Provide(NewBot),
+				fx.Provide(NewDispatcher),
 				fx.Provide(NewEngine),
+				fx.Provide(NewCorrelator),
+				fx.Provide(NewChecker),
+				fx.Provide(NewQueryRunner),
+				fx.Provide(NewDetector),
+				fx.Provide(NewStaticLoader),
+				fx.Invoke(RegisterCoins),
+				fx.Invoke(RegisterWatchlists),
+				fx.Invoke(func(*anomaly.Detector) {}),
+				fx.Invoke(func(*rule.StaticLoader) {}),
+				fx.Invoke(func(*rule.QueryRunner) {}),
 				fx.Invoke(func(cfg *processors.Processor) {}),
 			)
 			app.Run()
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
+	"github.com/strahe/suialert/types"
+	"gorm.io/gorm"
+)
+
+func (c *command) rulesSequenceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sequence",
+		Short: "Manage the sequence rules, alerts on events happening in order",
+	}
+	cmd.AddCommand(c.sequenceAddCmd(), c.sequenceListCmd(), c.sequenceDeleteCmd())
+	return cmd
+}
+
+func (c *command) sequenceAddCmd() *cobra.Command {
+	var (
+		r         model.SequenceRule
+		steps     []string
+		correlate string
+	)
+	cmd := &cobra.Command{
+		Use:   "add",
+		Short: "Add a sequence rule",
+		Long: "Add a sequence rule. Each step is an event type, optionally followed by a colon and its condition, " +
+			"the steps must happen in order within the window.",
+		Example: "  rules sequence add --user 1 --correlate object --window 5m --step NewObject --step TransferObject\n" +
+			"  rules sequence add --user 1 --correlate sender --window 1h \\\n" +
+			"    --step 'CoinBalanceChange:Event.AmountGreaterThan(\"1000\")' --step Publish",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, s := range steps {
+				event, cond, _ := strings.Cut(s, ":")
+				r.Steps = append(r.Steps, model.SequenceStep{
+					Event:     types.EventType(strings.TrimSpace(event)),
+					Condition: strings.TrimSpace(cond),
+				})
+			}
+			r.CorrelateBy = model.CorrelateBy(correlate)
+			return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+				if _, err := service.NewUserService(db).FindByID(r.UserID); err != nil {
+					return fmt.Errorf("user %d: %w", r.UserID, err)
+				}
+				if err := service.NewSequenceService(db).Create(&r); err != nil {
+					return err
+				}
+				fmt.Printf("sequence %d added\n", r.ID)
+				return nil
+			})
+		},
+	}
+	cmd.Flags().UintVarP(&r.UserID, "user", "u", 0, "id of the user to alert")
+	cmd.Flags().StringVar(&r.Name, "name", "", "name of the sequence")
+	cmd.Flags().StringArrayVarP(&steps, "step", "s", nil, "event type of a step and its condition, as Event[:condition]")
+	cmd.Flags().StringVar(&correlate, "correlate", string(model.CorrelateByObject), "field shared by the events: object, sender or tx")
+	cmd.Flags().DurationVarP(&r.Window, "window", "w", 5*time.Minute, "time allowed between the first and the last step")
+	_ = cmd.MarkFlagRequired("user")
+	_ = cmd.MarkFlagRequired("step")
+	return cmd
+}
+
+func (c *command) sequenceListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the sequence rules",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+				sequences, err := service.NewSequenceService(db).FindAll(ctx)
+				if err != nil {
+					return err
+				}
+				for _, r := range sequences {
+					steps := make([]string, len(r.Steps))
+					for i, st := range r.Steps {
+						steps[i] = string(st.Event)
+						if st.Condition != "" {
+							steps[i] += " (" + st.Condition + ")"
+						}
+					}
+					paused := ""
+					if r.Paused {
+						paused = "  paused"
+					}
+					fmt.Printf("%d  user %d  %s  by %s within %s  %s%s\n", r.ID, r.UserID,
+						strings.Join(steps, " -> "), r.CorrelateBy, r.Window, r.Name, paused)
+				}
+				return nil
+			})
+		},
+	}
+}
+
+func (c *command) sequenceDeleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete <sequence-id>",
+		Short: "Delete a sequence rule and its partial matches",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid sequence id: %s", args[0])
+			}
+			return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+				ssv := service.NewSequenceService(db)
+				r, err := ssv.FindByID(uint(id))
+				if err != nil {
+					return err
+				}
+				if err := ssv.Delete(r); err != nil {
+					return err
+				}
+				fmt.Printf("sequence %d deleted\n", r.ID)
+				return nil
+			})
+		},
+	}
+}
@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/strahe/suialert/bots"
+	"github.com/strahe/suialert/bots/email"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
+	"github.com/strahe/suialert/types"
+	"gorm.io/gorm"
+)
+
+func (c *command) initUsersCmd() {
+	cmd := &cobra.Command{
+		Use:   "users",
+		Short: "Manage the users",
+	}
+	cmd.AddCommand(c.usersListCmd(), c.usersEmailCmd(), c.usersDigestCmd(), c.usersScheduleCmd())
+	c.root.AddCommand(cmd)
+}
+
+func (c *command) usersListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the users",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+				var users []model.User
+				if err := db.WithContext(ctx).Order("id").Find(&users).Error; err != nil {
+					return err
+				}
+				for _, u := range users {
+					mail := "-"
+					if u.Email != nil {
+						mail = *u.Email
+						if !u.EmailVerified {
+							mail += " (unverified)"
+						}
+					}
+					fmt.Printf("%d  %s  %d rules  %s\n", u.ID, u.Name, u.RuleCount, mail)
+				}
+				return nil
+			})
+		},
+	}
+}
+
+func (c *command) usersEmailCmd() *cobra.Command {
+	var (
+		remove bool
+		test   bool
+	)
+	cmd := &cobra.Command{
+		Use:   "email <user-id> [address]",
+		Short: "Set the email address of a user, without verification",
+		Example: "  users email 3 alice@example.com --test\n" +
+			"  users email 3 --remove",
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid user id: %s", args[0])
+			}
+			if remove == (len(args) == 2) {
+				return fmt.Errorf("either an address or --remove is required")
+			}
+			cfg, err := c.Config()
+			if err != nil {
+				return err
+			}
+			return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+				usv := service.NewUserService(db)
+				u, err := usv.FindByID(uint(id))
+				if err != nil {
+					return err
+				}
+				var address *string
+				if !remove {
+					address = &args[1]
+				}
+				if err := usv.SetVerifiedEmail(u, address); err != nil {
+					return err
+				}
+				if remove {
+					fmt.Printf("email of user %d removed\n", u.ID)
+					return nil
+				}
+				fmt.Printf("alerts of user %d are sent to %s\n", u.ID, *u.Email)
+				if !test {
+					return nil
+				}
+				mailer, err := email.NewEmail(cfg.Bots.Email, bots.Explorer{URL: cfg.Sui.Explorer, Network: cfg.Sui.Network}, usv)
+				if err != nil {
+					return err
+				}
+				alert := &model.Alert{
+					UserID:    u.ID,
+					Event:     types.EventTypeCoinBalanceChange,
+					Severity:  model.SeverityInfo,
+					TxDigest:  "test",
+					Timestamp: uint64(time.Now().UnixMilli()),
+					CreatedAt: time.Now(),
+				}
+				if err := mailer.SendAlert(ctx, *u.Email, alert); err != nil {
+					return err
+				}
+				fmt.Printf("test alert sent to %s\n", *u.Email)
+				return nil
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&remove, "remove", false, "remove the email address")
+	cmd.Flags().BoolVar(&test, "test", false, "send a test alert to the address")
+	return cmd
+}
+
+func (c *command) usersDigestCmd() *cobra.Command {
+	var (
+		hour     int
+		timezone string
+	)
+	cmd := &cobra.Command{
+		Use:   "digest <user-id> <realtime|hourly|daily>",
+		Short: "Send the alerts of a user in an hourly or daily summary",
+		Example: "  users digest 3 daily --hour 8 --timezone Europe/Paris\n" +
+			"  users digest 3 realtime",
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid user id: %s", args[0])
+			}
+			mode := model.DigestMode(args[1])
+			if mode == model.DigestDefault {
+				return fmt.Errorf("unknown digest mode: %s", args[1])
+			}
+			return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+				usv := service.NewUserService(db)
+				u, err := usv.FindByID(uint(id))
+				if err != nil {
+					return err
+				}
+				if !cmd.Flags().Changed("timezone") {
+					timezone = u.Timezone
+				}
+				schedule := u.Schedule
+				schedule.Digest = mode
+				if cmd.Flags().Changed("hour") {
+					schedule.DigestHour = hour
+				}
+				if err := usv.UpdateSchedule(u, timezone, schedule); err != nil {
+					return err
+				}
+				switch mode {
+				case model.DigestDaily:
+					fmt.Printf("alerts of user %d are sent daily at %02d:00 %s\n", u.ID, schedule.DigestHour, u.Location())
+				default:
+					fmt.Printf("alerts of user %d are sent %s\n", u.ID, mode)
+				}
+				return nil
+			})
+		},
+	}
+	cmd.Flags().IntVar(&hour, "hour", 0, "hour of the day daily digests are sent at")
+	cmd.Flags().StringVar(&timezone, "timezone", "", "timezone of the user, e.g. Europe/Paris")
+	return cmd
+}
+
+func (c *command) usersScheduleCmd() *cobra.Command {
+	var (
+		weekdays string
+		quiet    string
+		timezone string
+	)
+	cmd := &cobra.Command{
+		Use:   "schedule <user-id>",
+		Short: "Set the days and the quiet hours alerts are sent to a user",
+		Long: "Set the days and the quiet hours alerts are sent to a user, in their timezone. " +
+			"The alerts of the other days are suppressed, the ones of the quiet hours are sent when they end. " +
+			"Without flags, the schedule is shown.",
+		Example: "  users schedule 3 --weekdays mon,tue,wed,thu,fri --quiet 22:00-07:00 --timezone Europe/Paris\n" +
+			"  users schedule 3 --weekdays all --quiet off",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid user id: %s", args[0])
+			}
+			return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+				usv := service.NewUserService(db)
+				u, err := usv.FindByID(uint(id))
+				if err != nil {
+					return err
+				}
+				schedule := u.Schedule
+				if cmd.Flags().Changed("weekdays") {
+					if schedule.Weekdays, err = model.ParseWeekdays(weekdays); err != nil {
+						return err
+					}
+				}
+				if cmd.Flags().Changed("quiet") {
+					if schedule.QuietStart, schedule.QuietEnd, err = model.ParseQuietHours(quiet); err != nil {
+						return err
+					}
+				}
+				if !cmd.Flags().Changed("timezone") {
+					timezone = u.Timezone
+				}
+				if cmd.Flags().Changed("weekdays") || cmd.Flags().Changed("quiet") || cmd.Flags().Changed("timezone") {
+					if err := usv.UpdateSchedule(u, timezone, schedule); err != nil {
+						return err
+					}
+				}
+				fmt.Printf("schedule of user %d: %s (%s)\n", u.ID, u.Schedule.String(), u.Location())
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&weekdays, "weekdays", "", "days alerts are sent on, e.g. mon,tue,wed, or all")
+	cmd.Flags().StringVar(&quiet, "quiet", "", "quiet hours, e.g. 22:00-07:00, or off")
+	cmd.Flags().StringVar(&timezone, "timezone", "", "timezone of the user, e.g. Europe/Paris")
+	return cmd
+}
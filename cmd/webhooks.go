@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/strahe/suialert/bots/webhook"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
+	"github.com/strahe/suialert/types"
+	"gorm.io/gorm"
+)
+
+func (c *command) initWebhooksCmd() {
+	cmd := &cobra.Command{
+		Use:   "webhooks",
+		Short: "Manage the webhooks the alerts are posted to",
+	}
+	cmd.AddCommand(c.webhooksAddCmd(), c.webhooksListCmd(), c.webhooksRemoveCmd(),
+		c.webhooksDeliveriesCmd(), c.webhooksTestCmd())
+	c.root.AddCommand(cmd)
+}
+
+func (c *command) webhooksAddCmd() *cobra.Command {
+	var w model.Webhook
+	cmd := &cobra.Command{
+		Use:   "add <url>",
+		Short: "Add a webhook",
+		Long: "Add a webhook. The alerts of its user are posted to it, the rules can also route their alerts to it\n" +
+			"with a webhook action or an escalation to the channel webhook:<id>.\n" +
+			"Webhooks without a user only receive the alerts routed to them.\n" +
+			"The requests are signed with the secret, generated if not set, in the " + webhook.HeaderSignature + " header.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			w.URL = args[0]
+			return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+				if w.UserID != 0 {
+					if _, err := service.NewUserService(db).FindByID(w.UserID); err != nil {
+						return fmt.Errorf("user %d: %w", w.UserID, err)
+					}
+				}
+				if err := service.NewWebhookService(db).Create(ctx, &w); err != nil {
+					return err
+				}
+				fmt.Printf("webhook %d added, secret: %s\n", w.ID, w.Secret)
+				return nil
+			})
+		},
+	}
+	cmd.Flags().UintVarP(&w.UserID, "user", "u", 0, "id of the user whose alerts are posted")
+	cmd.Flags().StringVar(&w.Name, "name", "", "name of the webhook")
+	cmd.Flags().StringVar(&w.Secret, "secret", "", "secret to sign the requests with, generated if not set")
+	return cmd
+}
+
+func (c *command) webhooksListCmd() *cobra.Command {
+	var uid uint
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the webhooks",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+				ws, err := service.NewWebhookService(db).FindByUser(ctx, uid)
+				if err != nil {
+					return err
+				}
+				for _, w := range ws {
+					owner := "no user"
+					if w.UserID != 0 {
+						owner = fmt.Sprintf("user %d", w.UserID)
+					}
+					fmt.Printf("%d  %s  %s  %s\n", w.ID, owner, w.URL, w.Name)
+				}
+				return nil
+			})
+		},
+	}
+	cmd.Flags().UintVarP(&uid, "user", "u", 0, "only the webhooks of this user")
+	return cmd
+}
+
+func (c *command) webhooksRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove <webhook-id>",
+		Short: "Remove a webhook",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid webhook id: %s", args[0])
+			}
+			return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+				wsv := service.NewWebhookService(db)
+				w, err := wsv.FindByID(ctx, uint(id))
+				if err != nil {
+					return err
+				}
+				if err := wsv.Delete(ctx, w); err != nil {
+					return err
+				}
+				fmt.Printf("webhook %d removed\n", w.ID)
+				return nil
+			})
+		},
+	}
+}
+
+func (c *command) webhooksDeliveriesCmd() *cobra.Command {
+	var limit int
+	cmd := &cobra.Command{
+		Use:   "deliveries <webhook-id>",
+		Short: "Show the last delivery attempts of a webhook",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid webhook id: %s", args[0])
+			}
+			return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+				ds, err := service.NewWebhookService(db).Deliveries(ctx, uint(id), limit)
+				if err != nil {
+					return err
+				}
+				for _, d := range ds {
+					result := fmt.Sprint(d.StatusCode)
+					if d.Error != "" {
+						result = d.Error
+					}
+					fmt.Printf("%s  %s  attempt %d  %s  %s  %s\n", d.CreatedAt.Local().Format(time.DateTime),
+						d.DeliveryID, d.Attempt, d.Source, d.Duration.Round(time.Millisecond), result)
+				}
+				return nil
+			})
+		},
+	}
+	cmd.Flags().IntVarP(&limit, "limit", "n", 20, "number of attempts")
+	return cmd
+}
+
+func (c *command) webhooksTestCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "test <webhook-id>",
+		Short: "Post a test alert to a webhook",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid webhook id: %s", args[0])
+			}
+			cfg, err := c.Config()
+			if err != nil {
+				return err
+			}
+			return c.withDB(func(ctx context.Context, db *gorm.DB) error {
+				wsv := service.NewWebhookService(db)
+				w, err := wsv.FindByID(ctx, uint(id))
+				if err != nil {
+					return err
+				}
+				alert := &model.Alert{
+					UserID:    w.UserID,
+					Event:     types.EventTypeCoinBalanceChange,
+					Severity:  model.SeverityInfo,
+					TxDigest:  "test",
+					Timestamp: uint64(time.Now().UnixMilli()),
+					CreatedAt: time.Now(),
+				}
+				if err := webhook.NewWebhook(cfg.Bots.Webhook, cfg.Sui.Network, wsv, service.NewOutboxService(db)).Deliver(ctx, w, alert); err != nil {
+					return err
+				}
+				fmt.Printf("test alert delivered to webhook %d\n", w.ID)
+				return nil
+			})
+		},
+	}
+}
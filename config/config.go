@@ -1,24 +1,69 @@
 package config
 
+import (
+	"time"
+)
+
 type Config struct {
 	// Enable Debug model
 	Debug bool `yaml:"debug" json:"debug" mapstructure:"debug"`
 
+	// Evaluate the rules and record their matches without sending any notification
+	DryRun bool `yaml:"dry_run" json:"dry_run" mapstructure:"dry_run"`
+
 	Sui SuiConfig `yaml:"sui" json:"sui" mapstructure:"sui"`
 
 	Bots BotsConfig `yaml:"bots" json:"bots" mapstructure:"bots"`
 
 	Database DatabaseConfig `yaml:"database" json:"database" mapstructure:"database"`
+
+	Alerts AlertsConfig `yaml:"alerts" json:"alerts" mapstructure:"alerts"`
+
+	Anomaly AnomalyConfig `yaml:"anomaly" json:"anomaly" mapstructure:"anomaly"`
+
+	Rules RulesConfig `yaml:"rules" json:"rules" mapstructure:"rules"`
+
+	Checks ChecksConfig `yaml:"checks" json:"checks" mapstructure:"checks"`
+
+	Queries QueriesConfig `yaml:"queries" json:"queries" mapstructure:"queries"`
+
+	Watchlists []WatchlistConfig `yaml:"watchlists" json:"watchlists" mapstructure:"watchlists"`
 }
 
 type SuiConfig struct {
 	Endpoint string `yaml:"endpoint" json:"endpoint" mapstructure:"endpoint"`
+	// Name of the network, sent with the alerts to the webhooks
+	Network string `yaml:"network" json:"network" mapstructure:"network"`
+	// Explorer linked from the alerts, with the network as a query parameter
+	Explorer string `yaml:"explorer" json:"explorer" mapstructure:"explorer"`
 	// Event type to subscribe
 	EventTypes []string `yaml:"event_types" json:"event_types" mapstructure:"event_types"`
+	// Symbol and decimals of the coins, SUI is known
+	Coins []CoinConfig `yaml:"coins" json:"coins" mapstructure:"coins"`
+}
+
+type CoinConfig struct {
+	// Coin type, e.g. 0x2::sui::SUI
+	Type     string `yaml:"type" json:"type" mapstructure:"type"`
+	Symbol   string `yaml:"symbol" json:"symbol" mapstructure:"symbol"`
+	Decimals uint8  `yaml:"decimals" json:"decimals" mapstructure:"decimals"`
+	// Price used by the ValueUSD helper of the conditions
+	PriceUSD float64 `yaml:"price_usd" json:"price_usd" mapstructure:"price_usd"`
+}
+
+// WatchlistConfig is a named list of addresses, for the InWatchlist helper of the conditions.
+type WatchlistConfig struct {
+	Name      string   `yaml:"name" json:"name" mapstructure:"name"`
+	Addresses []string `yaml:"addresses" json:"addresses" mapstructure:"addresses"`
 }
 
 type BotsConfig struct {
-	Discord DiscordBotConfig `yaml:"discord" json:"discord" mapstructure:"discord"`
+	Discord  DiscordBotConfig  `yaml:"discord" json:"discord" mapstructure:"discord"`
+	Telegram TelegramBotConfig `yaml:"telegram" json:"telegram" mapstructure:"telegram"`
+	Webhook  WebhookBotConfig  `yaml:"webhook" json:"webhook" mapstructure:"webhook"`
+	Slack    IncomingBotConfig `yaml:"slack" json:"slack" mapstructure:"slack"`
+	Teams    IncomingBotConfig `yaml:"teams" json:"teams" mapstructure:"teams"`
+	Email    EmailBotConfig    `yaml:"email" json:"email" mapstructure:"email"`
 }
 
 type DiscordBotConfig struct {
@@ -27,6 +72,46 @@ type DiscordBotConfig struct {
 	Token  string `yaml:"token" json:"token" mapstructure:"token"`
 }
 
+type TelegramBotConfig struct {
+	Enable bool   `yaml:"enable" json:"enable" mapstructure:"enable"`
+	Token  string `yaml:"token" json:"token" mapstructure:"token"`
+}
+
+// WebhookBotConfig configures the delivery of the alerts to the registered webhooks.
+type WebhookBotConfig struct {
+	Enable bool `yaml:"enable" json:"enable" mapstructure:"enable"`
+	// Timeout of a request, the failed deliveries are retried by the outbox
+	Timeout time.Duration `yaml:"timeout" json:"timeout" mapstructure:"timeout"`
+}
+
+// IncomingBotConfig configures the alerts posted to the incoming webhooks of a chat platform.
+type IncomingBotConfig struct {
+	Enable bool `yaml:"enable" json:"enable" mapstructure:"enable"`
+	// Timeout of a request
+	Timeout time.Duration `yaml:"timeout" json:"timeout" mapstructure:"timeout"`
+	// Incoming webhook urls by channel name, the alerts are routed to a channel with the destination "<platform>:<name>"
+	Channels map[string]string `yaml:"channels" json:"channels" mapstructure:"channels"`
+}
+
+// EmailBotConfig configures the alerts sent by email through an SMTP server.
+type EmailBotConfig struct {
+	Enable   bool   `yaml:"enable" json:"enable" mapstructure:"enable"`
+	Host     string `yaml:"host" json:"host" mapstructure:"host"`
+	Port     int    `yaml:"port" json:"port" mapstructure:"port"`
+	Username string `yaml:"username" json:"username" mapstructure:"username"`
+	Password string `yaml:"password" json:"password" mapstructure:"password"`
+	// From is the sender address, e.g. "suialert <alerts@example.com>"
+	From string `yaml:"from" json:"from" mapstructure:"from"`
+	// TLS is the security of the connection: starttls, tls or none
+	TLS string `yaml:"tls" json:"tls" mapstructure:"tls"`
+	// InsecureSkipVerify accepts any certificate of the server
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify" json:"insecure_skip_verify" mapstructure:"insecure_skip_verify"`
+	// Timeout of the delivery of an email
+	Timeout time.Duration `yaml:"timeout" json:"timeout" mapstructure:"timeout"`
+	// Digest batches the alerts of a recipient into one email within this interval, 0 sends them immediately
+	Digest time.Duration `yaml:"digest" json:"digest" mapstructure:"digest"`
+}
+
 // DatabaseConfig
 // https://gorm.io/docs/connecting_to_the_database.html
 type DatabaseConfig struct {
@@ -35,3 +120,99 @@ type DatabaseConfig struct {
 	// Database connection string
 	DSN string `yaml:"dsn" json:"dsn" mapstructure:"dsn"`
 }
+
+// AlertsConfig configures the delivery of the alerts.
+type AlertsConfig struct {
+	RateLimit RateLimitConfig `yaml:"rate_limit" json:"rate_limit" mapstructure:"rate_limit"`
+	Outbox    OutboxConfig    `yaml:"outbox" json:"outbox" mapstructure:"outbox"`
+}
+
+// OutboxConfig configures the delivery of the alerts, they are stored in the outbox before being sent,
+// and retried with an exponential backoff until delivered or dead-lettered.
+type OutboxConfig struct {
+	// Attempts before a notification is dead-lettered
+	MaxAttempts int `yaml:"max_attempts" json:"max_attempts" mapstructure:"max_attempts"`
+	// Delay before the first retry, doubled for each of the next ones up to MaxBackoff, without limit if 0
+	Backoff    time.Duration `yaml:"backoff" json:"backoff" mapstructure:"backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff" json:"max_backoff" mapstructure:"max_backoff"`
+	// Interval the outbox is polled at for the retries
+	Interval time.Duration `yaml:"interval" json:"interval" mapstructure:"interval"`
+	// Number of destinations delivered to at once
+	Workers int `yaml:"workers" json:"workers" mapstructure:"workers"`
+}
+
+// RateLimitConfig limits the alerts sent to a user or a channel within a window, by severity.
+// Alerts over the limit are dropped, 0 is unlimited.
+type RateLimitConfig struct {
+	Window   time.Duration `yaml:"window" json:"window" mapstructure:"window"`
+	Info     int           `yaml:"info" json:"info" mapstructure:"info"`
+	Warning  int           `yaml:"warning" json:"warning" mapstructure:"warning"`
+	Critical int           `yaml:"critical" json:"critical" mapstructure:"critical"`
+}
+
+// AnomalyConfig configures the detection of unusual activity of the watched addresses.
+type AnomalyConfig struct {
+	Enable bool `yaml:"enable" json:"enable" mapstructure:"enable"`
+	// Days of history the baseline of an address is learned from
+	WindowDays int `yaml:"window_days" json:"window_days" mapstructure:"window_days"`
+	// Days of history an address needs before alerts are raised
+	WarmupDays int `yaml:"warmup_days" json:"warmup_days" mapstructure:"warmup_days"`
+	// Z-score above which an activity is unusual, lower is more sensitive
+	Threshold float64 `yaml:"threshold" json:"threshold" mapstructure:"threshold"`
+	// How often the addresses are checked
+	Interval time.Duration `yaml:"interval" json:"interval" mapstructure:"interval"`
+}
+
+// RulesConfig configures the static rules, system-wide rules managed as code.
+type RulesConfig struct {
+	// Directory of yaml or json rule files, reloaded when they change
+	Dir string `yaml:"dir" json:"dir" mapstructure:"dir"`
+	// Channel of the rules without one
+	Channel string `yaml:"channel" json:"channel" mapstructure:"channel"`
+	// Rules of the config
+	Static []StaticRuleConfig `yaml:"static" json:"static" mapstructure:"static"`
+}
+
+// StaticRuleConfig is a static rule of the config, the fields are the ones of the rule files.
+type StaticRuleConfig struct {
+	Name      string `yaml:"name" json:"name" mapstructure:"name"`
+	Address   string `yaml:"address" json:"address" mapstructure:"address"`
+	Event     string `yaml:"event" json:"event" mapstructure:"event"`
+	Condition string `yaml:"condition" json:"condition" mapstructure:"condition"`
+	Salience  int    `yaml:"salience" json:"salience" mapstructure:"salience"`
+	// Channel the alerts are sent to, the channel of the rules if empty
+	Channel    string            `yaml:"channel" json:"channel" mapstructure:"channel"`
+	Severity   string            `yaml:"severity" json:"severity" mapstructure:"severity"`
+	Escalation *EscalationConfig `yaml:"escalation" json:"escalation" mapstructure:"escalation"`
+	Actions    []ActionConfig    `yaml:"actions" json:"actions" mapstructure:"actions"`
+}
+
+// EscalationConfig re-sends the alerts not acknowledged in time.
+type EscalationConfig struct {
+	After   time.Duration `yaml:"after" json:"after" mapstructure:"after"`
+	Channel string        `yaml:"channel" json:"channel" mapstructure:"channel"`
+	UserID  uint          `yaml:"user_id" json:"user_id" mapstructure:"user_id"`
+}
+
+// ActionConfig is an action of a static rule: notify, tag, webhook, escalate or stop.
+type ActionConfig struct {
+	Type     string `yaml:"type" json:"type" mapstructure:"type"`
+	Severity string `yaml:"severity" json:"severity" mapstructure:"severity"`
+	Tag      string `yaml:"tag" json:"tag" mapstructure:"tag"`
+	Webhook  uint   `yaml:"webhook" json:"webhook" mapstructure:"webhook"`
+	Channel  string `yaml:"channel" json:"channel" mapstructure:"channel"`
+}
+
+// ChecksConfig configures the scheduled checks, rules firing when an expected activity is missing.
+type ChecksConfig struct {
+	// How often the checks are evaluated
+	Interval time.Duration `yaml:"interval" json:"interval" mapstructure:"interval"`
+}
+
+// QueriesConfig configures the query rules, SQL queries over the stored events run on a schedule.
+type QueriesConfig struct {
+	// Maximum duration of a query
+	Timeout time.Duration `yaml:"timeout" json:"timeout" mapstructure:"timeout"`
+	// Maximum number of rows read from a result
+	MaxRows int `yaml:"max_rows" json:"max_rows" mapstructure:"max_rows"`
+}
@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 const (
 	DevNetRpcUrl = "wss://fullnode.devnet.sui.io"
 )
@@ -9,10 +11,60 @@ var DefaultConfig = Config{
 
 	Sui: SuiConfig{
 		Endpoint: DevNetRpcUrl,
+		Network:  "devnet",
+		Explorer: "https://suiexplorer.com",
+	},
+
+	Bots: BotsConfig{
+		Webhook: WebhookBotConfig{
+			Timeout: 10 * time.Second,
+		},
+		Slack: IncomingBotConfig{
+			Timeout: 10 * time.Second,
+		},
+		Teams: IncomingBotConfig{
+			Timeout: 10 * time.Second,
+		},
+		Email: EmailBotConfig{
+			Port:    587,
+			TLS:     "starttls",
+			Timeout: 30 * time.Second,
+		},
 	},
 
 	Database: DatabaseConfig{
 		Driver: "sqlite3",
 		DSN:    "db.sqlite3",
 	},
+
+	Alerts: AlertsConfig{
+		RateLimit: RateLimitConfig{
+			Window:  time.Minute,
+			Info:    10,
+			Warning: 30,
+		},
+		Outbox: OutboxConfig{
+			MaxAttempts: 10,
+			Backoff:     5 * time.Second,
+			MaxBackoff:  15 * time.Minute,
+			Interval:    5 * time.Second,
+			Workers:     8,
+		},
+	},
+
+	Anomaly: AnomalyConfig{
+		WindowDays: 14,
+		WarmupDays: 7,
+		Threshold:  3,
+		Interval:   time.Hour,
+	},
+
+	Checks: ChecksConfig{
+		Interval: time.Minute,
+	},
+
+	Queries: QueriesConfig{
+		Timeout: 10 * time.Second,
+		MaxRows: 100,
+	},
 }
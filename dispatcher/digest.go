@@ -0,0 +1,100 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/rule"
+	"github.com/strahe/suialert/service"
+	"github.com/strahe/suialert/types"
+	"go.uber.org/zap"
+)
+
+// digestAt returns the time the digest of an alert is sent, false if the alert is sent in real time.
+// The mode of the rule applies first, then the mode of the user for the alerts sent to them.
+// Alerts waiting for an acknowledgement are always sent in real time.
+func (d *Dispatcher) digestAt(alert *model.Alert) (model.DigestMode, time.Time, error) {
+	if alert.Event == types.EventTypeDigest || alert.Escalation != nil || alert.Escalated {
+		return model.DigestRealtime, time.Time{}, nil
+	}
+	u, err := d.usv.FindByID(alert.UserID)
+	if err != nil && !errors.Is(err, service.ErrNotFound) {
+		return "", time.Time{}, err
+	}
+	mode := alert.Digest
+	if mode == model.DigestDefault && alert.Destination == "" && u != nil {
+		mode = u.Schedule.Digest
+	}
+	if !mode.Batched() {
+		return model.DigestRealtime, time.Time{}, nil
+	}
+	now, hour := time.Now(), 0
+	if u != nil {
+		now, hour = now.In(u.Location()), u.Schedule.DigestHour
+	}
+	return mode, mode.Next(now, hour), nil
+}
+
+func (d *Dispatcher) collect(ctx context.Context, alert *model.Alert, mode model.DigestMode, at time.Time) error {
+	zap.S().Debugf("adding alert of %s to the %s digest of %s", alert.Source(), mode, alert.Recipient())
+	return service.Conn(ctx, d.db).Create(&model.DigestEntry{
+		Recipient: alert.Recipient(),
+		Mode:      mode,
+		Alert:     *alert,
+		SendAt:    at.UTC(),
+	}).Error
+}
+
+// flush sends the digests which are due, one per recipient and period.
+func (d *Dispatcher) flush(ctx context.Context) error {
+	var entries []model.DigestEntry
+	if err := d.db.WithContext(ctx).Where("send_at <= ?", time.Now().UTC()).
+		Order("id").Find(&entries).Error; err != nil {
+		return err
+	}
+	type key struct {
+		recipient string
+		mode      model.DigestMode
+		at        time.Time
+	}
+	var (
+		keys   []key
+		groups = map[key][]model.DigestEntry{}
+	)
+	for _, e := range entries {
+		k := key{e.Recipient, e.Mode, e.SendAt.UTC()}
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], e)
+	}
+	for _, k := range keys {
+		group := groups[k]
+		alerts := make([]model.Alert, len(group))
+		ids := make([]uint, len(group))
+		for i := range group {
+			alerts[i], ids[i] = group[i].Alert, group[i].ID
+		}
+		// the period is shown in the timezone of the user
+		until := k.at
+		if u, err := d.usv.FindByID(alerts[0].UserID); err == nil {
+			until = until.In(u.Location())
+		}
+		alert, err := model.NewDigestAlert(k.mode, until, alerts)
+		if err != nil {
+			return err
+		}
+		// the digest follows the schedule of the user, but not the rate limits
+		err = d.Notify(ctx, alert)
+		if rule.IsFailure(err) {
+			zap.S().Errorf("failed to send the %s digest of %s: %s", k.mode, k.recipient, err)
+			continue
+		}
+		if err := d.db.WithContext(ctx).Delete(&model.DigestEntry{}, ids).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
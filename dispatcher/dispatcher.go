@@ -0,0 +1,188 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/strahe/suialert/config"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/rule"
+	"github.com/strahe/suialert/service"
+	"github.com/strahe/suialert/types"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const releaseInterval = time.Minute
+
+// Dispatcher applies the rate limits and the schedule of the users to the alerts before passing them to the notifier.
+// Alerts over the rate limit of their severity are dropped with rule.ErrSuppressed, as are
+// alerts sent to a user outside of the days of their schedule.
+// Alerts sent during their quiet hours are held with rule.ErrHeld and sent when the quiet period ends.
+// Alerts with a destination are sent right away.
+// Alerts with an escalation policy are escalated when they are not acknowledged in time.
+// Alerts of the rules and the users in digest mode are held with rule.ErrHeld and sent in a digest at the end of the period.
+type Dispatcher struct {
+	next  rule.Notifier
+	db    *gorm.DB
+	usv   *service.UserService
+	asv   *service.AckService
+	limit *limiter
+
+	done chan struct{}
+}
+
+// NewDispatcher creates a dispatcher, alerts are only logged if next is nil.
+func NewDispatcher(next rule.Notifier, db *gorm.DB, usv *service.UserService, asv *service.AckService,
+	limits config.RateLimitConfig) *Dispatcher {
+	if next == nil {
+		next = rule.LogNotifier{}
+	}
+	return &Dispatcher{
+		next:  next,
+		db:    db,
+		usv:   usv,
+		asv:   asv,
+		limit: newLimiter(limits),
+		done:  make(chan struct{}),
+	}
+}
+
+func (d *Dispatcher) Start(context.Context) error {
+	go d.loop()
+	return nil
+}
+
+func (d *Dispatcher) Close(context.Context) error {
+	close(d.done)
+	return nil
+}
+
+func (d *Dispatcher) Notify(ctx context.Context, alert *model.Alert) error {
+	mode, at, err := d.digestAt(alert)
+	if err != nil {
+		return err
+	}
+	if mode.Batched() {
+		if err := d.collect(ctx, alert, mode, at); err != nil {
+			return err
+		}
+		return rule.ErrHeld
+	}
+	if alert.Event != types.EventTypeDigest && !d.limit.allow(alert, time.Now()) {
+		zap.S().Debugf("dropped %s alert of %s, rate limit of %s reached", alert.Severity, alert.Source(), recipient(alert))
+		return rule.ErrSuppressed
+	}
+	if alert.Destination != "" {
+		return d.send(ctx, alert)
+	}
+	u, err := d.usv.FindByID(alert.UserID)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return d.send(ctx, alert)
+		}
+		return err
+	}
+	now := time.Now().In(u.Location())
+	if !u.Schedule.ActiveOn(now) {
+		zap.S().Debugf("dropped alert of rule %d, user %d is not active on %s", alert.RuleID, u.ID, now.Weekday())
+		return rule.ErrSuppressed
+	}
+	if until, ok := u.Schedule.QuietUntil(now); ok {
+		if err := d.hold(ctx, alert, until); err != nil {
+			return err
+		}
+		return rule.ErrHeld
+	}
+	return d.send(ctx, alert)
+}
+
+// send passes the alert to the notifier, waiting for its acknowledgement if it has an escalation policy.
+func (d *Dispatcher) send(ctx context.Context, alert *model.Alert) error {
+	if alert.Escalation != nil && alert.AckID == 0 && !alert.Escalated {
+		if err := d.asv.Create(ctx, alert, time.Now()); err != nil {
+			return err
+		}
+	}
+	return d.next.Notify(ctx, alert)
+}
+
+func (d *Dispatcher) hold(ctx context.Context, alert *model.Alert, until time.Time) error {
+	zap.S().Debugf("holding alert of rule %d until %s", alert.RuleID, until)
+	return service.Conn(ctx, d.db).Create(&model.HeldAlert{
+		UserID:    alert.UserID,
+		Alert:     *alert,
+		ReleaseAt: until.UTC(),
+	}).Error
+}
+
+func (d *Dispatcher) loop() {
+	ticker := time.NewTicker(releaseInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+			if err := d.release(context.Background()); err != nil {
+				zap.S().Errorf("failed to release held alerts: %s", err)
+			}
+			if err := d.escalate(context.Background()); err != nil {
+				zap.S().Errorf("failed to escalate alerts: %s", err)
+			}
+			if err := d.flush(context.Background()); err != nil {
+				zap.S().Errorf("failed to send digests: %s", err)
+			}
+			d.limit.prune(time.Now())
+		}
+	}
+}
+
+// release sends the held alerts whose quiet period is over.
+func (d *Dispatcher) release(ctx context.Context) error {
+	var held []model.HeldAlert
+	if err := d.db.WithContext(ctx).Where("release_at <= ?", time.Now().UTC()).
+		Order("id").Find(&held).Error; err != nil {
+		return err
+	}
+	for i := range held {
+		if err := d.send(ctx, &held[i].Alert); rule.IsFailure(err) {
+			zap.S().Errorf("failed to send held alert %d: %s", held[i].ID, err)
+			continue
+		}
+		if err := d.db.WithContext(ctx).Delete(&held[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// escalate sends the alerts not acknowledged in time to the destination of their escalation policy.
+func (d *Dispatcher) escalate(ctx context.Context) error {
+	now := time.Now()
+	acks, err := d.asv.Due(ctx, now)
+	if err != nil {
+		return err
+	}
+	for i := range acks {
+		alert := acks[i].Alert
+		alert.AckID = acks[i].ID
+		alert.Escalated = true
+		if alert.Escalation.Channel != "" {
+			alert.Destination = alert.Escalation.Channel
+		} else {
+			alert.UserID = alert.Escalation.UserID
+			alert.Destination = ""
+		}
+		if err := d.next.Notify(ctx, &alert); rule.IsFailure(err) {
+			zap.S().Errorf("failed to escalate alert %d: %s", acks[i].ID, err)
+			continue
+		}
+		if err := d.asv.MarkEscalated(ctx, &acks[i], now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package dispatcher_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/strahe/suialert/config"
+	"github.com/strahe/suialert/dispatcher"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/rule"
+	"github.com/strahe/suialert/service"
+	"github.com/strahe/suialert/types"
+	"gorm.io/gorm"
+)
+
+func newDispatcher(db *gorm.DB, limits config.RateLimitConfig) (*dispatcher.Dispatcher, *flaky) {
+	f := &flaky{fails: map[string]int{}, got: map[string][]uint{}}
+	return dispatcher.NewDispatcher(f, db, service.NewUserService(db), service.NewAckService(db), limits), f
+}
+
+func TestQuietHours(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	d, f := newDispatcher(db, config.RateLimitConfig{})
+
+	// quiet hours from an hour ago to an hour from now, in the timezone of the user
+	loc, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().In(loc)
+	quiet := &model.User{ID: 1, Timezone: loc.String(), Schedule: model.Schedule{
+		QuietStart: now.Add(-time.Hour).Format("15:04"),
+		QuietEnd:   now.Add(time.Hour).Format("15:04"),
+	}}
+	// active only tomorrow
+	away := &model.User{ID: 2, Schedule: model.Schedule{Weekdays: []time.Weekday{time.Now().UTC().AddDate(0, 0, 1).Weekday()}}}
+	if err := db.Create([]*model.User{quiet, away}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Notify(ctx, testAlert(1, "")); !errors.Is(err, rule.ErrHeld) {
+		t.Fatalf("notify during quiet hours: %v, want %v", err, rule.ErrHeld)
+	}
+	// alerts with a destination are sent right away
+	if err := d.Notify(ctx, testAlert(2, "discord:1")); err != nil {
+		t.Fatalf("notify a destination: %v", err)
+	}
+	alert := testAlert(3, "")
+	alert.UserID = away.ID
+	if err := d.Notify(ctx, alert); !errors.Is(err, rule.ErrSuppressed) {
+		t.Fatalf("notify on an inactive day: %v, want %v", err, rule.ErrSuppressed)
+	}
+	if got := f.delivered(""); len(got) != 0 {
+		t.Fatalf("alerts %v sent to the users", got)
+	}
+
+	var held []model.HeldAlert
+	if err := db.Find(&held).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(held) != 1 || held[0].Alert.RuleID != 1 {
+		t.Fatalf("unexpected held alerts %+v", held)
+	}
+	if want := now.Add(time.Hour).Truncate(time.Minute); !held[0].ReleaseAt.Equal(want) {
+		t.Errorf("released at %s, want %s", held[0].ReleaseAt, want)
+	}
+
+	// not released before the end of the quiet hours
+	if err := d.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.delivered(""); len(got) != 0 {
+		t.Fatalf("alerts %v released during the quiet hours", got)
+	}
+	if err := db.Model(&held[0]).Update("release_at", time.Now().UTC()).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.delivered(""); len(got) != 1 || got[0] != 1 {
+		t.Fatalf("released alerts %v, want [1]", got)
+	}
+	var count int64
+	if db.Model(&model.HeldAlert{}).Count(&count); count != 0 {
+		t.Errorf("%d alerts still held", count)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	const window = 200 * time.Millisecond
+	d, f := newDispatcher(db, config.RateLimitConfig{Window: window, Info: 2, Critical: 1})
+
+	notify := func(ruleID uint, dest string, severity model.Severity) error {
+		alert := testAlert(ruleID, dest)
+		alert.Severity = severity
+		return d.Notify(ctx, alert)
+	}
+	for _, tc := range []struct {
+		name       string
+		ruleID     uint
+		dest       string
+		severity   model.Severity
+		suppressed bool
+	}{
+		{"first info", 1, "", model.SeverityInfo, false},
+		{"second info", 2, "", model.SeverityInfo, false},
+		{"info over the limit", 3, "", model.SeverityInfo, true},
+		// each severity has its own limit
+		{"first critical", 4, "", model.SeverityCritical, false},
+		{"critical over the limit", 5, "", model.SeverityCritical, true},
+		// and each recipient
+		{"info to a channel", 6, "discord:1", model.SeverityInfo, false},
+		// warnings are not limited
+		{"warning", 7, "", model.SeverityWarning, false},
+	} {
+		err := notify(tc.ruleID, tc.dest, tc.severity)
+		if suppressed := errors.Is(err, rule.ErrSuppressed); suppressed != tc.suppressed || (err != nil && !suppressed) {
+			t.Fatalf("%s: %v, suppressed %t", tc.name, err, tc.suppressed)
+		}
+	}
+	if got := f.delivered(""); len(got) != 4 {
+		t.Fatalf("alerts %v sent to the user, want 4", got)
+	}
+
+	// the limits start again with the next window
+	time.Sleep(window)
+	if err := notify(8, "", model.SeverityInfo); err != nil {
+		t.Fatalf("notify in the next window: %v", err)
+	}
+	if err := notify(9, "", model.SeverityCritical); err != nil {
+		t.Fatalf("notify in the next window: %v", err)
+	}
+}
+
+func TestEscalation(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	d, f := newDispatcher(db, config.RateLimitConfig{})
+	asv := service.NewAckService(db)
+	f.fails["oncall"] = 1
+
+	escalated := testAlert(1, "")
+	escalated.Escalation = &model.Escalation{After: time.Minute, Channel: "oncall"}
+	acked := testAlert(2, "")
+	acked.Escalation = &model.Escalation{After: time.Minute, Channel: "oncall"}
+	for _, alert := range []*model.Alert{escalated, acked} {
+		if err := d.Notify(ctx, alert); err != nil {
+			t.Fatal(err)
+		}
+		if alert.AckID == 0 {
+			t.Fatalf("alert of rule %d is not waiting for an acknowledgement", alert.RuleID)
+		}
+	}
+	if got := f.delivered(""); len(got) != 2 {
+		t.Fatalf("alerts %v sent to the user, want 2", got)
+	}
+	if _, err := asv.Acknowledge(ctx, acked.AckID, acked.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	// not escalated before the delay
+	if err := d.Escalate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.delivered("oncall"); len(got) != 0 {
+		t.Fatalf("alerts %v escalated before the delay", got)
+	}
+	if err := db.Model(&model.AlertAck{}).Where("1 = 1").Update("due_at", time.Now().UTC().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// a failed escalation is tried again, the acknowledged alert is not escalated
+	for i := 0; i < 3; i++ {
+		if err := d.Escalate(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := f.delivered("oncall"); len(got) != 1 || got[0] != 1 {
+		t.Fatalf("escalated alerts %v, want [1]", got)
+	}
+	var ack model.AlertAck
+	if err := db.First(&ack, escalated.AckID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if ack.EscalatedAt == nil {
+		t.Error("escalation not recorded")
+	}
+}
+
+func TestDigest(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	d, f := newDispatcher(db, config.RateLimitConfig{Window: time.Hour, Info: 1})
+	if err := db.Create(&model.User{ID: 1, Schedule: model.Schedule{Digest: model.DigestHourly}}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// the alerts of the user follow their mode, the ones of a channel the mode of their rule
+	for i := uint(1); i <= 3; i++ {
+		if err := d.Notify(ctx, testAlert(i, "")); !errors.Is(err, rule.ErrHeld) {
+			t.Fatalf("notify: %v, want %v", err, rule.ErrHeld)
+		}
+	}
+	hourly := testAlert(4, "discord:1")
+	hourly.Digest = model.DigestHourly
+	if err := d.Notify(ctx, hourly); !errors.Is(err, rule.ErrHeld) {
+		t.Fatalf("notify a channel: %v, want %v", err, rule.ErrHeld)
+	}
+	// reaches the rate limit of the channel
+	if err := d.Notify(ctx, testAlert(5, "discord:1")); err != nil {
+		t.Fatalf("notify a channel in real time: %v", err)
+	}
+
+	var entries []model.DigestEntry
+	if err := db.Order("id").Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("%d alerts in digests, want 4", len(entries))
+	}
+	if want := model.DigestHourly.Next(time.Now(), 0); !entries[0].SendAt.Equal(want) {
+		t.Errorf("digest sent at %s, want %s", entries[0].SendAt, want)
+	}
+
+	// not sent before the end of the period
+	if err := d.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.sent) != 1 {
+		t.Fatalf("%d alerts sent before the end of the period, want 1", len(f.sent))
+	}
+	if err := db.Model(&model.DigestEntry{}).Where("1 = 1").Update("send_at", time.Now().UTC().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// one digest per recipient, not subject to the rate limits
+	counts := map[string]int{}
+	for _, alert := range f.sent[1:] {
+		if alert.Event != types.EventTypeDigest {
+			t.Fatalf("unexpected alert of %s", alert.Source())
+		}
+		counts[alert.Recipient()] = alert.Data.(*types.Summary).Count
+	}
+	if len(counts) != 2 || counts["user:1"] != 3 || counts["discord:1"] != 1 {
+		t.Fatalf("unexpected digests %v", counts)
+	}
+	var count int64
+	if db.Model(&model.DigestEntry{}).Count(&count); count != 0 {
+		t.Errorf("%d alerts still waiting for a digest", count)
+	}
+}
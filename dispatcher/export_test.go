@@ -0,0 +1,18 @@
+package dispatcher
+
+import "context"
+
+// Release sends the held alerts due, as done every minute.
+func (d *Dispatcher) Release(ctx context.Context) error {
+	return d.release(ctx)
+}
+
+// Escalate sends the alerts not acknowledged in time, as done every minute.
+func (d *Dispatcher) Escalate(ctx context.Context) error {
+	return d.escalate(ctx)
+}
+
+// Flush sends the digests due, as done every minute.
+func (d *Dispatcher) Flush(ctx context.Context) error {
+	return d.flush(ctx)
+}
@@ -0,0 +1,85 @@
+package dispatcher
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/strahe/suialert/config"
+	"github.com/strahe/suialert/model"
+)
+
+type limitKey struct {
+	recipient string
+	severity  model.Severity
+}
+
+type limitWindow struct {
+	start time.Time
+	count int
+}
+
+// limiter counts the alerts of each severity sent to a recipient within fixed windows.
+type limiter struct {
+	cfg config.RateLimitConfig
+
+	lk      sync.Mutex
+	windows map[limitKey]*limitWindow
+}
+
+func newLimiter(cfg config.RateLimitConfig) *limiter {
+	return &limiter{
+		cfg:     cfg,
+		windows: map[limitKey]*limitWindow{},
+	}
+}
+
+func (l *limiter) limit(s model.Severity) int {
+	switch s {
+	case model.SeverityCritical:
+		return l.cfg.Critical
+	case model.SeverityWarning:
+		return l.cfg.Warning
+	}
+	return l.cfg.Info
+}
+
+// allow reports whether the alert is within the limit of its severity, and counts it if so.
+func (l *limiter) allow(alert *model.Alert, now time.Time) bool {
+	max := l.limit(alert.Severity)
+	if max <= 0 || l.cfg.Window <= 0 {
+		return true
+	}
+	key := limitKey{recipient: recipient(alert), severity: alert.Severity}
+
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.cfg.Window {
+		w = &limitWindow{start: now}
+		l.windows[key] = w
+	}
+	if w.count >= max {
+		return false
+	}
+	w.count++
+	return true
+}
+
+// prune forgets the windows which are over.
+func (l *limiter) prune(now time.Time) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	for k, w := range l.windows {
+		if now.Sub(w.start) >= l.cfg.Window {
+			delete(l.windows, k)
+		}
+	}
+}
+
+func recipient(alert *model.Alert) string {
+	if alert.Destination != "" {
+		return "channel:" + alert.Destination
+	}
+	return fmt.Sprintf("user:%d", alert.UserID)
+}
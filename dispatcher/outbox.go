@@ -0,0 +1,168 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/strahe/suialert/bots"
+	"github.com/strahe/suialert/config"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/rule"
+	"github.com/strahe/suialert/service"
+	"go.uber.org/zap"
+)
+
+// Outbox stores the alerts before passing them to the notifier, so that they are not lost
+// when it is down or rate limited. Notify returns rule.ErrQueued once the alert is stored, in the
+// transaction of the context if any, see service.WithTx, and the match of the alert is marked
+// delivered or dead once the sender is done with it.
+// A sender delivers the stored alerts one at a time for each destination, in order.
+// Failed deliveries are retried with an exponential backoff, and dead-lettered after MaxAttempts,
+// or right away if their user cannot be reached by any notifier.
+type Outbox struct {
+	next rule.Notifier
+	osv  *service.OutboxService
+	cfg  config.OutboxConfig
+
+	// kick wakes the sender up when an alert is stored
+	kick   chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewOutbox creates an outbox, alerts are only logged if next is nil.
+func NewOutbox(next rule.Notifier, osv *service.OutboxService, cfg config.OutboxConfig) *Outbox {
+	if next == nil {
+		next = rule.LogNotifier{}
+	}
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	return &Outbox{
+		next: next,
+		osv:  osv,
+		cfg:  cfg,
+		kick: make(chan struct{}, 1),
+	}
+}
+
+func (o *Outbox) Start(context.Context) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	o.cancel = cancel
+	o.wg.Add(1)
+	go o.loop(ctx)
+	return nil
+}
+
+// Close stops the sender, the alerts not delivered yet are sent after the next start.
+func (o *Outbox) Close(context.Context) error {
+	if o.cancel != nil {
+		o.cancel()
+	}
+	o.wg.Wait()
+	return nil
+}
+
+func (o *Outbox) Notify(ctx context.Context, alert *model.Alert) error {
+	if _, err := o.osv.Enqueue(ctx, alert); err != nil {
+		return err
+	}
+	// an alert stored in a transaction not committed yet is sent at the next tick
+	select {
+	case o.kick <- struct{}{}:
+	default:
+	}
+	return rule.ErrQueued
+}
+
+func (o *Outbox) loop(ctx context.Context) {
+	defer o.wg.Done()
+	ticker := time.NewTicker(o.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		// the messages of the previous runs are sent at start
+		for o.send(ctx) > 0 {
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-o.kick:
+		case <-ticker.C:
+		}
+	}
+}
+
+// send delivers the due message of each destination, and returns the number of messages delivered.
+func (o *Outbox) send(ctx context.Context) int {
+	if ctx.Err() != nil {
+		return 0
+	}
+	msgs, err := o.osv.Due(ctx, time.Now(), o.cfg.Workers)
+	if err != nil {
+		zap.S().Errorf("failed to read the outbox: %s", err)
+		return 0
+	}
+	var (
+		wg        sync.WaitGroup
+		lk        sync.Mutex
+		delivered int
+	)
+	for i := range msgs {
+		wg.Add(1)
+		go func(m *model.OutboxMessage) {
+			defer wg.Done()
+			if o.deliver(ctx, m) {
+				lk.Lock()
+				delivered++
+				lk.Unlock()
+			}
+		}(&msgs[i])
+	}
+	wg.Wait()
+	return delivered
+}
+
+// deliver sends a message, and reports whether it was delivered.
+func (o *Outbox) deliver(ctx context.Context, m *model.OutboxMessage) bool {
+	err := o.next.Notify(service.WithMessage(ctx, m), &m.Alert)
+	switch {
+	case err == nil, errors.Is(err, rule.ErrSuppressed), errors.Is(err, rule.ErrHeld):
+		if err := o.osv.Delivered(ctx, m); err != nil {
+			zap.S().Errorf("failed to remove delivered message %d from the outbox: %s", m.ID, err)
+			return false
+		}
+		return true
+	case ctx.Err() != nil:
+		// stopped while sending, attempted again at the next start
+		return false
+	case errors.Is(err, bots.ErrUnreachable), m.Attempts+1 >= o.cfg.MaxAttempts:
+		zap.S().Errorf("dead-lettered alert of %s to %s after %d attempts: %s", m.Alert.Source(), m.Destination, m.Attempts+1, err)
+		err = o.osv.Dead(ctx, m, err)
+	default:
+		at := time.Now().Add(o.backoff(m.Attempts))
+		zap.S().Warnf("failed to deliver alert of %s to %s, retrying at %s: %s", m.Alert.Source(), m.Destination,
+			at.Format(time.TimeOnly), err)
+		err = o.osv.Retry(ctx, m, err, at)
+	}
+	if err != nil {
+		zap.S().Errorf("failed to record the failure of message %d of the outbox: %s", m.ID, err)
+	}
+	return false
+}
+
+// backoff returns the delay before the next attempt of a message that failed attempts times before,
+// it is not capped if MaxBackoff is 0.
+func (o *Outbox) backoff(attempts int) time.Duration {
+	d := o.cfg.Backoff
+	for i := 0; i < attempts && (o.cfg.MaxBackoff <= 0 || d < o.cfg.MaxBackoff) && d < math.MaxInt64/2; i++ {
+		d *= 2
+	}
+	if o.cfg.MaxBackoff > 0 && d > o.cfg.MaxBackoff {
+		d = o.cfg.MaxBackoff
+	}
+	return d
+}
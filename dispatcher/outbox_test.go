@@ -0,0 +1,310 @@
+package dispatcher_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/strahe/suialert/bots"
+	"github.com/strahe/suialert/config"
+	"github.com/strahe/suialert/dispatcher"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/rule"
+	"github.com/strahe/suialert/service"
+	"github.com/strahe/suialert/types"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newDB returns an empty database, its name is unique so that repeated runs do not share it.
+func newDB(t *testing.T) *gorm.DB {
+	name := fmt.Sprintf("file:%s-%d?mode=memory&cache=shared", t.Name(), time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(name), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the writers of a shared database in memory fail with "table is locked" instead of waiting
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := model.Migration(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// eventually fails the test if cond is not met within 5 seconds.
+func eventually(t *testing.T, msg string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("timed out: %s", msg)
+}
+
+// flaky fails the alerts of a destination a number of times before delivering them.
+type flaky struct {
+	lk    sync.Mutex
+	fails map[string]int
+	got   map[string][]uint
+	sent  []model.Alert
+}
+
+func (f *flaky) Notify(_ context.Context, alert *model.Alert) error {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	switch n := f.fails[alert.Destination]; {
+	case n < 0:
+		return fmt.Errorf("no route: %w", bots.ErrUnreachable)
+	case n > 0:
+		f.fails[alert.Destination]--
+		return errors.New("rate limited")
+	}
+	f.got[alert.Destination] = append(f.got[alert.Destination], alert.RuleID)
+	f.sent = append(f.sent, *alert)
+	return nil
+}
+
+func (f *flaky) delivered(dest string) []uint {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	return append([]uint(nil), f.got[dest]...)
+}
+
+func outboxConfig() config.OutboxConfig {
+	return config.OutboxConfig{
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+		MaxBackoff:  2 * time.Millisecond,
+		Interval:    5 * time.Millisecond,
+		Workers:     4,
+	}
+}
+
+func testAlert(ruleID uint, dest string) *model.Alert {
+	return &model.Alert{
+		RuleID:      ruleID,
+		UserID:      1,
+		Event:       types.EventTypeCoinBalanceChange,
+		Severity:    model.SeverityInfo,
+		Destination: dest,
+		TxDigest:    fmt.Sprintf("tx%d", ruleID),
+	}
+}
+
+func TestOutboxRetryAndDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	osv := service.NewOutboxService(db)
+	msv := service.NewMatchService(db)
+	f := &flaky{fails: map[string]int{"slow": 2, "down": 100, "gone": -1}, got: map[string][]uint{}}
+	ob := dispatcher.NewOutbox(f, osv, outboxConfig())
+
+	var id uint
+	for _, dest := range []string{"slow", "down", "gone"} {
+		for i := 0; i < 2; i++ {
+			id++
+			alert := testAlert(id, dest)
+			if err := ob.Notify(ctx, alert); !errors.Is(err, rule.ErrQueued) {
+				t.Fatalf("notify: %v, want %v", err, rule.ErrQueued)
+			}
+			if err := msv.Record(ctx, model.NewMatch(alert, model.MatchLive, model.DeliveryQueued)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := ob.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer ob.Close(ctx) // nolint: errcheck
+
+	// the alerts of a destination are delivered in order, once the failures of the first one are over
+	eventually(t, "slow alerts delivered", func() bool { return len(f.delivered("slow")) == 2 })
+	if got := f.delivered("slow"); got[0] != 1 || got[1] != 2 {
+		t.Errorf("delivered %v, want [1 2]", got)
+	}
+	// the first alert of a destination always failing is dead-lettered after MaxAttempts,
+	// the next ones are attempted in turn
+	eventually(t, "alerts dead-lettered", func() bool {
+		dead, err := osv.List(ctx, model.OutboxDead, 10)
+		return err == nil && len(dead) == 4
+	})
+	dead, _ := osv.List(ctx, model.OutboxDead, 10)
+	for _, m := range dead {
+		want := 3
+		if m.Destination == "gone" {
+			// unreachable destinations are not retried
+			want = 1
+		}
+		if m.Attempts != want || m.LastError == "" {
+			t.Errorf("message of %s: %d attempts, error %q, want %d attempts", m.Destination, m.Attempts, m.LastError, want)
+		}
+	}
+
+	// the matches are settled by the outbox
+	for i := uint(1); i <= id; i++ {
+		ms, err := msv.FindByRule(ctx, i, 1)
+		if err != nil || len(ms) != 1 {
+			t.Fatalf("matches of rule %d: %v %v", i, ms, err)
+		}
+		want := model.DeliveryDead
+		if i <= 2 {
+			want = model.DeliveryDelivered
+		}
+		if ms[0].Status != want {
+			t.Errorf("match of rule %d is %s, want %s", i, ms[0].Status, want)
+		}
+	}
+
+	// re-driven messages are delivered once their destination is back
+	f.lk.Lock()
+	f.fails["down"] = 0
+	f.lk.Unlock()
+	if n, err := osv.Redrive(ctx); err != nil || n != 4 {
+		t.Fatalf("redrive: %d %v", n, err)
+	}
+	eventually(t, "re-driven alerts delivered", func() bool { return len(f.delivered("down")) == 2 })
+	if ms, _ := msv.FindByRule(ctx, 3, 1); ms[0].Status != model.DeliveryDelivered {
+		t.Errorf("re-driven match is %s, want %s", ms[0].Status, model.DeliveryDelivered)
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		maxBackoff time.Duration
+		want       []time.Duration
+	}{
+		{"capped", 3 * time.Hour, []time.Duration{time.Hour, 2 * time.Hour, 3 * time.Hour, 3 * time.Hour}},
+		{"not capped", 0, []time.Duration{time.Hour, 2 * time.Hour, 4 * time.Hour, 8 * time.Hour}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := newDB(t)
+			osv := service.NewOutboxService(db)
+			cfg := outboxConfig()
+			cfg.MaxAttempts = 10
+			cfg.Backoff = time.Hour
+			cfg.MaxBackoff = tc.maxBackoff
+			ob := dispatcher.NewOutbox(&flaky{fails: map[string]int{"down": 100}}, osv, cfg)
+			if err := ob.Notify(ctx, testAlert(1, "down")); !errors.Is(err, rule.ErrQueued) {
+				t.Fatal(err)
+			}
+			if err := ob.Start(ctx); err != nil {
+				t.Fatal(err)
+			}
+			defer ob.Close(ctx) // nolint: errcheck
+
+			for i, want := range tc.want {
+				var m *model.OutboxMessage
+				eventually(t, fmt.Sprintf("attempt %d", i+1), func() bool {
+					m, _ = osv.FindByID(ctx, 1)
+					return m != nil && m.Attempts == i+1
+				})
+				// the delay is measured from the failed attempt, shortly before now
+				if delay := time.Until(m.NextAttemptAt); delay > want || delay < want-time.Second {
+					t.Errorf("attempt %d: retried in %s, want %s", i+1, delay, want)
+				}
+				// make the message due again
+				if err := db.Model(m).Update("next_attempt_at", time.Now().UTC()).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestOutboxTransaction(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	osv := service.NewOutboxService(db)
+	ob := dispatcher.NewOutbox(nil, osv, outboxConfig())
+
+	// the alerts of an event which failed to be stored are not sent
+	failed := errors.New("event not stored")
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := ob.Notify(service.WithTx(ctx, tx), testAlert(1, "a")); !errors.Is(err, rule.ErrQueued) {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatal(err)
+	}
+	if msgs, _ := osv.List(ctx, "", 10); len(msgs) != 0 {
+		t.Fatalf("%d messages stored by a rolled back transaction", len(msgs))
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := ob.Notify(service.WithTx(ctx, tx), testAlert(2, "a")); !errors.Is(err, rule.ErrQueued) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msgs, _ := osv.List(ctx, "", 10); len(msgs) != 1 || msgs[0].Alert.RuleID != 2 {
+		t.Fatalf("unexpected messages %v", msgs)
+	}
+}
+
+func TestOutboxSettlesHeldMatches(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	osv := service.NewOutboxService(db)
+	msv := service.NewMatchService(db)
+	f := &flaky{fails: map[string]int{}, got: map[string][]uint{}}
+	ob := dispatcher.NewOutbox(f, osv, outboxConfig())
+
+	// two matches of the same source and event, only the one of the alert is settled
+	held := func(alert *model.Alert) *model.Match {
+		m := model.NewMatch(alert, model.MatchLive, model.DeliveryHeld)
+		if err := msv.Record(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	alert := testAlert(1, "a")
+	alert.MatchID = held(alert).ID
+	other := held(testAlert(1, "a"))
+	// a digest settles the matches it summarizes
+	entries := []model.Alert{*testAlert(2, "b"), *testAlert(3, "b")}
+	for i := range entries {
+		entries[i].MatchID = held(&entries[i]).ID
+	}
+	digest, err := model.NewDigestAlert(model.DigestHourly, time.Now(), entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range []*model.Alert{alert, digest} {
+		if err := ob.Notify(ctx, a); !errors.Is(err, rule.ErrQueued) {
+			t.Fatalf("notify: %v, want %v", err, rule.ErrQueued)
+		}
+	}
+	if err := ob.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer ob.Close(ctx) // nolint: errcheck
+
+	eventually(t, "alerts delivered", func() bool { return len(f.delivered("a")) == 1 && len(f.delivered("b")) == 1 })
+	eventually(t, "matches settled", func() bool {
+		var n int64
+		db.Model(&model.Match{}).Where("status = ?", model.DeliveryDelivered).Count(&n)
+		return n == 3
+	})
+	var m model.Match
+	if err := db.First(&m, other.ID).Error; err != nil || m.Status != model.DeliveryHeld {
+		t.Errorf("other match is %s, want %s: %v", m.Status, model.DeliveryHeld, err)
+	}
+}
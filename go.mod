@@ -6,6 +6,7 @@ require (
 	github.com/allegro/bigcache/v3 v3.1.0
 	github.com/bwmarrin/discordgo v0.27.0
 	github.com/filecoin-project/go-jsonrpc v0.0.0-00010101000000-000000000000
+	github.com/fsnotify/fsnotify v1.6.0
 	github.com/go-pg/migrations/v8 v8.1.0
 	github.com/hyperjumptech/grule-rule-engine v1.13.0
 	github.com/pgcontrib/bigint v1.0.2
@@ -18,6 +19,7 @@ require (
 	golang.org/x/crypto v0.6.0
 	golang.org/x/sync v0.1.0
 	gopkg.in/telebot.v3 v3.1.2
+	gopkg.in/yaml.v3 v3.0.1
 	gorm.io/driver/mysql v1.4.7
 	gorm.io/driver/postgres v1.5.0
 	gorm.io/driver/sqlite v1.4.4
@@ -29,7 +31,6 @@ require (
 	github.com/bmatcuk/doublestar v1.3.2 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/emirpasic/gods v1.12.0 // indirect
-	github.com/fsnotify/fsnotify v1.6.0 // indirect
 	github.com/go-pg/pg/v10 v10.11.0 // indirect
 	github.com/go-pg/zerochecker v0.2.0 // indirect
 	github.com/go-sql-driver/mysql v1.7.0 // indirect
@@ -82,7 +83,6 @@ require (
 	gopkg.in/src-d/go-billy.v4 v4.3.2 // indirect
 	gopkg.in/src-d/go-git.v4 v4.13.1 // indirect
 	gopkg.in/warnings.v0 v0.1.2 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 	mellium.im/sasl v0.3.1 // indirect
 )
 
@@ -7,9 +7,9 @@ import (
 
 	"github.com/pgcontrib/bigint"
 	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
 	"github.com/strahe/suialert/types"
 	"go.uber.org/zap"
-	"golang.org/x/sync/errgroup"
 )
 
 // HandleBalanceChange handle the balance change events.
@@ -24,18 +24,22 @@ func (e *SubHandler) HandleBalanceChange(ctx context.Context, er *types.EventRes
 		zap.S().Errorf("invalid coin balance change event type: %s", reflect.TypeOf(ed))
 		return nil
 	} else {
-		grp, ctx := errgroup.WithContext(ctx)
-		grp.Go(func() error {
-			return e.eng.ExecuteCoinBalanceChange(ctx, event)
-		})
-		grp.Go(func() error {
-			return e.storeBalanceChangeEvent(ctx, er, event)
-		})
-		return grp.Wait()
+		// the rules have to run first, their actions may tag the stored event
+		res, err := e.eng.ExecuteCoinBalanceChange(ctx, er, event)
+		if err != nil {
+			return err
+		}
+		if err := e.cor.Observe(ctx, er, types.EventTypeCoinBalanceChange, event); err != nil {
+			return err
+		}
+		if err := e.chk.Observe(ctx, er, types.EventTypeCoinBalanceChange, event); err != nil {
+			return err
+		}
+		return e.storeBalanceChangeEvent(ctx, er, event, res.Tags)
 	}
 }
 
-func (e *SubHandler) storeBalanceChangeEvent(_ context.Context, er *types.EventResult, ed *types.CoinBalanceChange) error {
+func (e *SubHandler) storeBalanceChangeEvent(ctx context.Context, er *types.EventResult, ed *types.CoinBalanceChange, tags []string) error {
 	if er == nil || ed == nil {
 		return nil
 	}
@@ -51,8 +55,9 @@ func (e *SubHandler) storeBalanceChangeEvent(_ context.Context, er *types.EventR
 		CoinType:          ed.CoinType,
 		CoinObjectID:      ed.CoinObjectId,
 		Version:           ed.Version,
-		Amount:            bigint.FromInt64(ed.Amount),
+		Amount:            bigint.NewBigint(ed.Amount.Int()),
+		Tags:              tags,
 	}
 
-	return e.db.Create(&m).Error
+	return service.Conn(ctx, e.db).Create(&m).Error
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
 
 	"github.com/strahe/suialert/types"
 )
@@ -13,6 +14,12 @@ func (e *SubHandler) HandleDeleteObject(ctx context.Context, er *types.EventResu
 	if event, ok := ed.(*types.DeleteObject); !ok {
 		return nil
 	} else {
+		if err := e.cor.Observe(ctx, er, types.EventTypeDeleteObject, event); err != nil {
+			return err
+		}
+		if err := e.chk.Observe(ctx, er, types.EventTypeDeleteObject, event); err != nil {
+			return err
+		}
 		if err := e.storeDeleteObjectEvent(ctx, er, event); err != nil {
 			return err
 		}
@@ -20,7 +27,7 @@ func (e *SubHandler) HandleDeleteObject(ctx context.Context, er *types.EventResu
 	return nil
 }
 
-func (e *SubHandler) storeDeleteObjectEvent(_ context.Context, er *types.EventResult, ed *types.DeleteObject) error {
+func (e *SubHandler) storeDeleteObjectEvent(ctx context.Context, er *types.EventResult, ed *types.DeleteObject) error {
 	m := model.DeleteObjectEvent{
 		TransactionDigest: er.Id.TxDigest,
 		EventSeq:          er.Id.EventSeq,
@@ -31,5 +38,5 @@ func (e *SubHandler) storeDeleteObjectEvent(_ context.Context, er *types.EventRe
 		ObjectID:          ed.ObjectID,
 		Version:           ed.Version,
 	}
-	return e.db.Create(&m).Error
+	return service.Conn(ctx, e.db).Create(&m).Error
 }
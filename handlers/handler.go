@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/strahe/suialert/rule"
+	"github.com/strahe/suialert/service"
 	"gorm.io/gorm"
 
 	"github.com/filecoin-project/go-jsonrpc"
@@ -23,19 +24,23 @@ type SubHandler struct {
 	eventNames map[types.SubscriptionID]types.EventType
 	lk         sync.Mutex
 
-	bot  bots.Bot
+	bot  bots.Notifier
 	db   *gorm.DB
 	eng  *rule.Engine
+	cor  *rule.Correlator
+	chk  *rule.Checker
 	done chan struct{}
 }
 
-func NewSubHandler(bot bots.Bot, db *gorm.DB, eng *rule.Engine) *SubHandler {
+func NewSubHandler(bot bots.Notifier, db *gorm.DB, eng *rule.Engine, cor *rule.Correlator, chk *rule.Checker) *SubHandler {
 	hd := &SubHandler{
 		handlers:   map[client.SubscriptionID]handler{},
 		eventNames: map[client.SubscriptionID]types.EventType{},
 		bot:        bot,
 		db:         db,
 		eng:        eng,
+		cor:        cor,
+		chk:        chk,
 		done:       make(chan struct{}),
 	}
 	return hd
@@ -84,7 +89,18 @@ func (e *SubHandler) eventName(id types.SubscriptionID) string {
 	return string(e.eventNames[id])
 }
 
+// inTx runs a handler in a transaction, so that the event and the alerts of its rules
+// stored in the outbox are committed together, see service.WithTx.
+func (e *SubHandler) inTx(hd handler) handler {
+	return func(ctx context.Context, er *types.EventResult, ed interface{}) error {
+		return e.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return hd(service.WithTx(ctx, tx), er, ed)
+		})
+	}
+}
+
 func (e *SubHandler) processSubscription(ctx context.Context, hd handler, p *types.Subscription) error {
+	hd = e.inTx(hd)
 	var er types.EventResult
 	if err := json.Unmarshal(p.Result, &er); err != nil {
 		return fmt.Errorf("error unmarshalling event result: %s", err.Error())
@@ -4,23 +4,30 @@ import (
 	"context"
 
 	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
 
 	"github.com/strahe/suialert/types"
 )
 
 // HandleMove handle delete object events
-func (e *SubHandler) HandleMove(_ context.Context, er *types.EventResult, ed interface{}) error {
+func (e *SubHandler) HandleMove(ctx context.Context, er *types.EventResult, ed interface{}) error {
 	if event, ok := ed.(*types.MoveEvent); !ok {
 		return nil
 	} else {
-		if err := e.storeMoveEvent(er, event); err != nil {
+		if err := e.cor.Observe(ctx, er, types.EventTypeMove, event); err != nil {
+			return err
+		}
+		if err := e.chk.Observe(ctx, er, types.EventTypeMove, event); err != nil {
+			return err
+		}
+		if err := e.storeMoveEvent(ctx, er, event); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (e *SubHandler) storeMoveEvent(er *types.EventResult, ed *types.MoveEvent) error {
+func (e *SubHandler) storeMoveEvent(ctx context.Context, er *types.EventResult, ed *types.MoveEvent) error {
 	m := model.MoveEvent{
 		TransactionDigest: er.Id.TxDigest,
 		EventSeq:          er.Id.EventSeq,
@@ -32,5 +39,5 @@ func (e *SubHandler) storeMoveEvent(er *types.EventResult, ed *types.MoveEvent)
 		Type:              ed.Type,
 		BCS:               ed.Bcs,
 	}
-	return e.db.Create(&m).Error
+	return service.Conn(ctx, e.db).Create(&m).Error
 }
@@ -4,23 +4,30 @@ import (
 	"context"
 
 	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
 
 	"github.com/strahe/suialert/types"
 )
 
 // HandleMutateObject handle mutate object events
-func (e *SubHandler) HandleMutateObject(_ context.Context, er *types.EventResult, ed interface{}) error {
+func (e *SubHandler) HandleMutateObject(ctx context.Context, er *types.EventResult, ed interface{}) error {
 	if event, ok := ed.(*types.MutateObject); !ok {
 		return nil
 	} else {
-		if err := e.storeMutateObjectEvent(er, event); err != nil {
+		if err := e.cor.Observe(ctx, er, types.EventTypeMutateObject, event); err != nil {
+			return err
+		}
+		if err := e.chk.Observe(ctx, er, types.EventTypeMutateObject, event); err != nil {
+			return err
+		}
+		if err := e.storeMutateObjectEvent(ctx, er, event); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (e *SubHandler) storeMutateObjectEvent(er *types.EventResult, ed *types.MutateObject) error {
+func (e *SubHandler) storeMutateObjectEvent(ctx context.Context, er *types.EventResult, ed *types.MutateObject) error {
 	m := model.MutateObjectEvent{
 		TransactionDigest: er.Id.TxDigest,
 		EventSeq:          er.Id.EventSeq,
@@ -32,5 +39,5 @@ func (e *SubHandler) storeMutateObjectEvent(er *types.EventResult, ed *types.Mut
 		ObjectType:        ed.ObjectType,
 		Version:           ed.Version,
 	}
-	return e.db.Create(&m).Error
+	return service.Conn(ctx, e.db).Create(&m).Error
 }
@@ -4,23 +4,30 @@ import (
 	"context"
 
 	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
 
 	"github.com/strahe/suialert/types"
 )
 
 // HandleNewObject handle delete object events
-func (e *SubHandler) HandleNewObject(_ context.Context, er *types.EventResult, ed interface{}) error {
+func (e *SubHandler) HandleNewObject(ctx context.Context, er *types.EventResult, ed interface{}) error {
 	if event, ok := ed.(*types.NewObject); !ok {
 		return nil
 	} else {
-		if err := e.storeNewObjectEvent(er, event); err != nil {
+		if err := e.cor.Observe(ctx, er, types.EventTypeNewObject, event); err != nil {
+			return err
+		}
+		if err := e.chk.Observe(ctx, er, types.EventTypeNewObject, event); err != nil {
+			return err
+		}
+		if err := e.storeNewObjectEvent(ctx, er, event); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (e *SubHandler) storeNewObjectEvent(er *types.EventResult, ed *types.NewObject) error {
+func (e *SubHandler) storeNewObjectEvent(ctx context.Context, er *types.EventResult, ed *types.NewObject) error {
 	m := model.NewObjectEvent{
 		TransactionDigest: er.Id.TxDigest,
 		EventSeq:          er.Id.EventSeq,
@@ -33,5 +40,5 @@ func (e *SubHandler) storeNewObjectEvent(er *types.EventResult, ed *types.NewObj
 		ObjectType:        ed.ObjectType,
 		Version:           ed.Version,
 	}
-	return e.db.Create(&m).Error
+	return service.Conn(ctx, e.db).Create(&m).Error
 }
@@ -4,23 +4,30 @@ import (
 	"context"
 
 	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
 
 	"github.com/strahe/suialert/types"
 )
 
 // HandlePublish handle publish event
-func (e *SubHandler) HandlePublish(_ context.Context, er *types.EventResult, ed interface{}) error {
+func (e *SubHandler) HandlePublish(ctx context.Context, er *types.EventResult, ed interface{}) error {
 	if event, ok := ed.(*types.Publish); !ok {
 		return nil
 	} else {
-		if err := e.storePublishEvent(er, event); err != nil {
+		if err := e.cor.Observe(ctx, er, types.EventTypePublish, event); err != nil {
+			return err
+		}
+		if err := e.chk.Observe(ctx, er, types.EventTypePublish, event); err != nil {
+			return err
+		}
+		if err := e.storePublishEvent(ctx, er, event); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (e *SubHandler) storePublishEvent(er *types.EventResult, ed *types.Publish) error {
+func (e *SubHandler) storePublishEvent(ctx context.Context, er *types.EventResult, ed *types.Publish) error {
 	m := model.PublishEvent{
 		TransactionDigest: er.Id.TxDigest,
 		EventSeq:          er.Id.EventSeq,
@@ -30,5 +37,5 @@ func (e *SubHandler) storePublishEvent(er *types.EventResult, ed *types.Publish)
 		Version:           ed.Version,
 		Digest:            ed.Digest,
 	}
-	return e.db.Create(&m).Error
+	return service.Conn(ctx, e.db).Create(&m).Error
 }
@@ -4,23 +4,30 @@ import (
 	"context"
 
 	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
 
 	"github.com/strahe/suialert/types"
 )
 
 // HandleTransferObject handle transfer object event
-func (e *SubHandler) HandleTransferObject(_ context.Context, er *types.EventResult, ed interface{}) error {
+func (e *SubHandler) HandleTransferObject(ctx context.Context, er *types.EventResult, ed interface{}) error {
 	if event, ok := ed.(*types.TransferObject); !ok {
 		return nil
 	} else {
-		if err := e.storeTransferObjectEvent(er, event); err != nil {
+		if err := e.cor.Observe(ctx, er, types.EventTypeTransferObject, event); err != nil {
+			return err
+		}
+		if err := e.chk.Observe(ctx, er, types.EventTypeTransferObject, event); err != nil {
+			return err
+		}
+		if err := e.storeTransferObjectEvent(ctx, er, event); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (e *SubHandler) storeTransferObjectEvent(er *types.EventResult, ed *types.TransferObject) error {
+func (e *SubHandler) storeTransferObjectEvent(ctx context.Context, er *types.EventResult, ed *types.TransferObject) error {
 	m := model.TransferObjectEvent{
 		TransactionDigest: er.Id.TxDigest,
 		EventSeq:          er.Id.EventSeq,
@@ -33,5 +40,5 @@ func (e *SubHandler) storeTransferObjectEvent(er *types.EventResult, ed *types.T
 		ObjectType:        ed.ObjectType,
 		Version:           ed.Version,
 	}
-	return e.db.Create(&m).Error
+	return service.Conn(ctx, e.db).Create(&m).Error
 }
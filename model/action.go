@@ -0,0 +1,58 @@
+package model
+
+import (
+	"fmt"
+)
+
+type ActionType string
+
+const (
+	// ActionNotify sends an alert to the rule owner.
+	ActionNotify ActionType = "notify"
+	// ActionTag adds a tag to the stored event.
+	ActionTag ActionType = "tag"
+	// ActionWebhook posts the alert to a registered webhook, signed and retried.
+	ActionWebhook ActionType = "webhook"
+	// ActionEscalate sends the alert to another channel.
+	ActionEscalate ActionType = "escalate"
+	// ActionStop prevents rules with a lower salience from running.
+	ActionStop ActionType = "stop"
+)
+
+// Action is executed by the engine when the condition of its rule matched.
+type Action struct {
+	Type     ActionType `json:"type" yaml:"type"`
+	Severity Severity   `json:"severity,omitempty" yaml:"severity,omitempty"`
+	Tag      string     `json:"tag,omitempty" yaml:"tag,omitempty"`
+	Webhook  uint       `json:"webhook,omitempty" yaml:"webhook,omitempty"`
+	Channel  string     `json:"channel,omitempty" yaml:"channel,omitempty"`
+}
+
+// DefaultActions are used by rules without any action.
+var DefaultActions = []Action{
+	{Type: ActionNotify},
+}
+
+func (a *Action) Validate() error {
+	switch a.Type {
+	case ActionNotify, ActionStop:
+	case ActionTag:
+		if a.Tag == "" {
+			return fmt.Errorf("tag action requires a tag")
+		}
+	case ActionWebhook:
+		if a.Webhook == 0 {
+			return fmt.Errorf("webhook action requires the id of a registered webhook")
+		}
+	case ActionEscalate:
+		if a.Channel == "" {
+			return fmt.Errorf("escalate action requires a channel")
+		}
+	default:
+		return fmt.Errorf("unknown action type: %s", a.Type)
+	}
+	if a.Severity != "" && !a.Severity.Valid() {
+		return fmt.Errorf("unknown severity: %s", a.Severity)
+	}
+	return nil
+}
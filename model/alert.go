@@ -0,0 +1,97 @@
+package model
+
+import (
+	"fmt"
+	"html"
+	"time"
+
+	"github.com/strahe/suialert/types"
+)
+
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// Valid reports whether s is a known severity, empty is not.
+func (s Severity) Valid() bool {
+	switch s {
+	case SeverityInfo, SeverityWarning, SeverityCritical:
+		return true
+	}
+	return false
+}
+
+func (s Severity) Emoji() string {
+	switch s {
+	case SeverityWarning:
+		return html.UnescapeString("&#128992;")
+	case SeverityCritical:
+		return html.UnescapeString("&#128308;")
+	}
+	return html.UnescapeString("&#128309;")
+}
+
+// Alert is the message produced when a rule matched an event.
+// It is not stored on its own, notifiers deliver it to the rule owner
+// or, when Destination is set, to that channel.
+type Alert struct {
+	RuleID      uint            `json:"rule_id,omitempty"`
+	SequenceID  uint            `json:"sequence_id,omitempty"`
+	StaticRule  string          `json:"static_rule,omitempty"`
+	CheckID     uint            `json:"check_id,omitempty"`
+	QueryID     uint            `json:"query_id,omitempty"`
+	UserID      uint            `json:"user_id"`
+	Event       types.EventType `json:"event"`
+	Address     types.Address   `json:"address"`
+	Severity    Severity        `json:"severity"`
+	Destination string          `json:"destination,omitempty"`
+	// Escalation policy of the source, nil if the alert needs no acknowledgement
+	Escalation *Escalation `json:"escalation,omitempty"`
+	// ID to acknowledge the alert with, set when it has an escalation policy
+	AckID uint `json:"ack_id,omitempty"`
+	// Escalated is set on the copy of an alert sent by its escalation policy
+	Escalated bool `json:"escalated,omitempty"`
+	// Digest mode of the rule, see Rule.Digest
+	Digest DigestMode `json:"-"`
+	// MatchID is the match the alert was raised for, its delivery is recorded on it
+	MatchID uint `json:"match_id,omitempty"`
+	// Matches are the matches summarized by a digest
+	Matches   []uint      `json:"matches,omitempty"`
+	TxDigest  string      `json:"tx_digest"`
+	EventSeq  int64       `json:"event_seq"`
+	Timestamp uint64      `json:"timestamp"`
+	Data      interface{} `json:"data"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// Source describes what produced the alert, for logging.
+func (a *Alert) Source() string {
+	if a.Event == types.EventTypeDigest {
+		return "digest"
+	}
+	if a.StaticRule != "" {
+		return fmt.Sprintf("static rule %s", a.StaticRule)
+	}
+	if a.CheckID != 0 {
+		return fmt.Sprintf("check %d", a.CheckID)
+	}
+	if a.QueryID != 0 {
+		return fmt.Sprintf("query %d", a.QueryID)
+	}
+	if a.SequenceID != 0 {
+		return fmt.Sprintf("sequence %d", a.SequenceID)
+	}
+	return fmt.Sprintf("rule %d", a.RuleID)
+}
+
+// Recipient identifies who the alert is sent to: its destination, or "user:<id>" for the alerts sent to their user.
+func (a *Alert) Recipient() string {
+	if a.Destination != "" {
+		return a.Destination
+	}
+	return fmt.Sprintf("user:%d", a.UserID)
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+func init() {
+	schema.RegisterSerializer("alert", alertSerializer{})
+}
+
+// alertSerializer stores an alert as json like the json serializer, but reads its data back
+// into the type of its event, so that the stored alerts are formatted as when they were raised.
+type alertSerializer struct {
+	schema.JSONSerializer
+}
+
+func (alertSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var alert Alert
+	var b []byte
+	switch v := dbValue.(type) {
+	case nil:
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("failed to unmarshal alert: %#v", dbValue)
+	}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &alert); err != nil {
+			return err
+		}
+		var raw struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(b, &raw); err != nil {
+			return err
+		}
+		if data := alert.Event.NewData(); data != nil && len(raw.Data) > 0 && string(raw.Data) != "null" {
+			if err := json.Unmarshal(raw.Data, data); err != nil {
+				return err
+			}
+			alert.Data = data
+		}
+	}
+	field.ReflectValueOf(ctx, dst).Set(reflect.ValueOf(alert))
+	return nil
+}
@@ -0,0 +1,114 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/strahe/suialert/types"
+)
+
+// CheckKind is what a scheduled check expects to happen.
+type CheckKind string
+
+const (
+	// CheckInactivity fires when the address had no event, or no event of a type, within the period.
+	CheckInactivity CheckKind = "inactivity"
+	// CheckNoInflow fires when the balance of a coin of the address was not topped up within the period.
+	CheckNoInflow CheckKind = "no_inflow"
+)
+
+// CheckRule is a rule evaluated on a schedule instead of per event,
+// it fires when an expected activity of an address did not happen within a period.
+type CheckRule struct {
+	ID      uint          `json:"id" gorm:"primaryKey"`
+	UserID  uint          `json:"user_id" gorm:"index"`
+	User    User          `json:"-"`
+	Name    string        `json:"name"`
+	Address types.Address `json:"address" gorm:"index"`
+	Kind    CheckKind     `json:"kind"`
+	// Event type expected by an inactivity check, any if empty
+	Event types.EventType `json:"event,omitempty"`
+	// Coin type expected by a no inflow check, SUI if empty
+	CoinType string        `json:"coin_type,omitempty"`
+	Period   time.Duration `json:"period"`
+	Actions  []Action      `json:"actions" gorm:"serializer:json"`
+	Paused   bool          `json:"paused" gorm:"not null;default:false"`
+	// Last time the check fired, it fires again after another period without activity
+	LastFiredAt *time.Time `json:"last_fired_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (*CheckRule) TableName() string {
+	return "check_rules"
+}
+
+// ActivityKind returns the kind of the activity the check expects.
+func (r *CheckRule) ActivityKind() string {
+	if r.Kind == CheckNoInflow {
+		coin := r.CoinType
+		if coin == "" {
+			coin = types.SuiCoinType
+		}
+		return InflowKey(coin)
+	}
+	return EventKey(r.Event)
+}
+
+// GetActions returns the actions of the rule, or DefaultActions if it has none.
+func (r *CheckRule) GetActions() []Action {
+	if len(r.Actions) == 0 {
+		return DefaultActions
+	}
+	return r.Actions
+}
+
+func (r *CheckRule) Validate() error {
+	switch r.Kind {
+	case CheckInactivity:
+		if r.Event != "" && !r.Event.Known() {
+			return fmt.Errorf("unknown event %q", r.Event)
+		}
+	case CheckNoInflow:
+	default:
+		return fmt.Errorf("unknown check %q", r.Kind)
+	}
+	if r.Period < time.Minute {
+		return fmt.Errorf("period must be at least a minute")
+	}
+	for i := range r.Actions {
+		if err := r.Actions[i].Validate(); err != nil {
+			return fmt.Errorf("action %d: %w", i, err)
+		}
+		if r.Actions[i].Type == ActionTag || r.Actions[i].Type == ActionStop {
+			return fmt.Errorf("action %d: %s is not supported by checks", i, r.Actions[i].Type)
+		}
+	}
+	return nil
+}
+
+// Activity is the last time an address was seen doing something, e.g. sending an event of a type.
+// Kind is built by EventKey or InflowKey.
+type Activity struct {
+	Address  types.Address `json:"address" gorm:"primaryKey"`
+	Kind     string        `json:"kind" gorm:"primaryKey"`
+	LastSeen time.Time     `json:"last_seen"`
+	TxDigest string        `json:"tx_digest"`
+}
+
+func (*Activity) TableName() string {
+	return "address_activities"
+}
+
+// EventKey is the kind of the activity of an event type, any event if empty.
+func EventKey(event types.EventType) string {
+	if event == "" {
+		return "event:*"
+	}
+	return "event:" + string(event)
+}
+
+// InflowKey is the kind of the activity of receiving a coin.
+func InflowKey(coinType string) string {
+	return "inflow:" + coinType
+}
@@ -18,6 +18,7 @@ type CoinBalanceChangeEvent struct {
 	CoinObjectID      string                      `json:"coin_object_id"`
 	Version           int64                       `json:"version"`
 	Amount            *bigint.Bigint              `json:"amount"`
+	Tags              []string                    `json:"tags" gorm:"serializer:json"`
 }
 
 func (*CoinBalanceChangeEvent) TableName() string {
@@ -0,0 +1,164 @@
+package model
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/strahe/suialert/types"
+)
+
+// DigestMode is how the alerts of a user or a rule are delivered.
+type DigestMode string
+
+const (
+	// DigestDefault delivers the alerts of a rule in the mode of its user, and the alerts of a user in real time.
+	DigestDefault DigestMode = ""
+	// DigestRealtime delivers the alerts right away.
+	DigestRealtime DigestMode = "realtime"
+	// DigestHourly sends a summary of the alerts at the start of every hour.
+	DigestHourly DigestMode = "hourly"
+	// DigestDaily sends a summary of the alerts every day at the digest hour of the user.
+	DigestDaily DigestMode = "daily"
+)
+
+// maxDigestTop is the number of transactions of a digest
+const maxDigestTop = 5
+
+// Valid reports whether m is a known mode, empty is valid.
+func (m DigestMode) Valid() bool {
+	switch m {
+	case DigestDefault, DigestRealtime, DigestHourly, DigestDaily:
+		return true
+	}
+	return false
+}
+
+// Batched reports whether the alerts are summarized.
+func (m DigestMode) Batched() bool {
+	return m == DigestHourly || m == DigestDaily
+}
+
+// Next returns the time the digest of the period t is in is sent, in the location of t.
+// Daily digests are sent at hour.
+func (m DigestMode) Next(t time.Time, hour int) time.Time {
+	switch m {
+	case DigestHourly:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+	case DigestDaily:
+		at := time.Date(t.Year(), t.Month(), t.Day(), hour, 0, 0, 0, t.Location())
+		if !at.After(t) {
+			at = at.AddDate(0, 0, 1)
+		}
+		return at
+	}
+	return t
+}
+
+// Period returns the duration summarized by a digest.
+func (m DigestMode) Period() time.Duration {
+	if m == DigestDaily {
+		return 24 * time.Hour
+	}
+	return time.Hour
+}
+
+// DigestEntry is an alert waiting for the digest of its recipient.
+type DigestEntry struct {
+	ID uint `json:"id" gorm:"primaryKey"`
+	// Recipient of the alert, see Alert.Recipient
+	Recipient string     `json:"recipient" gorm:"not null;index"`
+	Mode      DigestMode `json:"mode" gorm:"not null"`
+	Alert     Alert      `json:"alert" gorm:"serializer:alert"`
+	SendAt    time.Time  `json:"send_at" gorm:"index"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (*DigestEntry) TableName() string {
+	return "digest_entries"
+}
+
+// NewDigest summarizes the alerts of a period: the alerts of each source, the net amount of each coin,
+// and the transactions with the most alerts.
+func NewDigest(mode DigestMode, until time.Time, alerts []Alert) *types.Summary {
+	d := &types.Summary{
+		Mode:  string(mode),
+		Since: until.Add(-mode.Period()),
+		Until: until,
+		Count: len(alerts),
+	}
+	var (
+		sources = map[string]int{}
+		coins   = map[string]*types.SummaryCoin{}
+		txs     = map[string]int{}
+		// the order the transactions were first seen, to rank the ties
+		seen []string
+	)
+	for i := range alerts {
+		a := &alerts[i]
+		sources[a.Source()]++
+		if a.TxDigest != "" {
+			if txs[a.TxDigest] == 0 {
+				seen = append(seen, a.TxDigest)
+			}
+			txs[a.TxDigest]++
+		}
+		if c, ok := a.Data.(*types.CoinBalanceChange); ok {
+			dc := coins[c.CoinType]
+			if dc == nil {
+				dc = &types.SummaryCoin{CoinType: c.CoinType, Amount: types.NewBigInt(new(big.Int))}
+				coins[c.CoinType] = dc
+			}
+			dc.Amount.Int().Add(dc.Amount.Int(), c.Amount.Int())
+			dc.Count++
+		}
+	}
+	for s, n := range sources {
+		d.Sources = append(d.Sources, types.SummarySource{Source: s, Count: n})
+	}
+	sort.Slice(d.Sources, func(i, j int) bool {
+		if d.Sources[i].Count != d.Sources[j].Count {
+			return d.Sources[i].Count > d.Sources[j].Count
+		}
+		return d.Sources[i].Source < d.Sources[j].Source
+	})
+	for _, c := range coins {
+		d.Coins = append(d.Coins, *c)
+	}
+	sort.Slice(d.Coins, func(i, j int) bool { return d.Coins[i].CoinType < d.Coins[j].CoinType })
+	for _, tx := range seen {
+		d.Top = append(d.Top, types.SummaryTx{TxDigest: tx, Count: txs[tx]})
+	}
+	sort.SliceStable(d.Top, func(i, j int) bool { return d.Top[i].Count > d.Top[j].Count })
+	if len(d.Top) > maxDigestTop {
+		d.Top = d.Top[:maxDigestTop]
+	}
+	return d
+}
+
+// NewDigestAlert returns the alert delivering a digest of the alerts of a recipient,
+// its severity is the highest of the alerts and it settles their matches.
+func NewDigestAlert(mode DigestMode, until time.Time, alerts []Alert) (*Alert, error) {
+	if len(alerts) == 0 {
+		return nil, fmt.Errorf("no alert to summarize")
+	}
+	alert := &Alert{
+		UserID:      alerts[0].UserID,
+		Destination: alerts[0].Destination,
+		Event:       types.EventTypeDigest,
+		Severity:    SeverityInfo,
+		Data:        NewDigest(mode, until, alerts),
+		CreatedAt:   time.Now(),
+	}
+	rank := map[Severity]int{SeverityInfo: 0, SeverityWarning: 1, SeverityCritical: 2}
+	for _, a := range alerts {
+		if rank[a.Severity] > rank[alert.Severity] {
+			alert.Severity = a.Severity
+		}
+		if a.MatchID != 0 {
+			alert.Matches = append(alert.Matches, a.MatchID)
+		}
+	}
+	return alert, nil
+}
@@ -0,0 +1,41 @@
+package model_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/strahe/suialert/model"
+)
+
+func TestDigestNext(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day, hour, min int, loc *time.Location) time.Time {
+		return time.Date(2023, time.March, day, hour, min, 0, 0, loc)
+	}
+	for _, tc := range []struct {
+		name string
+		mode model.DigestMode
+		t    time.Time
+		hour int
+		next time.Time
+	}{
+		{"realtime", model.DigestRealtime, at(1, 12, 30, time.UTC), 0, at(1, 12, 30, time.UTC)},
+		{"hourly", model.DigestHourly, at(1, 12, 30, time.UTC), 0, at(1, 13, 0, time.UTC)},
+		{"hourly, at the hour", model.DigestHourly, at(1, 12, 0, time.UTC), 0, at(1, 13, 0, time.UTC)},
+		{"hourly, before midnight", model.DigestHourly, at(31, 23, 30, time.UTC), 0, time.Date(2023, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"daily, before the hour", model.DigestDaily, at(1, 7, 30, time.UTC), 8, at(1, 8, 0, time.UTC)},
+		{"daily, at the hour", model.DigestDaily, at(1, 8, 0, time.UTC), 8, at(2, 8, 0, time.UTC)},
+		{"daily, after the hour", model.DigestDaily, at(1, 9, 0, time.UTC), 8, at(2, 8, 0, time.UTC)},
+		// the digest hour is in the timezone of the time, across the switch to summer time
+		{"daily, timezone", model.DigestDaily, at(25, 9, 0, paris), 8, at(26, 8, 0, paris)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if next := tc.mode.Next(tc.t, tc.hour); !next.Equal(tc.next) {
+				t.Errorf("Next(%s, %d) = %s, want %s", tc.t, tc.hour, next, tc.next)
+			}
+		})
+	}
+}
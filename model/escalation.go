@@ -0,0 +1,50 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// Escalation re-sends an alert to another channel or user when nobody acknowledged it in time.
+type Escalation struct {
+	// Time to acknowledge the alert before it is escalated
+	After time.Duration `json:"after" yaml:"after" mapstructure:"after"`
+	// Channel the alert is escalated to
+	Channel string `json:"channel,omitempty" yaml:"channel,omitempty" mapstructure:"channel"`
+	// User the alert is escalated to, if there is no channel
+	UserID uint `json:"user_id,omitempty" yaml:"user_id,omitempty" mapstructure:"user_id"`
+}
+
+func (e *Escalation) Validate() error {
+	if e.After < time.Minute {
+		return fmt.Errorf("escalation delay must be at least a minute")
+	}
+	if e.Channel == "" && e.UserID == 0 {
+		return fmt.Errorf("escalation requires a channel or a user")
+	}
+	return nil
+}
+
+func (e *Escalation) String() string {
+	if e.Channel != "" {
+		return fmt.Sprintf("channel %s after %s", e.Channel, e.After)
+	}
+	return fmt.Sprintf("user %d after %s", e.UserID, e.After)
+}
+
+// AlertAck tracks the acknowledgement of an alert with an escalation policy.
+// The alert is escalated when it is not acknowledged by DueAt.
+type AlertAck struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"index"`
+	Alert       Alert      `json:"alert" gorm:"serializer:alert"`
+	DueAt       time.Time  `json:"due_at" gorm:"index"`
+	AckedAt     *time.Time `json:"acked_at"`
+	AckedBy     string     `json:"acked_by"`
+	EscalatedAt *time.Time `json:"escalated_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (*AlertAck) TableName() string {
+	return "alert_acks"
+}
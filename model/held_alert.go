@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// HeldAlert is an alert held back during the quiet hours of its user.
+type HeldAlert struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"index"`
+	Alert     Alert     `json:"alert" gorm:"serializer:alert"`
+	ReleaseAt time.Time `json:"release_at" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (*HeldAlert) TableName() string {
+	return "held_alerts"
+}
@@ -0,0 +1,102 @@
+package model
+
+import (
+	"time"
+
+	"github.com/strahe/suialert/types"
+)
+
+type MatchMode string
+
+const (
+	// MatchLive is a match whose actions were executed.
+	MatchLive MatchMode = "live"
+	// MatchShadow is a match of a shadow rule, its actions were skipped.
+	MatchShadow MatchMode = "shadow"
+	// MatchDryRun is a match while the engine runs dry, no notification was sent.
+	MatchDryRun MatchMode = "dry_run"
+)
+
+type DeliveryStatus string
+
+const (
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryFailed    DeliveryStatus = "failed"
+	// DeliverySuppressed is a match not notified: shadow rule, dry run or outside of the user schedule.
+	DeliverySuppressed DeliveryStatus = "suppressed"
+	// DeliveryHeld is a match notified after the quiet hours of the user, or in a digest,
+	// its status is set once its alert is delivered or dead-lettered.
+	DeliveryHeld DeliveryStatus = "held"
+	// DeliveryQueued is a match whose alert waits in the outbox, its status is set once delivered or dead-lettered.
+	DeliveryQueued DeliveryStatus = "queued"
+	// DeliveryDead is a match whose alert was dead-lettered by the outbox.
+	DeliveryDead DeliveryStatus = "dead"
+	// DeliveryNone is a match without notification actions, or whose notifications are being sent.
+	DeliveryNone DeliveryStatus = "none"
+)
+
+// Match records an event matched by a rule, a static rule, a sequence, a check or a query, and the delivery of its alert.
+// The event is identified by its transaction and sequence number.
+type Match struct {
+	ID         uint            `json:"id" gorm:"primaryKey"`
+	RuleID     uint            `json:"rule_id,omitempty" gorm:"index"`
+	SequenceID uint            `json:"sequence_id,omitempty" gorm:"index"`
+	StaticRule string          `json:"static_rule,omitempty" gorm:"index"`
+	CheckID    uint            `json:"check_id,omitempty" gorm:"index"`
+	QueryID    uint            `json:"query_id,omitempty" gorm:"index"`
+	UserID     uint            `json:"user_id"`
+	Mode       MatchMode       `json:"mode" gorm:"not null"`
+	Status     DeliveryStatus  `json:"status" gorm:"not null"`
+	Error      string          `json:"error,omitempty"`
+	Event      types.EventType `json:"event"`
+	Address    types.Address   `json:"address"`
+	TxDigest   string          `json:"tx_digest"`
+	EventSeq   int64           `json:"event_seq"`
+	Timestamp  uint64          `json:"timestamp"`
+	CreatedAt  time.Time       `json:"created_at" gorm:"index"`
+}
+
+func (*Match) TableName() string {
+	return "rule_matches"
+}
+
+// NewMatch returns the match of the source of an alert.
+func NewMatch(alert *Alert, mode MatchMode, status DeliveryStatus) *Match {
+	return &Match{
+		RuleID:     alert.RuleID,
+		SequenceID: alert.SequenceID,
+		StaticRule: alert.StaticRule,
+		CheckID:    alert.CheckID,
+		QueryID:    alert.QueryID,
+		UserID:     alert.UserID,
+		Mode:       mode,
+		Status:     status,
+		Event:      alert.Event,
+		Address:    alert.Address,
+		TxDigest:   alert.TxDigest,
+		EventSeq:   alert.EventSeq,
+		Timestamp:  alert.Timestamp,
+	}
+}
+
+// DayCount is the number of matches of a day.
+type DayCount struct {
+	Day   string `json:"day"`
+	Count int64  `json:"count"`
+}
+
+// RuleStats aggregates the matches of a rule since a time.
+type RuleStats struct {
+	RuleID     uint      `json:"rule_id"`
+	Since      time.Time `json:"since"`
+	Total      int64     `json:"total"`
+	Delivered  int64     `json:"delivered"`
+	Failed     int64     `json:"failed"`
+	Suppressed int64     `json:"suppressed"`
+	Held       int64     `json:"held"`
+	// Queued matches wait in the outbox, the dead-lettered ones are failed
+	Queued    int64      `json:"queued"`
+	LastMatch *time.Time `json:"last_match"`
+	// Matches per day in UTC, oldest first, days without match are omitted
+	PerDay []DayCount `json:"per_day"`
+}
@@ -3,9 +3,25 @@ package model
 import "gorm.io/gorm"
 
 func Migration(db *gorm.DB) error {
+	if err := migrateSequenceStates(db); err != nil {
+		return err
+	}
 	return db.AutoMigrate(
 		&User{},
 		&Rule{},
+		&RuleHistory{},
+		&Match{},
+		&HeldAlert{},
+		&OutboxMessage{},
+		&DigestEntry{},
+		&SequenceRule{},
+		&SequenceState{},
+		&CheckRule{},
+		&Activity{},
+		&QueryRule{},
+		&AlertAck{},
+		&Webhook{},
+		&WebhookDelivery{},
 		&CoinBalanceChangeEvent{},
 		&DeleteObjectEvent{},
 		&MoveEvent{},
@@ -16,6 +32,25 @@ func Migration(db *gorm.DB) error {
 	)
 }
 
+// migrateSequenceStates drops the checkpoints keyed by sequence and key only, before the step was
+// part of the primary key. The partial matches in progress at the upgrade are lost.
+func migrateSequenceStates(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasTable(&SequenceState{}) {
+		return nil
+	}
+	columns, err := m.ColumnTypes(&SequenceState{})
+	if err != nil {
+		return err
+	}
+	for _, c := range columns {
+		if pk, ok := c.PrimaryKey(); c.Name() == "step" && (pk || !ok) {
+			return nil
+		}
+	}
+	return m.DropTable(&SequenceState{})
+}
+
 type Model interface {
 	TableName() string
 }
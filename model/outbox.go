@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+type OutboxStatus string
+
+const (
+	// OutboxPending is a notification waiting to be delivered, or retried after a failure.
+	OutboxPending OutboxStatus = "pending"
+	// OutboxDead is a notification that failed too many times, it is only retried when re-driven.
+	OutboxDead OutboxStatus = "dead"
+)
+
+// OutboxMessage is an alert stored until its notification is delivered, it is removed once delivered.
+// The messages of a destination are delivered one at a time, in the order they were stored.
+type OutboxMessage struct {
+	ID uint `json:"id" gorm:"primaryKey"`
+	// Destination is the recipient of the alert, see Alert.Recipient
+	Destination   string       `json:"destination" gorm:"not null;index"`
+	Alert         Alert        `json:"alert" gorm:"serializer:alert"`
+	Status        OutboxStatus `json:"status" gorm:"not null;index"`
+	Attempts      int          `json:"attempts"`
+	NextAttemptAt time.Time    `json:"next_attempt_at" gorm:"index"`
+	LastError     string       `json:"last_error,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
+
+func (*OutboxMessage) TableName() string {
+	return "notification_outbox"
+}
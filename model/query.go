@@ -0,0 +1,123 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CompareOp compares the result of a query with a threshold.
+type CompareOp string
+
+const (
+	OpGreater      CompareOp = ">"
+	OpGreaterEqual CompareOp = ">="
+	OpLess         CompareOp = "<"
+	OpLessEqual    CompareOp = "<="
+	OpEqual        CompareOp = "=="
+	OpNotEqual     CompareOp = "!="
+)
+
+// Compare reports whether v op threshold holds.
+func (op CompareOp) Compare(v, threshold float64) bool {
+	switch op {
+	case OpGreater:
+		return v > threshold
+	case OpGreaterEqual:
+		return v >= threshold
+	case OpLess:
+		return v < threshold
+	case OpLessEqual:
+		return v <= threshold
+	case OpEqual:
+		return v == threshold
+	case OpNotEqual:
+		return v != threshold
+	}
+	return false
+}
+
+// QueryRule is a read-only SQL query over the stored events, run on a schedule.
+// It fires when the first column of the first row, 0 without rows, meets the threshold.
+// The query can use the named parameters @since and @now, the start and the end of the window,
+// @since_ms and @now_ms in milliseconds like the timestamps of the events, and its own Params.
+// e.g. SELECT COUNT(DISTINCT sender) FROM move_events WHERE package_id = @package AND timestamp >= @since_ms
+type QueryRule struct {
+	ID     uint   `json:"id" gorm:"primaryKey"`
+	UserID uint   `json:"user_id" gorm:"index"`
+	User   User   `json:"-"`
+	Name   string `json:"name"`
+	Query  string `json:"query"`
+	// Values of the named parameters of the query
+	Params map[string]string `json:"params" gorm:"serializer:json"`
+	// How often the query runs
+	Interval time.Duration `json:"interval"`
+	// Time covered by the query, from @since to @now
+	Window    time.Duration `json:"window"`
+	Op        CompareOp     `json:"op"`
+	Threshold float64       `json:"threshold"`
+	Actions   []Action      `json:"actions" gorm:"serializer:json"`
+	Paused    bool          `json:"paused" gorm:"not null;default:false"`
+	LastRunAt *time.Time    `json:"last_run_at"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+func (*QueryRule) TableName() string {
+	return "query_rules"
+}
+
+// GetActions returns the actions of the rule, or DefaultActions if it has none.
+func (r *QueryRule) GetActions() []Action {
+	if len(r.Actions) == 0 {
+		return DefaultActions
+	}
+	return r.Actions
+}
+
+// Due reports whether the query has to run at t.
+func (r *QueryRule) Due(t time.Time) bool {
+	return !r.Paused && (r.LastRunAt == nil || t.Sub(*r.LastRunAt) >= r.Interval)
+}
+
+var (
+	paramName   = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	reservedArg = map[string]bool{"since": true, "now": true, "since_ms": true, "now_ms": true}
+)
+
+func (r *QueryRule) Validate() error {
+	q := strings.TrimSuffix(strings.TrimSpace(r.Query), ";")
+	lower := strings.ToLower(q)
+	if !strings.HasPrefix(lower, "select") && !strings.HasPrefix(lower, "with") {
+		return fmt.Errorf("query must be a select")
+	}
+	if strings.Contains(q, ";") {
+		return fmt.Errorf("query must be a single statement")
+	}
+	for name := range r.Params {
+		if !paramName.MatchString(name) {
+			return fmt.Errorf("invalid parameter name %q", name)
+		}
+		if reservedArg[name] {
+			return fmt.Errorf("parameter %q is reserved", name)
+		}
+	}
+	switch r.Op {
+	case OpGreater, OpGreaterEqual, OpLess, OpLessEqual, OpEqual, OpNotEqual:
+	default:
+		return fmt.Errorf("invalid operator %q", r.Op)
+	}
+	if r.Interval < time.Minute {
+		return fmt.Errorf("interval must be at least a minute")
+	}
+	for i := range r.Actions {
+		if err := r.Actions[i].Validate(); err != nil {
+			return fmt.Errorf("action %d: %w", i, err)
+		}
+		if r.Actions[i].Type == ActionTag || r.Actions[i].Type == ActionStop {
+			return fmt.Errorf("action %d: %s is not supported by queries", i, r.Actions[i].Type)
+		}
+	}
+	return nil
+}
@@ -2,6 +2,8 @@ package model
 
 import (
 	"bytes"
+	"fmt"
+	"regexp"
 	"text/template"
 	"time"
 
@@ -16,20 +18,115 @@ type Rule struct {
 	UserID    uint            `json:"user_id" gorm:"primaryKey,autoIncrement:false,priority:1,index"`
 	User      User            `json:"-"`
 	Condition string          `json:"condition"`
-	CreatedAt time.Time       `json:"created_at"`
-	UpdatedAt time.Time       `json:"updated_at"`
+	Salience  int             `json:"salience" gorm:"not null;default:10"`
+	Actions   []Action        `json:"actions" gorm:"serializer:json"`
+	// Severity of the alerts, unless set by the actions
+	Severity   Severity    `json:"severity" gorm:"not null;default:info"`
+	Escalation *Escalation `json:"escalation" gorm:"serializer:json"`
+	Paused     bool        `json:"paused" gorm:"not null;default:false"`
+	// Shadow rules only record their matches, their actions are skipped
+	Shadow    bool       `json:"shadow" gorm:"not null;default:false"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	// MutedUntil suppresses the notifications of the rule until then, the matches are still recorded
+	MutedUntil *time.Time `json:"muted_until"`
+	// Destination of the notifications instead of the owner, e.g. "discord:<channel id>"
+	Destination string `json:"destination,omitempty"`
+	// GuildID is the Discord server of the destination, its members holding RoleID
+	// can manage the rule as well as its owner
+	GuildID string `json:"guild_id,omitempty" gorm:"index"`
+	RoleID  string `json:"role_id,omitempty"`
+	// Digest mode of the notifications, the mode of the user if empty
+	Digest    DigestMode `json:"digest,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
 }
 
 func (*Rule) TableName() string {
 	return "rules"
 }
 
+// Name returns the name of the rule in GRL.
+func (r *Rule) Name() string {
+	return fmt.Sprintf("Rule%d", r.ID)
+}
+
+// GetActions returns the actions of the rule, or DefaultActions if it has none.
+func (r *Rule) GetActions() []Action {
+	if len(r.Actions) == 0 {
+		return DefaultActions
+	}
+	return r.Actions
+}
+
+// Active reports whether the rule is neither paused nor expired at t.
+func (r *Rule) Active(t time.Time) bool {
+	if r.Paused {
+		return false
+	}
+	return r.ExpiresAt == nil || t.Before(*r.ExpiresAt)
+}
+
+// Muted reports whether the notifications of the rule are suppressed at t.
+func (r *Rule) Muted(t time.Time) bool {
+	return r.MutedUntil != nil && t.Before(*r.MutedUntil)
+}
+
+func (r *Rule) Validate() error {
+	if err := ValidateCondition(r.Event, r.Condition); err != nil {
+		return err
+	}
+	if r.Severity != "" && !r.Severity.Valid() {
+		return fmt.Errorf("unknown severity: %s", r.Severity)
+	}
+	if !r.Digest.Valid() {
+		return fmt.Errorf("unknown digest mode: %s", r.Digest)
+	}
+	if r.RoleID != "" && r.GuildID == "" {
+		return fmt.Errorf("a role requires a guild")
+	}
+	if r.Escalation != nil {
+		if err := r.Escalation.Validate(); err != nil {
+			return err
+		}
+	}
+	for i := range r.Actions {
+		if err := r.Actions[i].Validate(); err != nil {
+			return fmt.Errorf("action %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+var (
+	// rawAmount matches the amount of a coin balance change compared directly,
+	// it is a big integer the rule engine can't compare
+	rawAmount = regexp.MustCompile(`\bEvent\.Amount\b`)
+	// amountValue matches the values the amount of a coin balance change is compared with
+	amountValue = regexp.MustCompile(`\bEvent\.(?:CompareAmount|AmountGreaterThan|AmountLessThan)\(\s*"([^"]*)"\s*\)`)
+)
+
+// ValidateCondition rejects the conditions on an event the rule engine compiles but fails to evaluate.
+func ValidateCondition(event types.EventType, cond string) error {
+	if event != types.EventTypeCoinBalanceChange {
+		return nil
+	}
+	if rawAmount.MatchString(cond) {
+		return fmt.Errorf(`Event.Amount can't be compared, use Event.CompareAmount("1.5") > 0 or Event.AmountGreaterThan("1.5")`)
+	}
+	for _, m := range amountValue.FindAllStringSubmatch(cond, -1) {
+		if _, err := types.ParseAmount(m[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *Rule) AfterCreate(tx *gorm.DB) (err error) {
-	return tx.Model(r.User).Update("rule_count", gorm.Expr("rule_count + ?", 1)).Error
+	return tx.Model(&User{ID: r.UserID}).Update("rule_count", gorm.Expr("rule_count + ?", 1)).Error
 }
 
 func (r *Rule) AfterDelete(tx *gorm.DB) (err error) {
-	return tx.Model(r.User).Update("rule_count", gorm.Expr("rule_count - ?", 1)).Error
+	return tx.Model(&User{ID: r.UserID}).Update("rule_count", gorm.Expr("rule_count - ?", 1)).Error
 }
 
 var (
@@ -38,11 +135,11 @@ var (
 
 func init() {
 	grl := `
-rule Rule{{ .ID }} "" salience 10  {
+rule {{ .Name }} "" salience {{ .Salience }}  {
     when
         {{ .Condition }}
     then
-		Event.CoinType = "xxxx";
+		Retract("{{ .Name }}");
 }
 `
 	t, err := template.New("rule").Parse(grl)
@@ -53,8 +150,18 @@ rule Rule{{ .ID }} "" salience 10  {
 }
 
 func (r *Rule) BuildGRL() ([]byte, error) {
+	return BuildGRL(r.Name(), r.Salience, r.Condition)
+}
+
+// BuildGRL builds a GRL rule that only matches, its actions are executed by the engine.
+func BuildGRL(name string, salience int, condition string) ([]byte, error) {
 	var buf bytes.Buffer
-	if err := gtp.Execute(&buf, r); err != nil {
+	err := gtp.Execute(&buf, struct {
+		Name      string
+		Salience  int
+		Condition string
+	}{name, salience, condition})
+	if err != nil {
 		return nil, err
 	}
 	return buf.Bytes(), nil
@@ -0,0 +1,133 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/strahe/suialert/types"
+)
+
+type ChangeType string
+
+const (
+	ChangeCreated  ChangeType = "created"
+	ChangeUpdated  ChangeType = "updated"
+	ChangeDeleted  ChangeType = "deleted"
+	ChangePaused   ChangeType = "paused"
+	ChangeResumed  ChangeType = "resumed"
+	ChangeRestored ChangeType = "restored"
+)
+
+// ChangeSource is where a change was made from.
+type ChangeSource string
+
+const (
+	SourceDiscord  ChangeSource = "discord"
+	SourceTelegram ChangeSource = "telegram"
+	SourceCLI      ChangeSource = "cli"
+	SourceAPI      ChangeSource = "api"
+	SourceSystem   ChangeSource = "system"
+)
+
+// Actor is who made a change.
+type Actor struct {
+	Source ChangeSource `json:"source"`
+	Name   string       `json:"name"`
+}
+
+func (a Actor) String() string {
+	if a.Name == "" {
+		return string(a.Source)
+	}
+	return fmt.Sprintf("%s (%s)", a.Name, a.Source)
+}
+
+// FieldChange is the old and new value of a changed field of a rule.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// RuleHistory is a version of a rule, recorded for every change.
+// Snapshot is the rule after the change, or before it when deleted.
+type RuleHistory struct {
+	ID        uint          `json:"id" gorm:"primaryKey"`
+	RuleID    uint          `json:"rule_id" gorm:"not null;index"`
+	Version   int           `json:"version" gorm:"not null"`
+	Change    ChangeType    `json:"change" gorm:"not null"`
+	Actor     string        `json:"actor"`
+	Source    ChangeSource  `json:"source"`
+	Diff      []FieldChange `json:"diff" gorm:"serializer:json"`
+	Snapshot  Rule          `json:"snapshot" gorm:"serializer:json"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+func (*RuleHistory) TableName() string {
+	return "rule_histories"
+}
+
+// DiffRules returns the changes of the editable fields from old to new, either can be nil.
+func DiffRules(old, new *Rule) []FieldChange {
+	if old == nil {
+		old = &Rule{}
+	}
+	if new == nil {
+		new = &Rule{}
+	}
+	var changes []FieldChange
+	add := func(field string, o, n interface{}) {
+		os, ns := diffValue(o), diffValue(n)
+		if os != ns {
+			changes = append(changes, FieldChange{Field: field, Old: os, New: ns})
+		}
+	}
+	add("address", old.Address, new.Address)
+	add("event", old.Event, new.Event)
+	add("condition", old.Condition, new.Condition)
+	add("salience", old.Salience, new.Salience)
+	add("actions", old.Actions, new.Actions)
+	add("severity", old.Severity, new.Severity)
+	add("escalation", old.Escalation, new.Escalation)
+	add("paused", old.Paused, new.Paused)
+	add("shadow", old.Shadow, new.Shadow)
+	add("expires_at", old.ExpiresAt, new.ExpiresAt)
+	add("muted_until", old.MutedUntil, new.MutedUntil)
+	add("destination", old.Destination, new.Destination)
+	add("guild_id", old.GuildID, new.GuildID)
+	add("role_id", old.RoleID, new.RoleID)
+	add("digest", old.Digest, new.Digest)
+	return changes
+}
+
+func diffValue(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case []Action:
+		if len(x) == 0 {
+			return ""
+		}
+		b, _ := json.Marshal(x)
+		return string(b)
+	case types.Address:
+		if x == (types.Address{}) {
+			return ""
+		}
+		return x.Hex()
+	case *Escalation:
+		if x == nil {
+			return ""
+		}
+		return x.String()
+	case *time.Time:
+		if x == nil {
+			return ""
+		}
+		return x.UTC().Format(time.RFC3339)
+	case fmt.Stringer:
+		return x.String()
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/strahe/suialert/types"
+)
+
+// RuleSpec is the portable form of a rule, used to export and import rules.
+type RuleSpec struct {
+	UserID      uint            `json:"user_id" yaml:"user_id"`
+	Address     string          `json:"address" yaml:"address"`
+	Event       types.EventType `json:"event" yaml:"event"`
+	Condition   string          `json:"condition" yaml:"condition"`
+	Salience    int             `json:"salience,omitempty" yaml:"salience,omitempty"`
+	Actions     []Action        `json:"actions,omitempty" yaml:"actions,omitempty"`
+	Severity    Severity        `json:"severity,omitempty" yaml:"severity,omitempty"`
+	Escalation  *Escalation     `json:"escalation,omitempty" yaml:"escalation,omitempty"`
+	Paused      bool            `json:"paused,omitempty" yaml:"paused,omitempty"`
+	Shadow      bool            `json:"shadow,omitempty" yaml:"shadow,omitempty"`
+	ExpiresAt   *time.Time      `json:"expires_at,omitempty" yaml:"expires_at,omitempty"`
+	Destination string          `json:"destination,omitempty" yaml:"destination,omitempty"`
+	GuildID     string          `json:"guild_id,omitempty" yaml:"guild_id,omitempty"`
+	RoleID      string          `json:"role_id,omitempty" yaml:"role_id,omitempty"`
+	Digest      DigestMode      `json:"digest,omitempty" yaml:"digest,omitempty"`
+}
+
+// RuleFile is the document of exported rules.
+type RuleFile struct {
+	Rules []RuleSpec `json:"rules" yaml:"rules"`
+}
+
+func NewRuleSpec(r *Rule) RuleSpec {
+	return RuleSpec{
+		UserID:      r.UserID,
+		Address:     r.Address.Hex(),
+		Event:       r.Event,
+		Condition:   r.Condition,
+		Salience:    r.Salience,
+		Actions:     r.Actions,
+		Severity:    r.Severity,
+		Escalation:  r.Escalation,
+		Paused:      r.Paused,
+		Shadow:      r.Shadow,
+		ExpiresAt:   r.ExpiresAt,
+		Destination: r.Destination,
+		GuildID:     r.GuildID,
+		RoleID:      r.RoleID,
+		Digest:      r.Digest,
+	}
+}
+
+// Rule returns the rule described by the spec, the salience defaults to 10.
+func (s *RuleSpec) Rule() (*Rule, error) {
+	if s.UserID == 0 {
+		return nil, fmt.Errorf("user_id is required")
+	}
+	if !types.IsHexAddress(s.Address) {
+		return nil, fmt.Errorf("invalid address %q", s.Address)
+	}
+	if !s.Event.Known() {
+		return nil, fmt.Errorf("unknown event %q", s.Event)
+	}
+	if s.Condition == "" {
+		return nil, fmt.Errorf("condition is required")
+	}
+	r := &Rule{
+		UserID:      s.UserID,
+		Address:     types.HexToAddress(s.Address),
+		Event:       s.Event,
+		Condition:   s.Condition,
+		Salience:    s.Salience,
+		Actions:     s.Actions,
+		Severity:    s.Severity,
+		Escalation:  s.Escalation,
+		Paused:      s.Paused,
+		Shadow:      s.Shadow,
+		ExpiresAt:   s.ExpiresAt,
+		Destination: s.Destination,
+		GuildID:     s.GuildID,
+		RoleID:      s.RoleID,
+		Digest:      s.Digest,
+	}
+	if r.Salience == 0 {
+		r.Salience = 10
+	}
+	if r.Severity == "" {
+		r.Severity = SeverityInfo
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
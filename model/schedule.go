@@ -0,0 +1,157 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const clockLayout = "15:04"
+
+// Schedule defines when a user wants to receive alerts.
+type Schedule struct {
+	// Days on which alerts are sent, every day if empty
+	Weekdays []time.Weekday `json:"weekdays,omitempty"`
+	// Quiet hours in the user's timezone, formatted as "15:04".
+	// Alerts are held between QuietStart and QuietEnd, and sent when the quiet period ends.
+	QuietStart string `json:"quiet_start,omitempty"`
+	QuietEnd   string `json:"quiet_end,omitempty"`
+	// Digest delivers the alerts hourly or daily in a summary, they are sent in real time if empty.
+	// Rules may have their own mode.
+	Digest DigestMode `json:"digest,omitempty"`
+	// DigestHour is the hour of the day daily digests are sent, in the user's timezone
+	DigestHour int `json:"digest_hour,omitempty"`
+}
+
+func (s *Schedule) Validate() error {
+	for _, d := range s.Weekdays {
+		if d < time.Sunday || d > time.Saturday {
+			return fmt.Errorf("invalid weekday: %d", d)
+		}
+	}
+	if !s.Digest.Valid() {
+		return fmt.Errorf("unknown digest mode: %s", s.Digest)
+	}
+	if s.DigestHour < 0 || s.DigestHour > 23 {
+		return fmt.Errorf("invalid digest hour: %d", s.DigestHour)
+	}
+	if (s.QuietStart == "") != (s.QuietEnd == "") {
+		return fmt.Errorf("quiet hours require both a start and an end")
+	}
+	if s.QuietStart != "" {
+		if _, err := time.Parse(clockLayout, s.QuietStart); err != nil {
+			return fmt.Errorf("invalid quiet start: %s", err)
+		}
+		if _, err := time.Parse(clockLayout, s.QuietEnd); err != nil {
+			return fmt.Errorf("invalid quiet end: %s", err)
+		}
+	}
+	return nil
+}
+
+// ActiveOn reports whether alerts are sent on the day of t.
+func (s *Schedule) ActiveOn(t time.Time) bool {
+	if len(s.Weekdays) == 0 {
+		return true
+	}
+	for _, d := range s.Weekdays {
+		if d == t.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// QuietUntil returns the end of the quiet period t is in, quiet hours may span midnight.
+func (s *Schedule) QuietUntil(t time.Time) (time.Time, bool) {
+	if s.QuietStart == "" || s.QuietEnd == "" {
+		return time.Time{}, false
+	}
+	start, err := time.Parse(clockLayout, s.QuietStart)
+	if err != nil {
+		return time.Time{}, false
+	}
+	end, err := time.Parse(clockLayout, s.QuietEnd)
+	if err != nil {
+		return time.Time{}, false
+	}
+	var (
+		now   = t.Hour()*60 + t.Minute()
+		from  = start.Hour()*60 + start.Minute()
+		to    = end.Hour()*60 + end.Minute()
+		today = time.Date(t.Year(), t.Month(), t.Day(), end.Hour(), end.Minute(), 0, 0, t.Location())
+	)
+	switch {
+	case from < to && now >= from && now < to:
+		return today, true
+	case from > to && now >= from:
+		return today.AddDate(0, 0, 1), true
+	case from > to && now < to:
+		return today, true
+	}
+	return time.Time{}, false
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseWeekdays parses the days alerts are sent on, e.g. "mon,tue,fri", "all" is every day.
+func ParseWeekdays(s string) ([]time.Weekday, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" || s == "all" {
+		return nil, nil
+	}
+	seen := map[time.Weekday]bool{}
+	var days []time.Weekday
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		d, ok := weekdayNames[name]
+		if !ok && len(name) > 3 {
+			// full names, e.g. "monday"
+			d, ok = weekdayNames[name[:3]]
+			ok = ok && strings.EqualFold(d.String(), name)
+		}
+		if !ok {
+			return nil, fmt.Errorf("invalid weekday: %q", name)
+		}
+		if !seen[d] {
+			seen[d] = true
+			days = append(days, d)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i] < days[j] })
+	return days, nil
+}
+
+// ParseQuietHours parses quiet hours formatted as "22:00-07:00", "off" has none.
+func ParseQuietHours(s string) (start, end string, err error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" || s == "off" {
+		return "", "", nil
+	}
+	start, end, ok := strings.Cut(s, "-")
+	if !ok {
+		return "", "", fmt.Errorf("invalid quiet hours %q, expected a start and an end, e.g. 22:00-07:00", s)
+	}
+	return strings.TrimSpace(start), strings.TrimSpace(end), nil
+}
+
+// String describes when the alerts are sent, e.g. "mon,tue,wed, quiet 22:00-07:00".
+func (s *Schedule) String() string {
+	days := "every day"
+	if len(s.Weekdays) > 0 {
+		names := make([]string, len(s.Weekdays))
+		for i, d := range s.Weekdays {
+			names[i] = strings.ToLower(d.String()[:3])
+		}
+		days = strings.Join(names, ",")
+	}
+	quiet := "no quiet hours"
+	if s.QuietStart != "" {
+		quiet = fmt.Sprintf("quiet %s-%s", s.QuietStart, s.QuietEnd)
+	}
+	return days + ", " + quiet
+}
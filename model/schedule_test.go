@@ -0,0 +1,65 @@
+package model_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/strahe/suialert/model"
+)
+
+func TestQuietUntil(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day, hour, min int, loc *time.Location) time.Time {
+		return time.Date(2023, time.March, day, hour, min, 0, 0, loc)
+	}
+	for _, tc := range []struct {
+		name       string
+		start, end string
+		t          time.Time
+		until      time.Time
+		quiet      bool
+	}{
+		{"no quiet hours", "", "", at(1, 12, 0, time.UTC), time.Time{}, false},
+		{"within", "12:00", "14:00", at(1, 13, 30, time.UTC), at(1, 14, 0, time.UTC), true},
+		{"at the start", "12:00", "14:00", at(1, 12, 0, time.UTC), at(1, 14, 0, time.UTC), true},
+		{"at the end", "12:00", "14:00", at(1, 14, 0, time.UTC), time.Time{}, false},
+		{"before", "12:00", "14:00", at(1, 11, 59, time.UTC), time.Time{}, false},
+		{"across midnight, before midnight", "22:00", "07:00", at(1, 23, 30, time.UTC), at(2, 7, 0, time.UTC), true},
+		{"across midnight, after midnight", "22:00", "07:00", at(2, 6, 59, time.UTC), at(2, 7, 0, time.UTC), true},
+		{"across midnight, during the day", "22:00", "07:00", at(2, 12, 0, time.UTC), time.Time{}, false},
+		{"across the end of the month", "22:00", "07:00", at(31, 22, 0, time.UTC), time.Date(2023, time.April, 1, 7, 0, 0, 0, time.UTC), true},
+		// the quiet hours are in the timezone of the time, 21:30 UTC is 23:30 in Paris in summer
+		{"timezone", "22:00", "07:00", time.Date(2023, time.July, 1, 21, 30, 0, 0, time.UTC).In(paris),
+			time.Date(2023, time.July, 2, 7, 0, 0, 0, paris), true},
+		{"timezone, outside", "22:00", "07:00", time.Date(2023, time.July, 1, 5, 30, 0, 0, time.UTC).In(paris),
+			time.Time{}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := model.Schedule{QuietStart: tc.start, QuietEnd: tc.end}
+			until, quiet := s.QuietUntil(tc.t)
+			if quiet != tc.quiet || !until.Equal(tc.until) {
+				t.Errorf("QuietUntil(%s) = %s, %t, want %s, %t", tc.t, until, quiet, tc.until, tc.quiet)
+			}
+		})
+	}
+}
+
+func TestParseWeekdays(t *testing.T) {
+	days, err := model.ParseWeekdays("fri, Monday,tue,mon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := model.Schedule{Weekdays: days}
+	if got := s.String(); got != "mon,tue,fri, no quiet hours" {
+		t.Errorf("unexpected schedule %q", got)
+	}
+	if days, err := model.ParseWeekdays("all"); err != nil || days != nil {
+		t.Errorf("all: %v %v", days, err)
+	}
+	if _, err := model.ParseWeekdays("mon,someday"); err == nil {
+		t.Error("invalid weekday accepted")
+	}
+}
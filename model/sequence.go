@@ -0,0 +1,117 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/strahe/suialert/types"
+)
+
+// CorrelateBy is the field events of a sequence must share.
+type CorrelateBy string
+
+const (
+	CorrelateByObject   CorrelateBy = "object"
+	CorrelateBySender   CorrelateBy = "sender"
+	CorrelateByTxDigest CorrelateBy = "tx"
+)
+
+// SequenceStep is one event of a sequence.
+type SequenceStep struct {
+	Event types.EventType `json:"event"`
+	// GRL condition on the event, any event of the type matches if empty
+	Condition string `json:"condition,omitempty"`
+}
+
+// SequenceRule matches events happening in order, correlated by a shared field,
+// e.g. a NewObject followed by a TransferObject of the same object within 5 minutes.
+type SequenceRule struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	UserID      uint           `json:"user_id" gorm:"index"`
+	User        User           `json:"-"`
+	Name        string         `json:"name"`
+	Steps       []SequenceStep `json:"steps" gorm:"serializer:json"`
+	CorrelateBy CorrelateBy    `json:"correlate_by"`
+	// Time allowed between the first and the last step
+	Window    time.Duration `json:"window"`
+	Actions   []Action      `json:"actions" gorm:"serializer:json"`
+	Paused    bool          `json:"paused" gorm:"not null;default:false"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+func (*SequenceRule) TableName() string {
+	return "sequence_rules"
+}
+
+// StepName returns the name of a step in GRL.
+func (r *SequenceRule) StepName(i int) string {
+	return fmt.Sprintf("Seq%dStep%d", r.ID, i)
+}
+
+// BuildStepGRL builds the GRL rule matching a step.
+func (r *SequenceRule) BuildStepGRL(i int) ([]byte, error) {
+	cond := strings.TrimSpace(r.Steps[i].Condition)
+	if cond == "" {
+		cond = "true"
+	}
+	return BuildGRL(r.StepName(i), 0, cond)
+}
+
+// GetActions returns the actions of the rule, or DefaultActions if it has none.
+func (r *SequenceRule) GetActions() []Action {
+	if len(r.Actions) == 0 {
+		return DefaultActions
+	}
+	return r.Actions
+}
+
+func (r *SequenceRule) Validate() error {
+	if len(r.Steps) < 2 {
+		return fmt.Errorf("a sequence needs at least two steps")
+	}
+	for i, st := range r.Steps {
+		if st.Event == "" {
+			return fmt.Errorf("step %d: missing event type", i)
+		}
+		if !st.Event.Known() {
+			return fmt.Errorf("step %d: unknown event %q", i, st.Event)
+		}
+		if err := ValidateCondition(st.Event, st.Condition); err != nil {
+			return fmt.Errorf("step %d: %w", i, err)
+		}
+	}
+	switch r.CorrelateBy {
+	case CorrelateByObject, CorrelateBySender, CorrelateByTxDigest:
+	default:
+		return fmt.Errorf("unknown correlation: %s", r.CorrelateBy)
+	}
+	if r.Window <= 0 {
+		return fmt.Errorf("the window must be positive")
+	}
+	for i := range r.Actions {
+		if r.Actions[i].Type == ActionTag || r.Actions[i].Type == ActionStop {
+			return fmt.Errorf("action %d: %s is not supported by sequences", i, r.Actions[i].Type)
+		}
+		if err := r.Actions[i].Validate(); err != nil {
+			return fmt.Errorf("action %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// SequenceState is the checkpoint of a partial match of a sequence.
+// A key has at most one partial match per step.
+type SequenceState struct {
+	SequenceID uint      `json:"sequence_id" gorm:"primaryKey;autoIncrement:false"`
+	Key        string    `json:"key" gorm:"primaryKey"`
+	Step       int       `json:"step" gorm:"primaryKey;autoIncrement:false"`
+	TxDigests  []string  `json:"tx_digests" gorm:"serializer:json"`
+	StartedAt  time.Time `json:"started_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+func (*SequenceState) TableName() string {
+	return "sequence_states"
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/strahe/suialert/types"
+)
+
+// StaticRule is a system-wide rule managed as code, from the config or a rule file.
+// It has no owner, its alerts are routed to Channel.
+type StaticRule struct {
+	Name      string          `yaml:"name" json:"name" mapstructure:"name"`
+	Address   string          `yaml:"address" json:"address" mapstructure:"address"`
+	Event     types.EventType `yaml:"event" json:"event" mapstructure:"event"`
+	Condition string          `yaml:"condition" json:"condition" mapstructure:"condition"`
+	Salience  int             `yaml:"salience" json:"salience" mapstructure:"salience"`
+	// Channel the alerts are sent to
+	Channel    string      `yaml:"channel" json:"channel" mapstructure:"channel"`
+	Severity   Severity    `yaml:"severity" json:"severity" mapstructure:"severity"`
+	Escalation *Escalation `yaml:"escalation" json:"escalation" mapstructure:"escalation"`
+	Actions    []Action    `yaml:"actions" json:"actions" mapstructure:"actions"`
+}
+
+// StaticRuleFile is a file of static rules.
+type StaticRuleFile struct {
+	Rules []StaticRule `yaml:"rules" json:"rules"`
+}
+
+// GetActions returns the actions of the rule, notify actions are sent to the channel of the rule.
+func (r *StaticRule) GetActions() []Action {
+	actions := r.Actions
+	if len(actions) == 0 {
+		actions = []Action{{Type: ActionNotify, Severity: r.Severity}}
+	}
+	routed := make([]Action, len(actions))
+	for i, act := range actions {
+		if act.Type == ActionNotify {
+			act.Type = ActionEscalate
+			act.Channel = r.Channel
+		}
+		if act.Severity == "" {
+			act.Severity = r.Severity
+		}
+		routed[i] = act
+	}
+	return routed
+}
+
+func (r *StaticRule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !types.IsHexAddress(r.Address) {
+		return fmt.Errorf("invalid address %q", r.Address)
+	}
+	if !r.Event.Known() {
+		return fmt.Errorf("unknown event %q", r.Event)
+	}
+	if r.Condition == "" {
+		return fmt.Errorf("condition is required")
+	}
+	if err := ValidateCondition(r.Event, r.Condition); err != nil {
+		return err
+	}
+	if r.Severity != "" && !r.Severity.Valid() {
+		return fmt.Errorf("unknown severity: %s", r.Severity)
+	}
+	if r.Escalation != nil {
+		if err := r.Escalation.Validate(); err != nil {
+			return err
+		}
+	}
+	notify := len(r.Actions) == 0
+	for i := range r.Actions {
+		if err := r.Actions[i].Validate(); err != nil {
+			return fmt.Errorf("action %d: %w", i, err)
+		}
+		if r.Actions[i].Type == ActionNotify {
+			notify = true
+		}
+	}
+	if notify && r.Channel == "" {
+		return fmt.Errorf("channel is required to notify")
+	}
+	return nil
+}
@@ -15,10 +15,40 @@ type User struct {
 	TelegramID   *int64          `json:"telegram_id" gorm:"index"`
 	TelegramInfo *telebot.User   `json:"telegram_info" gorm:"serializer:json"`
 	RuleCount    int             `json:"rule_count" gorm:"not null"`
+	Timezone     string          `json:"timezone"`
+	Schedule     Schedule        `json:"schedule" gorm:"serializer:json"`
 	CreatedAt    time.Time       `json:"created_at"`
 	UpdatedAt    time.Time       `json:"updated_at"`
+
+	// Email is only used once verified
+	Email         *string    `json:"email" gorm:"index"`
+	EmailVerified bool       `json:"email_verified" gorm:"not null;default:false"`
+	EmailCode     string     `json:"-"`
+	EmailCodeAt   *time.Time `json:"-"`
+	// Number of wrong codes tried since the code was sent
+	EmailAttempts int `json:"-" gorm:"not null;default:0"`
 }
 
 func (*User) TableName() string {
 	return "users"
 }
+
+// VerifiedEmail returns the email address of the user, empty if not verified.
+func (u *User) VerifiedEmail() string {
+	if u.Email == nil || !u.EmailVerified {
+		return ""
+	}
+	return *u.Email
+}
+
+// Location returns the timezone of the user, UTC if not set or invalid.
+func (u *User) Location() *time.Location {
+	if u.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(u.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Webhook is an endpoint the alerts of a user are posted to, signed with its secret.
+// Webhooks of no user are only used by the rules routing alerts to them.
+type Webhook struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"index"`
+	Name      string    `json:"name"`
+	URL       string    `json:"url" gorm:"not null"`
+	Secret    string    `json:"-"`
+	Paused    bool      `json:"paused" gorm:"not null;default:false"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (*Webhook) TableName() string {
+	return "webhooks"
+}
+
+// Destination returns the destination of the alerts routed to the webhook.
+func (w *Webhook) Destination() string {
+	return fmt.Sprintf("webhook:%d", w.ID)
+}
+
+func (w *Webhook) Validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook url: %s", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid webhook url scheme: %s", u.Scheme)
+	}
+	if w.Secret == "" {
+		return fmt.Errorf("webhook secret is required")
+	}
+	return nil
+}
+
+// WebhookDelivery records an attempt to deliver an alert to a webhook.
+// The attempts of a delivery share its DeliveryID.
+type WebhookDelivery struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	WebhookID  uint   `json:"webhook_id" gorm:"index"`
+	DeliveryID string `json:"delivery_id" gorm:"index"`
+	Attempt    int    `json:"attempt"`
+	// Source of the alert
+	Source     string        `json:"source"`
+	StatusCode int           `json:"status_code"`
+	Response   string        `json:"response"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	CreatedAt  time.Time     `json:"created_at" gorm:"index"`
+}
+
+func (*WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// Succeeded reports whether the attempt delivered the alert.
+func (d *WebhookDelivery) Succeeded() bool {
+	return d.Error == "" && d.StatusCode >= 200 && d.StatusCode < 300
+}
@@ -0,0 +1,188 @@
+package rule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
+	"go.uber.org/zap"
+)
+
+// LogNotifier only logs the alerts.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(_ context.Context, alert *model.Alert) error {
+	zap.L().Info("alert",
+		zap.Uint("rule", alert.RuleID),
+		zap.Uint("user", alert.UserID),
+		zap.String("event", string(alert.Event)),
+		zap.String("severity", string(alert.Severity)),
+		zap.String("destination", alert.Destination),
+		zap.String("tx", alert.TxDigest),
+	)
+	return nil
+}
+
+// actor executes the actions of the matched rules.
+type actor struct {
+	notifier Notifier
+	// records the matches, may be nil
+	matches *service.MatchService
+	dryRun  atomic.Bool
+}
+
+// SetDryRun makes the matches only recorded, without any notification sent.
+// Tags are still added and stop actions still apply, so the matches are the same as live.
+func (a *actor) SetDryRun(enable bool) {
+	a.dryRun.Store(enable)
+}
+
+var deliveryRank = map[model.DeliveryStatus]int{
+	model.DeliveryNone:       0,
+	model.DeliverySuppressed: 1,
+	model.DeliveryHeld:       2,
+	model.DeliveryQueued:     3,
+	model.DeliveryDelivered:  4,
+	model.DeliveryFailed:     5,
+}
+
+// delivery accumulates the outcome of the notifications of a match.
+type delivery struct {
+	status model.DeliveryStatus
+	err    error
+}
+
+// add keeps the most significant outcome: failed, delivered, queued, held, then suppressed.
+func (d *delivery) add(err error) {
+	var status model.DeliveryStatus
+	switch {
+	case err == nil:
+		status = model.DeliveryDelivered
+	case errors.Is(err, ErrHeld):
+		status = model.DeliveryHeld
+	case errors.Is(err, ErrQueued):
+		status = model.DeliveryQueued
+	case errors.Is(err, ErrSuppressed):
+		status = model.DeliverySuppressed
+	default:
+		status = model.DeliveryFailed
+		d.err = err
+	}
+	if deliveryRank[status] > deliveryRank[d.status] {
+		d.status = status
+	}
+}
+
+// run executes the actions for the alert of a matched rule, the actions of shadow rules are skipped.
+// The notifications of muted rules are suppressed, their other actions still run.
+// The match is recorded with the outcome of its notifications.
+// It returns the tags to add to the stored event, and whether the rules
+// with a lower salience should be skipped.
+func (a *actor) run(ctx context.Context, actions []model.Action, base *model.Alert, shadow, muted bool) (tags []string, stop bool) {
+	mode := model.MatchLive
+	switch {
+	case shadow:
+		mode = model.MatchShadow
+	case a.dryRun.Load():
+		mode = model.MatchDryRun
+	}
+	d := &delivery{status: model.DeliveryNone}
+	m := a.open(ctx, base, mode)
+	defer func() {
+		a.record(ctx, base, mode, m, d)
+	}()
+	if shadow {
+		d.add(ErrSuppressed)
+		return nil, false
+	}
+	for _, act := range actions {
+		if (mode == model.MatchDryRun || muted) && act.Type != model.ActionTag && act.Type != model.ActionStop {
+			zap.S().Debugf("skipped %s action of %s, muted %t", act.Type, base.Source(), muted)
+			d.add(ErrSuppressed)
+			continue
+		}
+		alert := *base
+		if act.Severity != "" {
+			alert.Severity = act.Severity
+		}
+		if alert.Severity == "" {
+			alert.Severity = model.SeverityInfo
+		}
+		switch act.Type {
+		case model.ActionNotify:
+			err := a.notifier.Notify(ctx, &alert)
+			d.add(err)
+			if IsFailure(err) {
+				zap.S().Errorf("failed to notify %s: %s", alert.Source(), err)
+			}
+		case model.ActionEscalate:
+			alert.Destination = act.Channel
+			err := a.notifier.Notify(ctx, &alert)
+			d.add(err)
+			if IsFailure(err) {
+				zap.S().Errorf("failed to escalate %s to %s: %s", alert.Source(), act.Channel, err)
+			}
+		case model.ActionWebhook:
+			// webhooks are not acknowledged
+			alert.Destination = fmt.Sprintf("webhook:%d", act.Webhook)
+			alert.Escalation = nil
+			err := a.notifier.Notify(ctx, &alert)
+			d.add(err)
+			if IsFailure(err) {
+				zap.S().Errorf("failed to call webhook of %s: %s", alert.Source(), err)
+			}
+		case model.ActionTag:
+			tags = append(tags, act.Tag)
+		case model.ActionStop:
+			stop = true
+		default:
+			zap.S().Warnf("unknown action %s in %s", act.Type, alert.Source())
+		}
+	}
+	return tags, stop
+}
+
+// open records the match of an alert before its notifications, so that the alert carries its ID.
+// It returns nil if the matches are not recorded.
+func (a *actor) open(ctx context.Context, alert *model.Alert, mode model.MatchMode) *model.Match {
+	alert.MatchID = 0
+	if a.matches == nil {
+		return nil
+	}
+	m := model.NewMatch(alert, mode, model.DeliveryNone)
+	if err := a.matches.Record(ctx, m); err != nil {
+		zap.S().Errorf("failed to record match of %s: %s", alert.Source(), err)
+		return nil
+	}
+	alert.MatchID = m.ID
+	return m
+}
+
+// record sets the outcome of the notifications on the match of an alert, m is nil if it was not recorded.
+func (a *actor) record(ctx context.Context, alert *model.Alert, mode model.MatchMode, m *model.Match, d *delivery) {
+	if a.matches == nil {
+		if mode != model.MatchLive {
+			zap.S().Infof("%s matched %s in tx %s", alert.Source(), mode, alert.TxDigest)
+		}
+		return
+	}
+	if m == nil {
+		return
+	}
+	m.Status = d.status
+	if d.err != nil {
+		m.Error = d.err.Error()
+	}
+	if err := a.matches.SetStatus(ctx, m); err != nil {
+		zap.S().Errorf("failed to record delivery of match %d of %s: %s", m.ID, alert.Source(), err)
+	}
+}
+
+// IsFailure reports whether an error of a notifier is a failure to deliver,
+// the alerts suppressed, held or queued are not.
+func IsFailure(err error) bool {
+	return err != nil && !errors.Is(err, ErrHeld) && !errors.Is(err, ErrSuppressed) && !errors.Is(err, ErrQueued)
+}
@@ -0,0 +1,230 @@
+package rule
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
+	"github.com/strahe/suialert/types"
+	"go.uber.org/zap"
+)
+
+// Checker evaluates the check rules on a schedule, they fire when an expected activity
+// of an address did not happen within their period.
+// It tracks the last time the checked addresses were seen from the events.
+type Checker struct {
+	actor
+
+	csv      *service.CheckService
+	interval time.Duration
+
+	lk       sync.RWMutex
+	watched  map[types.Address]bool
+	revision string
+
+	done chan struct{}
+}
+
+// NewChecker creates a checker, alerts are only logged if notifier is nil.
+// Matches are recorded with msv, if not nil.
+func NewChecker(csv *service.CheckService, msv *service.MatchService, notifier Notifier, interval time.Duration) *Checker {
+	if notifier == nil {
+		notifier = LogNotifier{}
+	}
+	c := &Checker{
+		actor: actor{
+			notifier: notifier,
+			matches:  msv,
+		},
+		csv:      csv,
+		interval: interval,
+		watched:  map[types.Address]bool{},
+		done:     make(chan struct{}),
+	}
+	csv.Subscribe(func(r *model.CheckRule) {
+		if err := c.LoadChecks(context.Background()); err != nil {
+			zap.S().Errorf("failed to reload checks after change of %d: %s", r.ID, err)
+		}
+	})
+	return c
+}
+
+func (c *Checker) Start(ctx context.Context) error {
+	if err := c.LoadChecks(ctx); err != nil {
+		return err
+	}
+	go c.loop()
+	return nil
+}
+
+func (c *Checker) Close(context.Context) error {
+	close(c.done)
+	return nil
+}
+
+// LoadChecks updates the addresses to track, those never seen are backfilled from the stored events.
+func (c *Checker) LoadChecks(ctx context.Context) error {
+	rev, err := c.csv.Revision(ctx)
+	if err != nil {
+		return err
+	}
+	checks, err := c.csv.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	watched := make(map[types.Address]bool, len(checks))
+	for _, r := range checks {
+		watched[r.Address] = true
+		if r.Kind != model.CheckInactivity {
+			continue
+		}
+		if err := c.backfill(ctx, &r); err != nil {
+			return err
+		}
+	}
+	c.lk.Lock()
+	c.watched = watched
+	c.revision = rev
+	c.lk.Unlock()
+	return nil
+}
+
+// Refresh reloads the checks if they changed since they were loaded, also by another process.
+func (c *Checker) Refresh(ctx context.Context) error {
+	rev, err := c.csv.Revision(ctx)
+	if err != nil {
+		return err
+	}
+	c.lk.RLock()
+	stale := rev != c.revision
+	c.lk.RUnlock()
+	if !stale {
+		return nil
+	}
+	return c.LoadChecks(ctx)
+}
+
+func (c *Checker) backfill(ctx context.Context, r *model.CheckRule) error {
+	kind := r.ActivityKind()
+	act, err := c.csv.LastSeen(ctx, r.Address, kind)
+	if err != nil || act != nil {
+		return err
+	}
+	last, err := c.csv.LastEventAt(ctx, r.Address, r.Event)
+	if err != nil || last.IsZero() {
+		return err
+	}
+	return c.csv.Seen(ctx, []model.Activity{{Address: r.Address, Kind: kind, LastSeen: last}})
+}
+
+// Observe records the activity of the checked addresses in an event.
+func (c *Checker) Observe(ctx context.Context, er *types.EventResult, event types.EventType, data interface{}) error {
+	c.lk.RLock()
+	watched := c.watched
+	c.lk.RUnlock()
+	if len(watched) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	var txDigest string
+	if er != nil {
+		txDigest = er.Id.TxDigest
+		if er.Timestamp > 0 {
+			now = time.UnixMilli(int64(er.Timestamp))
+		}
+	}
+	var acts []model.Activity
+	if sender, _ := eventFields(data); sender != "" {
+		addr := types.HexToAddress(sender)
+		if watched[addr] {
+			for _, kind := range []string{model.EventKey(event), model.EventKey("")} {
+				acts = append(acts, model.Activity{Address: addr, Kind: kind, LastSeen: now, TxDigest: txDigest})
+			}
+		}
+	}
+	if cb, ok := data.(*types.CoinBalanceChange); ok && cb.Amount.Int().Sign() > 0 {
+		if owner := ownerAddress(cb.Owner); owner != nil && watched[*owner] {
+			acts = append(acts, model.Activity{Address: *owner, Kind: model.InflowKey(cb.CoinType), LastSeen: now, TxDigest: txDigest})
+		}
+	}
+	return c.csv.Seen(ctx, acts)
+}
+
+func (c *Checker) loop() {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	reload := time.NewTicker(reloadInterval)
+	defer reload.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.Check(context.Background()); err != nil {
+				zap.S().Errorf("failed to run checks: %s", err)
+			}
+		case <-reload.C:
+			if err := c.Refresh(context.Background()); err != nil {
+				zap.S().Errorf("failed to reload changed checks: %s", err)
+			}
+		}
+	}
+}
+
+// Check fires the checks whose activity was not seen within their period,
+// since it was last seen, the check was created or it last fired.
+func (c *Checker) Check(ctx context.Context) error {
+	checks, err := c.csv.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for i := range checks {
+		r := &checks[i]
+		if r.Paused {
+			continue
+		}
+		act, err := c.csv.LastSeen(ctx, r.Address, r.ActivityKind())
+		if err != nil {
+			return err
+		}
+		since := r.CreatedAt
+		var lastSeen *time.Time
+		if act != nil {
+			lastSeen = &act.LastSeen
+			if act.LastSeen.After(since) {
+				since = act.LastSeen
+			}
+		}
+		if r.LastFiredAt != nil && r.LastFiredAt.After(since) {
+			since = *r.LastFiredAt
+		}
+		if now.Sub(since) < r.Period {
+			continue
+		}
+
+		alert := &model.Alert{
+			CheckID:   r.ID,
+			UserID:    r.UserID,
+			Event:     types.EventTypeInactivity,
+			Address:   r.Address,
+			Timestamp: uint64(now.UnixMilli()),
+			Data: &types.Inactivity{
+				Check:    string(r.Kind),
+				Name:     r.Name,
+				Period:   r.Period,
+				LastSeen: lastSeen,
+			},
+			CreatedAt: now,
+		}
+		c.run(ctx, r.GetActions(), alert, false, false)
+		if err := c.csv.MarkFired(ctx, r, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
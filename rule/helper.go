@@ -0,0 +1,133 @@
+package rule
+
+import (
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/strahe/suialert/types"
+)
+
+// helperName is the name of the Helper in the conditions, e.g. Helper.AmountIn("SUI") > 100
+const helperName = "Helper"
+
+var (
+	watchlistsLk sync.RWMutex
+	watchlists   = map[string]map[types.Address]bool{}
+)
+
+// RegisterWatchlist sets the addresses of a named list, used by Helper.InWatchlist.
+func RegisterWatchlist(name string, addresses []string) {
+	list := make(map[types.Address]bool, len(addresses))
+	for _, a := range addresses {
+		list[types.HexToAddress(a)] = true
+	}
+	watchlistsLk.Lock()
+	defer watchlistsLk.Unlock()
+	watchlists[strings.ToLower(name)] = list
+}
+
+// Helper offers functions to the conditions of the rules about the event being matched.
+// Its methods must not panic, the engine does not recover from panics of the conditions.
+type Helper struct {
+	data interface{}
+	now  time.Time
+}
+
+func newHelper(data interface{}) *Helper {
+	return &Helper{data: data, now: time.Now()}
+}
+
+// AmountIn returns the amount of a coin balance change in units of the coin,
+// if its symbol or type is coin, 0 otherwise.
+func (h *Helper) AmountIn(coin string) float64 {
+	c, ok := h.data.(*types.CoinBalanceChange)
+	if !ok || !matchCoin(c.CoinType, coin) {
+		return 0
+	}
+	v, _ := c.Value().Float64()
+	return v
+}
+
+// InWatchlist reports whether an address is in a registered watchlist.
+func (h *Helper) InWatchlist(addr string, list string) bool {
+	if !types.IsHexAddress(addr) {
+		return false
+	}
+	watchlistsLk.RLock()
+	defer watchlistsLk.RUnlock()
+	return watchlists[strings.ToLower(list)][types.HexToAddress(addr)]
+}
+
+// IsSelfTransfer reports whether the sender of the event is also the owner of the object or coin.
+func (h *Helper) IsSelfTransfer() bool {
+	var (
+		sender string
+		owner  *types.ObjectOwner
+	)
+	switch d := h.data.(type) {
+	case *types.TransferObject:
+		sender, owner = d.Sender, d.Recipient
+	case *types.CoinBalanceChange:
+		sender, owner = d.Sender, d.Owner
+	default:
+		return false
+	}
+	addr := ownerAddress(owner)
+	return addr != nil && sender != "" && *addr == types.HexToAddress(sender)
+}
+
+// ModuleOf returns the module of a move type, e.g. "sui" for 0x2::sui::SUI.
+func (h *Helper) ModuleOf(typ string) string {
+	// strip the type parameters
+	if i := strings.Index(typ, "<"); i >= 0 {
+		typ = typ[:i]
+	}
+	parts := strings.Split(typ, "::")
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+// HoursSince returns the hours elapsed since a timestamp in milliseconds.
+func (h *Helper) HoursSince(timestamp int64) float64 {
+	return h.now.Sub(time.UnixMilli(timestamp)).Hours()
+}
+
+// ValueUSD returns the value of a coin balance change in USD, 0 if the coin has no price.
+func (h *Helper) ValueUSD() float64 {
+	c, ok := h.data.(*types.CoinBalanceChange)
+	if !ok {
+		return 0
+	}
+	info := types.LookupCoin(c.CoinType)
+	if info.PriceUSD == 0 {
+		return 0
+	}
+	v, _ := new(big.Rat).Mul(c.Value(), new(big.Rat).SetFloat64(info.PriceUSD)).Float64()
+	return v
+}
+
+func matchCoin(coinType, coin string) bool {
+	if strings.Contains(coin, "::") {
+		return strings.EqualFold(coinType, coin)
+	}
+	return strings.EqualFold(types.LookupCoin(coinType).Symbol, coin)
+}
+
+func ownerAddress(o *types.ObjectOwner) *types.Address {
+	if o == nil || o.ObjectOwnerInternal == nil {
+		return nil
+	}
+	switch {
+	case o.AddressOwner != nil:
+		return o.AddressOwner
+	case o.ObjectOwner != nil:
+		return o.ObjectOwner
+	case o.SingleOwner != nil:
+		return o.SingleOwner
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package rule
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/hyperjumptech/grule-rule-engine/ast"
+	"github.com/hyperjumptech/grule-rule-engine/builder"
+	"github.com/hyperjumptech/grule-rule-engine/engine"
+	"github.com/hyperjumptech/grule-rule-engine/pkg"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/types"
+	"go.uber.org/zap"
+)
+
+// kbPool is a compiled knowledge base, with a pool of instances ready to be evaluated.
+// A knowledge base instance keeps its working memory while rules are matched,
+// so each instance is used by only one evaluation at a time.
+// The pool is immutable, when its rules change a new pool replaces it and the
+// instances of the old one are dropped.
+type kbPool struct {
+	lib     *ast.KnowledgeLibrary
+	name    string
+	version string
+	pool    chan *ast.KnowledgeBase
+}
+
+func newKBPool(name, version string) *kbPool {
+	p := &kbPool{
+		lib:     ast.NewKnowledgeLibrary(),
+		name:    name,
+		version: version,
+		pool:    make(chan *ast.KnowledgeBase, runtime.GOMAXPROCS(0)),
+	}
+	// make sure the blueprint exists even without rules
+	p.lib.GetKnowledgeBase(name, version)
+	return p
+}
+
+func (p *kbPool) add(grl []byte) error {
+	return builder.NewRuleBuilder(p.lib).BuildRuleFromResource(p.name, p.version, pkg.NewBytesResource(grl))
+}
+
+// get takes an instance from the pool, or creates a new one if the pool is empty.
+func (p *kbPool) get() *ast.KnowledgeBase {
+	select {
+	case kb := <-p.pool:
+		return kb
+	default:
+		return p.lib.NewKnowledgeBaseInstance(p.name, p.version)
+	}
+}
+
+// put returns an instance to the pool, it is dropped if the pool is full.
+func (p *kbPool) put(kb *ast.KnowledgeBase) {
+	select {
+	case p.pool <- kb:
+	default:
+	}
+}
+
+// match returns the rules matching the data context, evaluated by an instance of the pool.
+// A panic of a function called by a condition is returned as an error, and the instance is dropped.
+func (p *kbPool) match(eg *engine.GruleEngine, dataCtx ast.IDataContext) (entries []*ast.RuleEntry, err error) {
+	kb := p.get()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to evaluate %s: %v", p.name, r)
+			return
+		}
+		p.put(kb)
+	}()
+	return eg.FetchMatchingRules(dataCtx, kb)
+}
+
+// setKey identifies the rules of one address for one event type.
+type setKey struct {
+	address types.Address
+	event   types.EventType
+}
+
+// ruleSet holds the compiled rules of one address for one event type.
+type ruleSet struct {
+	*kbPool
+	key    setKey
+	rules  map[string]model.Rule
+	static map[string]model.StaticRule
+}
+
+// newRuleSet compiles the rules of the users and the static rules, keyed by their name in GRL.
+func newRuleSet(key setKey, rules []model.Rule, static map[string]model.StaticRule) (*ruleSet, error) {
+	s := &ruleSet{
+		kbPool: newKBPool(key.address.Hex(), string(key.event)),
+		key:    key,
+		rules:  make(map[string]model.Rule, len(rules)),
+		static: static,
+	}
+	for _, r := range rules {
+		if r.Paused {
+			continue
+		}
+		// the rules stored before a condition was rejected are skipped, instead of failing all the events
+		if err := r.Validate(); err != nil {
+			zap.S().Warnf("skipped rule %d: %s", r.ID, err)
+			continue
+		}
+		grl, err := r.BuildGRL()
+		if err != nil {
+			return nil, err
+		}
+		if err := s.add(grl); err != nil {
+			return nil, err
+		}
+		s.rules[r.Name()] = r
+	}
+	for name, r := range static {
+		grl, err := model.BuildGRL(name, r.Salience, r.Condition)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.add(grl); err != nil {
+			return nil, fmt.Errorf("static rule %s: %w", r.Name, err)
+		}
+	}
+	return s, nil
+}
+
+// Check compiles the condition of a rule without loading it.
+func Check(r *model.Rule) error {
+	grl, err := r.BuildGRL()
+	if err != nil {
+		return err
+	}
+	if err := newKBPool("check", string(r.Event)).add(grl); err != nil {
+		return fmt.Errorf("invalid condition: %w", err)
+	}
+	return nil
+}
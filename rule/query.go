@@ -0,0 +1,111 @@
+package rule
+
+import (
+	"context"
+	"time"
+
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
+	"github.com/strahe/suialert/types"
+	"go.uber.org/zap"
+)
+
+// QueryRunner runs the query rules when they are due,
+// they fire when the result of their query meets their threshold.
+type QueryRunner struct {
+	actor
+
+	qsv     *service.QueryService
+	timeout time.Duration
+	maxRows int
+
+	done chan struct{}
+}
+
+// NewQueryRunner creates a query runner, alerts are only logged if notifier is nil.
+// Matches are recorded with msv, if not nil.
+func NewQueryRunner(qsv *service.QueryService, msv *service.MatchService, notifier Notifier, timeout time.Duration, maxRows int) *QueryRunner {
+	if notifier == nil {
+		notifier = LogNotifier{}
+	}
+	return &QueryRunner{
+		actor: actor{
+			notifier: notifier,
+			matches:  msv,
+		},
+		qsv:     qsv,
+		timeout: timeout,
+		maxRows: maxRows,
+		done:    make(chan struct{}),
+	}
+}
+
+func (q *QueryRunner) Start(context.Context) error {
+	go q.loop()
+	return nil
+}
+
+func (q *QueryRunner) Close(context.Context) error {
+	close(q.done)
+	return nil
+}
+
+func (q *QueryRunner) loop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.done:
+			return
+		case <-ticker.C:
+			if err := q.RunDue(context.Background()); err != nil {
+				zap.S().Errorf("failed to run queries: %s", err)
+			}
+		}
+	}
+}
+
+// RunDue runs the queries whose interval passed since they last ran.
+// A failed query is logged and retried at its next interval.
+func (q *QueryRunner) RunDue(ctx context.Context) error {
+	rules, err := q.qsv.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for i := range rules {
+		r := &rules[i]
+		if !r.Due(now) {
+			continue
+		}
+		if err := q.Run(ctx, r, now); err != nil {
+			zap.S().Errorf("failed to run query %d: %s", r.ID, err)
+		}
+		if err := q.qsv.MarkRun(ctx, r, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run executes a query rule and fires it if its result meets the threshold.
+func (q *QueryRunner) Run(ctx context.Context, r *model.QueryRule, now time.Time) error {
+	res, err := q.qsv.Run(ctx, r, now, q.timeout, q.maxRows)
+	if err != nil {
+		return err
+	}
+	if !r.Op.Compare(res.Value, r.Threshold) {
+		return nil
+	}
+	alert := &model.Alert{
+		QueryID:   r.ID,
+		UserID:    r.UserID,
+		Event:     types.EventTypeQuery,
+		Timestamp: uint64(now.UnixMilli()),
+		Data:      res,
+		CreatedAt: now,
+	}
+	q.run(ctx, r.GetActions(), alert, false, false)
+	return nil
+}
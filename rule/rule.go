@@ -2,77 +2,342 @@ package rule
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/hyperjumptech/grule-rule-engine/ast"
-	"github.com/hyperjumptech/grule-rule-engine/builder"
 	"github.com/hyperjumptech/grule-rule-engine/engine"
-	"github.com/hyperjumptech/grule-rule-engine/pkg"
 	"github.com/samber/lo"
+	"github.com/strahe/suialert/model"
 	"github.com/strahe/suialert/service"
 	"github.com/strahe/suialert/types"
 	"go.uber.org/zap"
 )
 
+// Notifier delivers the alerts produced by rule actions.
+type Notifier interface {
+	Notify(ctx context.Context, alert *model.Alert) error
+}
+
+var (
+	// ErrSuppressed is returned by notifiers which deliberately did not deliver an alert.
+	ErrSuppressed = errors.New("alert suppressed")
+	// ErrHeld is returned by notifiers which will deliver an alert later.
+	ErrHeld = errors.New("alert held")
+	// ErrQueued is returned by notifiers which stored an alert to deliver it, the outcome is recorded on its match.
+	ErrQueued = errors.New("alert queued")
+)
+
+// reloadInterval is how often the rules changed by other processes sharing the database, such as the CLI, are reloaded.
+const reloadInterval = 10 * time.Second
+
+// Result is the outcome of running an event through the engine.
+type Result struct {
+	// Matched rules, ordered by salience
+	Matched []model.Rule
+	// Tags to add to the stored event
+	Tags []string
+}
+
 type Engine struct {
-	eg  *engine.GruleEngine
-	lib *ast.KnowledgeLibrary
+	eg *engine.GruleEngine
+	actor
 
 	rsv *service.RuleService
+
+	lk     sync.RWMutex
+	sets   map[setKey]*ruleSet
+	static map[setKey]map[string]model.StaticRule
+	// last change of the rules loaded
+	revision uint
+
+	done chan struct{}
 }
 
-func NewEngine(rsv *service.RuleService) (*Engine, error) {
-	e := Engine{
-		eg:  engine.NewGruleEngine(),
-		lib: ast.NewKnowledgeLibrary(),
+// NewEngine creates a rule engine, alerts are only logged if notifier is nil.
+// Matches are recorded with msv, if not nil.
+func NewEngine(rsv *service.RuleService, msv *service.MatchService, notifier Notifier) (*Engine, error) {
+	if notifier == nil {
+		notifier = LogNotifier{}
+	}
+	e := &Engine{
+		eg: engine.NewGruleEngine(),
+		actor: actor{
+			notifier: notifier,
+			matches:  msv,
+		},
+
+		rsv:    rsv,
+		sets:   map[setKey]*ruleSet{},
+		static: map[setKey]map[string]model.StaticRule{},
+		done:   make(chan struct{}),
+	}
+	rsv.Subscribe(func(r *model.Rule) {
+		if err := e.ReloadRules(context.Background(), r.Address, r.Event); err != nil {
+			zap.S().Errorf("failed to reload rules of %s for %s: %s", r.Address, r.Event, err)
+		}
+	})
+	return e, nil
+}
 
-		rsv: rsv,
+// Start loads the rules, and keeps reloading the ones changed by other processes.
+func (e *Engine) Start(ctx context.Context) error {
+	if err := e.LoadRules(ctx); err != nil {
+		return err
 	}
-	return &e, nil
+	go e.loop()
+	return nil
+}
+
+func (e *Engine) Close(context.Context) error {
+	close(e.done)
+	return nil
 }
 
+func (e *Engine) loop() {
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+			if err := e.Refresh(context.Background()); err != nil {
+				zap.S().Errorf("failed to reload changed rules: %s", err)
+			}
+		}
+	}
+}
+
+// LoadRules compiles all the rules, replacing the ones already loaded.
 func (e *Engine) LoadRules(ctx context.Context) error {
-	rules, err := e.rsv.FindAll(ctx)
+	e.lk.RLock()
+	static := e.static
+	e.lk.RUnlock()
+
+	// the changes made while building are reloaded by the next refresh
+	rev, err := e.rsv.Revision(ctx)
+	if err != nil {
+		return err
+	}
+	sets, err := e.buildSets(ctx, static)
 	if err != nil {
 		return err
 	}
-	rb := builder.NewRuleBuilder(e.lib)
+	e.lk.Lock()
+	e.sets = sets
+	e.revision = rev
+	e.lk.Unlock()
+	return nil
+}
 
-	for _, rule := range rules {
-		kb := e.lib.NewKnowledgeBaseInstance(rule.Address.Hex(), "")
-		if kb == nil {
-			gpl, err := rule.BuildGRL()
-			if err != nil {
-				return err
-			}
-			if err := rb.BuildRuleFromResource(rule.Address.Hex(), "", pkg.NewBytesResource(gpl)); err != nil {
-				return err
-			}
+// Refresh reloads the sets of the rules changed since they were loaded, the history of the rules
+// records the changes of every process, so that the changes of the CLI reach a running node.
+func (e *Engine) Refresh(ctx context.Context) error {
+	e.lk.RLock()
+	rev := e.revision
+	e.lk.RUnlock()
+
+	changes, err := e.rsv.ChangesSince(ctx, rev)
+	if err != nil || len(changes) == 0 {
+		return err
+	}
+	keys := map[setKey]bool{}
+	for _, h := range changes {
+		keys[setKey{address: h.Snapshot.Address, event: h.Snapshot.Event}] = true
+	}
+	for key := range keys {
+		if err := e.ReloadRules(ctx, key.address, key.event); err != nil {
+			return err
 		}
 	}
+	e.lk.Lock()
+	if last := changes[len(changes)-1].ID; last > e.revision {
+		e.revision = last
+	}
+	e.lk.Unlock()
 	return nil
 }
 
-func (e *Engine) ExecuteCoinBalanceChange(_ context.Context, data *types.CoinBalanceChange) error {
-	dataCtx := ast.NewDataContext()
-	err := dataCtx.Add("Event", data)
+// SetStaticRules replaces the static rules, the rules are kept if any of them is invalid.
+func (e *Engine) SetStaticRules(ctx context.Context, rules []model.StaticRule) error {
+	static := map[setKey]map[string]model.StaticRule{}
+	names := map[string]bool{}
+	for i, r := range rules {
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("static rule %q: %w", r.Name, err)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("duplicate static rule %q", r.Name)
+		}
+		names[r.Name] = true
+		if r.Salience == 0 {
+			r.Salience = 10
+		}
+		key := setKey{address: types.HexToAddress(r.Address), event: r.Event}
+		if static[key] == nil {
+			static[key] = map[string]model.StaticRule{}
+		}
+		static[key][fmt.Sprintf("Static%d", i)] = r
+	}
+
+	sets, err := e.buildSets(ctx, static)
 	if err != nil {
 		return err
 	}
-	owner, ok := lo.Coalesce[*types.Address](data.Owner.ObjectOwner, data.Owner.AddressOwner, data.Owner.SingleOwner)
-	if !ok {
-		// todo: handle this case
-		return fmt.Errorf("invalid owner")
+	e.lk.Lock()
+	e.sets = sets
+	e.static = static
+	e.lk.Unlock()
+	return nil
+}
+
+func (e *Engine) buildSets(ctx context.Context, static map[setKey]map[string]model.StaticRule) (map[setKey]*ruleSet, error) {
+	rules, err := e.rsv.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	grouped := lo.GroupBy(rules, func(r model.Rule) setKey {
+		return setKey{address: r.Address, event: r.Event}
+	})
+	for key := range static {
+		if _, ok := grouped[key]; !ok {
+			grouped[key] = nil
+		}
 	}
-	knowledgeBase := e.lib.NewKnowledgeBaseInstance(owner.Hex(), "")
-	if knowledgeBase == nil {
-		zap.S().Debugf("no rules matchd for owner: %s", owner.Hex())
+	sets := make(map[setKey]*ruleSet, len(grouped))
+	for key, rs := range grouped {
+		set, err := newRuleSet(key, rs, static[key])
+		if err != nil {
+			return nil, err
+		}
+		sets[key] = set
+	}
+	return sets, nil
+}
+
+// ReloadRules compiles the rules of an address for one event type again,
+// the cached instances of the other sets are kept.
+func (e *Engine) ReloadRules(ctx context.Context, addr types.Address, event types.EventType) error {
+	rules, err := e.rsv.FindByAddressAndEvent(ctx, addr, event)
+	if err != nil {
+		return err
+	}
+	key := setKey{address: addr, event: event}
+	e.lk.RLock()
+	static := e.static[key]
+	e.lk.RUnlock()
+	if len(rules) == 0 && len(static) == 0 {
+		e.lk.Lock()
+		delete(e.sets, key)
+		e.lk.Unlock()
 		return nil
 	}
-	rules, err := e.eg.FetchMatchingRules(dataCtx, knowledgeBase)
+	set, err := newRuleSet(key, rules, static)
 	if err != nil {
 		return err
 	}
-	fmt.Println("match rules", rules)
+	e.lk.Lock()
+	e.sets[key] = set
+	e.lk.Unlock()
 	return nil
 }
+
+func (e *Engine) ruleSet(addr types.Address, event types.EventType) *ruleSet {
+	e.lk.RLock()
+	defer e.lk.RUnlock()
+	return e.sets[setKey{address: addr, event: event}]
+}
+
+func (e *Engine) ExecuteCoinBalanceChange(ctx context.Context, er *types.EventResult, data *types.CoinBalanceChange) (*Result, error) {
+	owner, ok := lo.Coalesce[*types.Address](data.Owner.ObjectOwner, data.Owner.AddressOwner, data.Owner.SingleOwner)
+	if !ok {
+		// todo: handle this case
+		return nil, fmt.Errorf("invalid owner")
+	}
+	return e.execute(ctx, er, *owner, types.EventTypeCoinBalanceChange, data)
+}
+
+func (e *Engine) execute(ctx context.Context, er *types.EventResult, addr types.Address, event types.EventType, data interface{}) (*Result, error) {
+	set := e.ruleSet(addr, event)
+	if set == nil {
+		zap.S().Debugf("no rules matchd for owner: %s", addr.Hex())
+		return &Result{}, nil
+	}
+	dataCtx := ast.NewDataContext()
+	if err := dataCtx.Add("Event", data); err != nil {
+		return nil, err
+	}
+	if err := dataCtx.Add(helperName, newHelper(data)); err != nil {
+		return nil, err
+	}
+
+	entries, err := set.match(e.eg, dataCtx)
+	if err != nil {
+		return nil, err
+	}
+	return e.runActions(ctx, er, set, event, data, entries), nil
+}
+
+// runActions executes the actions of the matched rules in salience order,
+// until a rule asks to stop.
+func (e *Engine) runActions(ctx context.Context, er *types.EventResult, set *ruleSet, event types.EventType, data interface{}, entries []*ast.RuleEntry) *Result {
+	res := &Result{}
+	now := time.Now()
+	for _, entry := range entries {
+		if sr, ok := set.static[entry.RuleName]; ok {
+			alert := newAlert(er, event, data)
+			alert.StaticRule = sr.Name
+			alert.Address = set.key.address
+			alert.Severity = sr.Severity
+			alert.Escalation = sr.Escalation
+			tags, stop := e.run(ctx, sr.GetActions(), alert, false, false)
+			res.Tags = lo.Uniq(append(res.Tags, tags...))
+			if stop {
+				break
+			}
+			continue
+		}
+		r, ok := set.rules[entry.RuleName]
+		if !ok {
+			zap.S().Warnf("matched unknown rule: %s", entry.RuleName)
+			continue
+		}
+		if !r.Active(now) {
+			continue
+		}
+		res.Matched = append(res.Matched, r)
+
+		alert := newAlert(er, event, data)
+		alert.RuleID = r.ID
+		alert.UserID = r.UserID
+		alert.Address = r.Address
+		alert.Severity = r.Severity
+		alert.Escalation = r.Escalation
+		alert.Destination = r.Destination
+		alert.Digest = r.Digest
+		tags, stop := e.run(ctx, r.GetActions(), alert, r.Shadow, r.Muted(now))
+		res.Tags = lo.Uniq(append(res.Tags, tags...))
+		if stop {
+			break
+		}
+	}
+	return res
+}
+
+func newAlert(er *types.EventResult, event types.EventType, data interface{}) *model.Alert {
+	alert := &model.Alert{
+		Event:     event,
+		Data:      data,
+		CreatedAt: time.Now(),
+	}
+	if er != nil {
+		alert.TxDigest = er.Id.TxDigest
+		alert.EventSeq = er.Id.EventSeq
+		alert.Timestamp = er.Timestamp
+	}
+	return alert
+}
@@ -0,0 +1,72 @@
+package rule_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/rule"
+	"github.com/strahe/suialert/service"
+	"github.com/strahe/suialert/types"
+)
+
+func TestEngineRefresh(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	if err := db.Create(&model.User{ID: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	owner := types.HexToAddress("0xfd9a15b8a0d7ebab0adb0c69f1c03b05cc2a5a32")
+	eng, err := rule.NewEngine(service.NewRuleService(db), nil, &recorder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := eng.LoadRules(ctx); err != nil {
+		t.Fatal(err)
+	}
+	matched := func() int {
+		t.Helper()
+		data := &types.CoinBalanceChange{
+			ChangeType: "Pay",
+			Owner:      &types.ObjectOwner{ObjectOwnerInternal: &types.ObjectOwnerInternal{AddressOwner: &owner}},
+			Amount:     types.BigIntFromInt64(1),
+		}
+		res, err := eng.ExecuteCoinBalanceChange(ctx, &types.EventResult{}, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(res.Matched)
+	}
+
+	// another process, e.g. the CLI, changes the rules without notifying the engine
+	other := service.NewRuleService(db)
+	r := &model.Rule{
+		UserID:    1,
+		Address:   owner,
+		Event:     types.EventTypeCoinBalanceChange,
+		Condition: `Event.ChangeType == "Pay"`,
+		Actions:   []model.Action{{Type: model.ActionNotify}},
+	}
+	if err := other.Create(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+	if n := matched(); n != 0 {
+		t.Fatalf("%d rules matched before the refresh", n)
+	}
+	if err := eng.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := matched(); n != 1 {
+		t.Fatalf("%d rules matched after the creation, want 1", n)
+	}
+
+	if err := other.Pause(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+	if err := eng.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := matched(); n != 0 {
+		t.Fatalf("%d rules matched after the pause", n)
+	}
+}
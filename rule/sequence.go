@@ -0,0 +1,404 @@
+package rule
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperjumptech/grule-rule-engine/ast"
+	"github.com/hyperjumptech/grule-rule-engine/engine"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
+	"github.com/strahe/suialert/types"
+	"go.uber.org/zap"
+)
+
+const checkpointInterval = 30 * time.Second
+
+// stepRef points to a step of a sequence rule.
+type stepRef struct {
+	sequence uint
+	index    int
+}
+
+// stepSet holds the compiled step conditions of all the sequences for one event type.
+type stepSet struct {
+	*kbPool
+	steps map[string]stepRef
+}
+
+type partialKey struct {
+	sequence uint
+	key      string
+}
+
+// partial is a sequence matched up to, but not including, step.
+type partial struct {
+	step      int
+	txDigests []string
+	startedAt time.Time
+	expiresAt time.Time
+}
+
+// Correlator matches sequence rules: ordered events sharing an object, a sender
+// or a transaction, within a time window.
+// Partial matches are kept in memory and checkpointed to the database. Every event matching
+// the first step starts a partial match, a key keeps the latest one reaching each step
+// since it advances like the older ones and expires last.
+type Correlator struct {
+	eg *engine.GruleEngine
+	actor
+
+	ssv *service.SequenceService
+
+	lk        sync.RWMutex
+	sequences map[uint]model.SequenceRule
+	sets      map[types.EventType]*stepSet
+	revision  string
+
+	plk      sync.Mutex
+	partials map[partialKey][]*partial
+
+	done chan struct{}
+}
+
+// NewCorrelator creates a correlator, alerts are only logged if notifier is nil.
+// Matches are recorded with msv, if not nil.
+func NewCorrelator(ssv *service.SequenceService, msv *service.MatchService, notifier Notifier) *Correlator {
+	if notifier == nil {
+		notifier = LogNotifier{}
+	}
+	c := &Correlator{
+		eg: engine.NewGruleEngine(),
+		actor: actor{
+			notifier: notifier,
+			matches:  msv,
+		},
+		ssv:       ssv,
+		sequences: map[uint]model.SequenceRule{},
+		sets:      map[types.EventType]*stepSet{},
+		partials:  map[partialKey][]*partial{},
+		done:      make(chan struct{}),
+	}
+	ssv.Subscribe(func(r *model.SequenceRule) {
+		if err := c.LoadSequences(context.Background()); err != nil {
+			zap.S().Errorf("failed to reload sequences after change of %d: %s", r.ID, err)
+		}
+	})
+	return c
+}
+
+// Start loads the sequences and their checkpointed partial matches.
+func (c *Correlator) Start(ctx context.Context) error {
+	if err := c.LoadSequences(ctx); err != nil {
+		return err
+	}
+	states, err := c.ssv.LoadStates(ctx)
+	if err != nil {
+		return err
+	}
+	c.plk.Lock()
+	for _, st := range states {
+		pk := partialKey{sequence: st.SequenceID, key: st.Key}
+		c.partials[pk] = append(c.partials[pk], &partial{
+			step:      st.Step,
+			txDigests: st.TxDigests,
+			startedAt: st.StartedAt,
+			expiresAt: st.ExpiresAt,
+		})
+	}
+	c.plk.Unlock()
+
+	go c.loop()
+	return nil
+}
+
+func (c *Correlator) Close(ctx context.Context) error {
+	close(c.done)
+	return c.checkpoint(ctx)
+}
+
+// LoadSequences compiles the steps of all the sequences, replacing the ones already loaded.
+func (c *Correlator) LoadSequences(ctx context.Context) error {
+	rev, err := c.ssv.Revision(ctx)
+	if err != nil {
+		return err
+	}
+	rules, err := c.ssv.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	sequences := make(map[uint]model.SequenceRule, len(rules))
+	sets := map[types.EventType]*stepSet{}
+	for _, r := range rules {
+		if r.Paused {
+			continue
+		}
+		if err := r.Validate(); err != nil {
+			zap.S().Warnf("skipped sequence %d: %s", r.ID, err)
+			continue
+		}
+		for i, st := range r.Steps {
+			set, ok := sets[st.Event]
+			if !ok {
+				set = &stepSet{kbPool: newKBPool("sequences", string(st.Event)), steps: map[string]stepRef{}}
+				sets[st.Event] = set
+			}
+			grl, err := r.BuildStepGRL(i)
+			if err != nil {
+				return err
+			}
+			if err := set.add(grl); err != nil {
+				return err
+			}
+			set.steps[r.StepName(i)] = stepRef{sequence: r.ID, index: i}
+		}
+		sequences[r.ID] = r
+	}
+
+	c.lk.Lock()
+	c.sequences = sequences
+	c.sets = sets
+	c.revision = rev
+	c.lk.Unlock()
+
+	// forget the partial matches of removed sequences
+	c.plk.Lock()
+	for k := range c.partials {
+		if _, ok := sequences[k.sequence]; !ok {
+			delete(c.partials, k)
+		}
+	}
+	c.plk.Unlock()
+	return nil
+}
+
+// Refresh reloads the sequences if they changed since they were loaded, also by another process.
+func (c *Correlator) Refresh(ctx context.Context) error {
+	rev, err := c.ssv.Revision(ctx)
+	if err != nil {
+		return err
+	}
+	c.lk.RLock()
+	stale := rev != c.revision
+	c.lk.RUnlock()
+	if !stale {
+		return nil
+	}
+	return c.LoadSequences(ctx)
+}
+
+// Observe advances the sequences with an event, and executes the actions of the completed ones.
+func (c *Correlator) Observe(ctx context.Context, er *types.EventResult, event types.EventType, data interface{}) error {
+	c.lk.RLock()
+	set := c.sets[event]
+	sequences := c.sequences
+	c.lk.RUnlock()
+	if set == nil {
+		return nil
+	}
+
+	dataCtx := ast.NewDataContext()
+	if err := dataCtx.Add("Event", data); err != nil {
+		return err
+	}
+	if err := dataCtx.Add(helperName, newHelper(data)); err != nil {
+		return err
+	}
+	entries, err := set.match(c.eg, dataCtx)
+	if err != nil {
+		return err
+	}
+
+	matched := map[uint]map[int]bool{}
+	for _, entry := range entries {
+		ref, ok := set.steps[entry.RuleName]
+		if !ok {
+			continue
+		}
+		if matched[ref.sequence] == nil {
+			matched[ref.sequence] = map[int]bool{}
+		}
+		matched[ref.sequence][ref.index] = true
+	}
+
+	txDigest := ""
+	if er != nil {
+		txDigest = er.Id.TxDigest
+	}
+	now := time.Now()
+	var completed []model.SequenceRule
+
+	c.plk.Lock()
+	for id, steps := range matched {
+		seq, ok := sequences[id]
+		if !ok {
+			continue
+		}
+		key := correlationKey(seq.CorrelateBy, er, data)
+		if key == "" {
+			continue
+		}
+		pk := partialKey{sequence: id, key: key}
+		var (
+			next []*partial
+			done bool
+		)
+		for _, p := range c.partials[pk] {
+			if now.After(p.expiresAt) {
+				continue
+			}
+			if steps[p.step] {
+				p.step++
+				p.txDigests = append(p.txDigests, txDigest)
+				if p.step == len(seq.Steps) {
+					done = true
+					continue
+				}
+			}
+			next = append(next, p)
+		}
+		if done {
+			// the sequence completes once for an event, however many partial matches it completes
+			completed = append(completed, seq)
+		}
+		if steps[0] {
+			next = append(next, &partial{
+				step:      1,
+				txDigests: []string{txDigest},
+				startedAt: now,
+				expiresAt: now.Add(seq.Window),
+			})
+		}
+		if next = latestPerStep(next); len(next) > 0 {
+			c.partials[pk] = next
+		} else {
+			delete(c.partials, pk)
+		}
+	}
+	c.plk.Unlock()
+
+	for _, seq := range completed {
+		alert := newAlert(er, event, data)
+		alert.SequenceID = seq.ID
+		alert.UserID = seq.UserID
+		if sender, _ := eventFields(data); sender != "" {
+			alert.Address = types.HexToAddress(sender)
+		}
+		c.run(ctx, seq.GetActions(), alert, false, false)
+	}
+	return nil
+}
+
+func (c *Correlator) loop() {
+	ticker := time.NewTicker(checkpointInterval)
+	defer ticker.Stop()
+	reload := time.NewTicker(reloadInterval)
+	defer reload.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.checkpoint(context.Background()); err != nil {
+				zap.S().Errorf("failed to checkpoint sequences: %s", err)
+			}
+		case <-reload.C:
+			if err := c.Refresh(context.Background()); err != nil {
+				zap.S().Errorf("failed to reload changed sequences: %s", err)
+			}
+		}
+	}
+}
+
+// checkpoint drops the expired partial matches and saves the others.
+func (c *Correlator) checkpoint(ctx context.Context) error {
+	now := time.Now()
+	var states []model.SequenceState
+
+	c.plk.Lock()
+	for k, ps := range c.partials {
+		live := ps[:0]
+		for _, p := range ps {
+			if now.After(p.expiresAt) {
+				continue
+			}
+			live = append(live, p)
+			states = append(states, model.SequenceState{
+				SequenceID: k.sequence,
+				Key:        k.key,
+				Step:       p.step,
+				TxDigests:  append([]string(nil), p.txDigests...),
+				StartedAt:  p.startedAt,
+				ExpiresAt:  p.expiresAt,
+			})
+		}
+		if len(live) > 0 {
+			c.partials[k] = live
+		} else {
+			delete(c.partials, k)
+		}
+	}
+	c.plk.Unlock()
+
+	return c.ssv.SaveStates(ctx, states)
+}
+
+// latestPerStep keeps the partial match started last of each step.
+func latestPerStep(ps []*partial) []*partial {
+	latest := make(map[int]*partial, len(ps))
+	for _, p := range ps {
+		if q, ok := latest[p.step]; !ok || p.startedAt.After(q.startedAt) {
+			latest[p.step] = p
+		}
+	}
+	kept := ps[:0]
+	for _, p := range ps {
+		if latest[p.step] == p {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+func correlationKey(by model.CorrelateBy, er *types.EventResult, data interface{}) string {
+	sender, object := eventFields(data)
+	switch by {
+	case model.CorrelateBySender:
+		if sender == "" {
+			return ""
+		}
+		return types.HexToAddress(sender).Hex()
+	case model.CorrelateByObject:
+		return strings.ToLower(object)
+	case model.CorrelateByTxDigest:
+		if er == nil {
+			return ""
+		}
+		return er.Id.TxDigest
+	}
+	return ""
+}
+
+// eventFields returns the sender of an event and the object it is about.
+func eventFields(data interface{}) (sender string, object string) {
+	switch d := data.(type) {
+	case *types.CoinBalanceChange:
+		return d.Sender, d.CoinObjectId
+	case *types.NewObject:
+		return d.Sender, d.ObjectID
+	case *types.TransferObject:
+		return d.Sender, d.ObjectID
+	case *types.MutateObject:
+		return d.Sender, d.ObjectID
+	case *types.DeleteObject:
+		return d.Sender, d.ObjectID
+	case *types.Publish:
+		return d.Sender, d.PackageID
+	case *types.MoveEvent:
+		return d.Sender, ""
+	}
+	return "", ""
+}
@@ -0,0 +1,159 @@
+package rule_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/rule"
+	"github.com/strahe/suialert/service"
+	"github.com/strahe/suialert/types"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newDB(t *testing.T) *gorm.DB {
+	name := fmt.Sprintf("file:%s-%d?mode=memory&cache=shared", t.Name(), time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(name), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := model.Migration(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// recorder keeps the alerts, the correlator notifies synchronously.
+type recorder struct {
+	alerts []*model.Alert
+}
+
+func (r *recorder) Notify(_ context.Context, alert *model.Alert) error {
+	r.alerts = append(r.alerts, alert)
+	return nil
+}
+
+// newCorrelator starts a correlator matching a NewObject then a TransferObject of the same object.
+func newCorrelator(t *testing.T, window time.Duration) (*rule.Correlator, *recorder) {
+	ctx := context.Background()
+	db := newDB(t)
+	if err := db.Create(&model.User{ID: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	ssv := service.NewSequenceService(db)
+	seq := &model.SequenceRule{
+		UserID:      1,
+		Steps:       []model.SequenceStep{{Event: types.EventTypeNewObject}, {Event: types.EventTypeTransferObject}},
+		CorrelateBy: model.CorrelateByObject,
+		Window:      window,
+	}
+	if err := ssv.Create(seq); err != nil {
+		t.Fatal(err)
+	}
+	rec := &recorder{}
+	c := rule.NewCorrelator(ssv, service.NewMatchService(db), rec)
+	if err := c.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close(ctx) })
+	return c, rec
+}
+
+func observe(t *testing.T, c *rule.Correlator, tx string, event types.EventType, object string) {
+	t.Helper()
+	er := &types.EventResult{Id: types.EventID{TxDigest: tx}}
+	var data interface{}
+	switch event {
+	case types.EventTypeNewObject:
+		data = &types.NewObject{ObjectID: object}
+	case types.EventTypeTransferObject:
+		data = &types.TransferObject{ObjectID: object}
+	}
+	if err := c.Observe(context.Background(), er, event, data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCorrelatorOrder(t *testing.T) {
+	c, rec := newCorrelator(t, time.Minute)
+
+	// the steps must happen in order
+	observe(t, c, "tx1", types.EventTypeTransferObject, "0xa")
+	observe(t, c, "tx2", types.EventTypeNewObject, "0xa")
+	// and share the object
+	observe(t, c, "tx3", types.EventTypeTransferObject, "0xb")
+	if len(rec.alerts) != 0 {
+		t.Fatalf("%d alerts before the sequence completed", len(rec.alerts))
+	}
+	observe(t, c, "tx4", types.EventTypeTransferObject, "0xa")
+	if len(rec.alerts) != 1 || rec.alerts[0].TxDigest != "tx4" || rec.alerts[0].SequenceID != 1 {
+		t.Fatalf("unexpected alerts %+v", rec.alerts)
+	}
+	// a completed sequence starts again from its first step
+	observe(t, c, "tx5", types.EventTypeTransferObject, "0xa")
+	if len(rec.alerts) != 1 {
+		t.Fatalf("%d alerts, the sequence completed again without its first step", len(rec.alerts))
+	}
+}
+
+func TestCorrelatorWindow(t *testing.T) {
+	const window = 200 * time.Millisecond
+	c, rec := newCorrelator(t, window)
+
+	// the last step must happen within the window of the first one
+	observe(t, c, "tx1", types.EventTypeNewObject, "0xa")
+	time.Sleep(window + 50*time.Millisecond)
+	observe(t, c, "tx2", types.EventTypeTransferObject, "0xa")
+	if len(rec.alerts) != 0 {
+		t.Fatalf("%d alerts after the window expired", len(rec.alerts))
+	}
+
+	// an expired start does not hide a later one
+	observe(t, c, "tx3", types.EventTypeNewObject, "0xa")
+	time.Sleep(window / 2)
+	observe(t, c, "tx4", types.EventTypeNewObject, "0xa")
+	time.Sleep(window/2 + 50*time.Millisecond)
+	observe(t, c, "tx5", types.EventTypeTransferObject, "0xa")
+	if len(rec.alerts) != 1 || rec.alerts[0].TxDigest != "tx5" {
+		t.Fatalf("unexpected alerts %+v", rec.alerts)
+	}
+}
+
+func TestCorrelatorRefresh(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	if err := db.Create(&model.User{ID: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	rec := &recorder{}
+	c := rule.NewCorrelator(service.NewSequenceService(db), service.NewMatchService(db), rec)
+	if err := c.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close(ctx) })
+
+	// another process, e.g. the CLI, adds a sequence without notifying the correlator
+	err := service.NewSequenceService(db).Create(&model.SequenceRule{
+		UserID:      1,
+		Steps:       []model.SequenceStep{{Event: types.EventTypeNewObject}, {Event: types.EventTypeTransferObject}},
+		CorrelateBy: model.CorrelateByObject,
+		Window:      time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	observe(t, c, "tx1", types.EventTypeNewObject, "0xa")
+	observe(t, c, "tx2", types.EventTypeTransferObject, "0xa")
+	if len(rec.alerts) != 1 {
+		t.Fatalf("%d alerts, want 1 from the sequence added by another process", len(rec.alerts))
+	}
+}
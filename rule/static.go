@@ -0,0 +1,198 @@
+package rule
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/strahe/suialert/config"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/types"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// changes of the rule files are applied once they settled
+const reloadDelay = time.Second
+
+// StaticLoader loads the static rules of the config and of the rule files into the engine,
+// and reloads them when the files change.
+type StaticLoader struct {
+	cfg    config.RulesConfig
+	static []model.StaticRule
+	eng    *Engine
+
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+func NewStaticLoader(cfg config.RulesConfig, eng *Engine) *StaticLoader {
+	static := make([]model.StaticRule, len(cfg.Static))
+	for i, r := range cfg.Static {
+		static[i] = staticRule(r)
+	}
+	return &StaticLoader{
+		cfg:    cfg,
+		static: static,
+		eng:    eng,
+		done:   make(chan struct{}),
+	}
+}
+
+// staticRule converts a static rule of the config.
+func staticRule(c config.StaticRuleConfig) model.StaticRule {
+	r := model.StaticRule{
+		Name:      c.Name,
+		Address:   c.Address,
+		Event:     types.EventType(c.Event),
+		Condition: c.Condition,
+		Salience:  c.Salience,
+		Channel:   c.Channel,
+		Severity:  model.Severity(c.Severity),
+	}
+	if c.Escalation != nil {
+		r.Escalation = &model.Escalation{
+			After:   c.Escalation.After,
+			Channel: c.Escalation.Channel,
+			UserID:  c.Escalation.UserID,
+		}
+	}
+	for _, a := range c.Actions {
+		r.Actions = append(r.Actions, model.Action{
+			Type:     model.ActionType(a.Type),
+			Severity: model.Severity(a.Severity),
+			Tag:      a.Tag,
+			Webhook:  a.Webhook,
+			Channel:  a.Channel,
+		})
+	}
+	return r
+}
+
+func (l *StaticLoader) Start(ctx context.Context) error {
+	if err := l.Load(ctx); err != nil {
+		return err
+	}
+	if l.cfg.Dir == "" {
+		return nil
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := w.Add(l.cfg.Dir); err != nil {
+		w.Close() // nolint: errcheck
+		return fmt.Errorf("failed to watch %s: %w", l.cfg.Dir, err)
+	}
+	l.watcher = w
+	go l.watch()
+	return nil
+}
+
+func (l *StaticLoader) Close(context.Context) error {
+	close(l.done)
+	if l.watcher != nil {
+		return l.watcher.Close()
+	}
+	return nil
+}
+
+// Load reads the static rules and replaces the ones of the engine.
+func (l *StaticLoader) Load(ctx context.Context) error {
+	rules := append([]model.StaticRule(nil), l.static...)
+	if l.cfg.Dir != "" {
+		files, err := ruleFiles(l.cfg.Dir)
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			rs, err := readRuleFile(f)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", f, err)
+			}
+			rules = append(rules, rs...)
+		}
+	}
+	for i := range rules {
+		if rules[i].Channel == "" {
+			rules[i].Channel = l.cfg.Channel
+		}
+	}
+	if err := l.eng.SetStaticRules(ctx, rules); err != nil {
+		return err
+	}
+	zap.S().Infof("loaded %d static rules", len(rules))
+	return nil
+}
+
+func (l *StaticLoader) watch() {
+	timer := time.NewTimer(reloadDelay)
+	timer.Stop()
+	for {
+		select {
+		case <-l.done:
+			timer.Stop()
+			return
+		case ev, ok := <-l.watcher.Events:
+			if !ok {
+				return
+			}
+			if isRuleFile(ev.Name) {
+				timer.Reset(reloadDelay)
+			}
+		case err, ok := <-l.watcher.Errors:
+			if !ok {
+				return
+			}
+			zap.S().Errorf("failed to watch rule files: %s", err)
+		case <-timer.C:
+			// a broken file keeps the previous rules
+			if err := l.Load(context.Background()); err != nil {
+				zap.S().Errorf("failed to reload static rules: %s", err)
+			}
+		}
+	}
+}
+
+func isRuleFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+func ruleFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && isRuleFile(e.Name()) {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func readRuleFile(name string) ([]model.StaticRule, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var file model.StaticRuleFile
+	if strings.EqualFold(filepath.Ext(name), ".json") {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	return file.Rules, err
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/strahe/suialert/model"
+)
+
+var ErrForbidden = fmt.Errorf("forbidden")
+
+// AckService tracks the acknowledgements of the alerts with an escalation policy.
+type AckService struct {
+	db *gorm.DB
+}
+
+func NewAckService(db *gorm.DB) *AckService {
+	return &AckService{db: db}
+}
+
+// Create starts waiting for the acknowledgement of an alert, it sets the AckID of the alert.
+func (s *AckService) Create(ctx context.Context, alert *model.Alert, now time.Time) error {
+	if alert.Escalation == nil {
+		return fmt.Errorf("alert has no escalation policy")
+	}
+	ack := &model.AlertAck{
+		UserID: alert.UserID,
+		Alert:  *alert,
+		DueAt:  now.Add(alert.Escalation.After).UTC(),
+	}
+	if err := Conn(ctx, s.db).Create(ack).Error; err != nil {
+		return err
+	}
+	alert.AckID = ack.ID
+	return nil
+}
+
+// Acknowledge acknowledges an alert on behalf of a user, which must be its recipient
+// or the recipient of its escalation, unless it was sent to a channel.
+// An alert acknowledged already is returned unchanged.
+func (s *AckService) Acknowledge(ctx context.Context, id uint, userID uint) (*model.AlertAck, error) {
+	var ack model.AlertAck
+	err := s.db.WithContext(ctx).First(&ack, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !canAcknowledge(&ack.Alert, userID) {
+		return nil, ErrForbidden
+	}
+	if ack.AckedAt != nil {
+		return &ack, nil
+	}
+	now := time.Now()
+	ack.AckedAt = &now
+	ack.AckedBy = ActorFrom(ctx).String()
+	err = s.db.WithContext(ctx).Model(&ack).Select("AckedAt", "AckedBy").Updates(&ack).Error
+	return &ack, err
+}
+
+func canAcknowledge(alert *model.Alert, userID uint) bool {
+	if alert.Destination != "" || alert.Escalation.Channel != "" {
+		return true
+	}
+	return userID == alert.UserID || userID == alert.Escalation.UserID
+}
+
+// Due returns the alerts not acknowledged in time and not escalated yet.
+func (s *AckService) Due(ctx context.Context, now time.Time) ([]model.AlertAck, error) {
+	var acks []model.AlertAck
+	err := s.db.WithContext(ctx).
+		Where("acked_at IS NULL AND escalated_at IS NULL AND due_at <= ?", now.UTC()).
+		Order("id").Find(&acks).Error
+	return acks, err
+}
+
+// MarkEscalated records the escalation of an alert.
+func (s *AckService) MarkEscalated(ctx context.Context, ack *model.AlertAck, at time.Time) error {
+	ack.EscalatedAt = &at
+	return s.db.WithContext(ctx).Model(ack).Update("escalated_at", at).Error
+}
@@ -0,0 +1,22 @@
+package service
+
+import (
+	"context"
+
+	"github.com/strahe/suialert/model"
+)
+
+type actorKey struct{}
+
+// WithActor returns a context recording actor as the author of the changes made with it.
+func WithActor(ctx context.Context, actor model.Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor of the context, the system if there is none.
+func ActorFrom(ctx context.Context) model.Actor {
+	if a, ok := ctx.Value(actorKey{}).(model.Actor); ok {
+		return a
+	}
+	return model.Actor{Source: model.SourceSystem}
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/types"
+)
+
+type CheckService struct {
+	db *gorm.DB
+
+	lk       sync.RWMutex
+	onChange []func(rule *model.CheckRule)
+}
+
+func NewCheckService(db *gorm.DB) *CheckService {
+	return &CheckService{db: db}
+}
+
+func (s *CheckService) Create(r *model.CheckRule) error {
+	if r == nil {
+		return fmt.Errorf("check is nil")
+	}
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	if err := s.db.Create(r).Error; err != nil {
+		return err
+	}
+	s.changed(r)
+	return nil
+}
+
+func (s *CheckService) FindByID(id uint) (*model.CheckRule, error) {
+	var rule model.CheckRule
+	err := s.db.First(&rule, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return &rule, err
+}
+
+func (s *CheckService) Delete(r *model.CheckRule) error {
+	if r == nil {
+		return fmt.Errorf("check is nil")
+	}
+	if err := s.db.Delete(r).Error; err != nil {
+		return err
+	}
+	s.changed(r)
+	return nil
+}
+
+// FindAll returns all checks
+func (s *CheckService) FindAll(ctx context.Context) ([]model.CheckRule, error) {
+	var rules []model.CheckRule
+	err := s.db.WithContext(ctx).Order("id").Find(&rules).Error
+	return rules, err
+}
+
+// Revision changes when a check is created or deleted.
+func (s *CheckService) Revision(ctx context.Context) (string, error) {
+	return revision(ctx, s.db, &model.CheckRule{})
+}
+
+// MarkFired records the last time a check fired.
+func (s *CheckService) MarkFired(ctx context.Context, r *model.CheckRule, at time.Time) error {
+	r.LastFiredAt = &at
+	return s.db.WithContext(ctx).Model(r).Update("last_fired_at", at).Error
+}
+
+// Seen records the activities, keeping the latest of each address and kind.
+func (s *CheckService) Seen(ctx context.Context, activities []model.Activity) error {
+	if len(activities) == 0 {
+		return nil
+	}
+	return Conn(ctx, s.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "address"}, {Name: "kind"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_seen", "tx_digest"}),
+	}).Create(&activities).Error
+}
+
+// LastSeen returns the last activity of an address, nil if never seen.
+func (s *CheckService) LastSeen(ctx context.Context, addr types.Address, kind string) (*model.Activity, error) {
+	var acts []model.Activity
+	err := s.db.WithContext(ctx).Where("address = ? AND kind = ?", addr, kind).Limit(1).Find(&acts).Error
+	if err != nil || len(acts) == 0 {
+		return nil, err
+	}
+	return &acts[0], nil
+}
+
+var eventModels = map[types.EventType]model.Model{
+	types.EventTypeCoinBalanceChange: &model.CoinBalanceChangeEvent{},
+	types.EventTypeDeleteObject:      &model.DeleteObjectEvent{},
+	types.EventTypeMove:              &model.MoveEvent{},
+	types.EventTypeMutateObject:      &model.MutateObjectEvent{},
+	types.EventTypeNewObject:         &model.NewObjectEvent{},
+	types.EventTypePublish:           &model.PublishEvent{},
+	types.EventTypeTransferObject:    &model.TransferObjectEvent{},
+}
+
+// LastEventAt returns the time of the last stored event sent by an address,
+// of a type or of any type if event is empty, zero if there is none.
+func (s *CheckService) LastEventAt(ctx context.Context, addr types.Address, event types.EventType) (time.Time, error) {
+	var last uint64
+	for et, m := range eventModels {
+		if event != "" && et != event {
+			continue
+		}
+		var ts *uint64
+		err := s.db.WithContext(ctx).Model(m).Where("sender = ?", addr).
+			Select("MAX(timestamp)").Scan(&ts).Error
+		if err != nil {
+			return time.Time{}, err
+		}
+		if ts != nil && *ts > last {
+			last = *ts
+		}
+	}
+	if last == 0 {
+		return time.Time{}, nil
+	}
+	return time.UnixMilli(int64(last)), nil
+}
+
+// Subscribe registers fn to be called after a check was created or deleted.
+func (s *CheckService) Subscribe(fn func(rule *model.CheckRule)) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.onChange = append(s.onChange, fn)
+}
+
+func (s *CheckService) changed(rule *model.CheckRule) {
+	s.lk.RLock()
+	defer s.lk.RUnlock()
+	for _, fn := range s.onChange {
+		fn(rule)
+	}
+}
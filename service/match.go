@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/strahe/suialert/model"
+)
+
+type MatchService struct {
+	db *gorm.DB
+}
+
+func NewMatchService(db *gorm.DB) *MatchService {
+	return &MatchService{db: db}
+}
+
+// Record stores a match, in the transaction of the context if any.
+func (s *MatchService) Record(ctx context.Context, m *model.Match) error {
+	return Conn(ctx, s.db).Create(m).Error
+}
+
+// SetStatus updates the delivery status and the error of a match, in the transaction of the context if any.
+func (s *MatchService) SetStatus(ctx context.Context, m *model.Match) error {
+	return Conn(ctx, s.db).Model(m).Select("Status", "Error").Updates(m).Error
+}
+
+// FindByRule returns the latest matches of a rule, newest first.
+func (s *MatchService) FindByRule(ctx context.Context, ruleID uint, limit int) ([]model.Match, error) {
+	var matches []model.Match
+	err := s.db.WithContext(ctx).Where("rule_id = ?", ruleID).Order("id DESC").Limit(limit).Find(&matches).Error
+	return matches, err
+}
+
+// Stats aggregates the matches of a rule since a time, the last match is of all time.
+func (s *MatchService) Stats(ctx context.Context, ruleID uint, since time.Time) (*model.RuleStats, error) {
+	stats, err := s.stats(ctx, []uint{ruleID}, since)
+	if err != nil {
+		return nil, err
+	}
+	return &stats[0], nil
+}
+
+// StatsByUser returns the stats of all the rules of a user.
+func (s *MatchService) StatsByUser(ctx context.Context, uid uint, since time.Time) ([]model.RuleStats, error) {
+	var ids []uint
+	if err := s.db.WithContext(ctx).Model(&model.Rule{}).Where("user_id = ?", uid).
+		Order("id").Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []model.RuleStats{}, nil
+	}
+	return s.stats(ctx, ids, since)
+}
+
+// stats aggregates the matches of the rules in the database, in the order of the ids.
+func (s *MatchService) stats(ctx context.Context, ids []uint, since time.Time) ([]model.RuleStats, error) {
+	stats := make([]model.RuleStats, len(ids))
+	byRule := make(map[uint]*model.RuleStats, len(ids))
+	for i, id := range ids {
+		stats[i] = model.RuleStats{RuleID: id, Since: since}
+		byRule[id] = &stats[i]
+	}
+	matches := func() *gorm.DB {
+		return s.db.WithContext(ctx).Model(&model.Match{}).Where("rule_id IN ?", ids)
+	}
+
+	var statuses []struct {
+		RuleID uint
+		Status model.DeliveryStatus
+		Count  int64
+	}
+	err := matches().Select("rule_id, status, COUNT(*) AS count").Where("created_at >= ?", since).
+		Group("rule_id, status").Scan(&statuses).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range statuses {
+		st := byRule[row.RuleID]
+		st.Total += row.Count
+		switch row.Status {
+		case model.DeliveryDelivered:
+			st.Delivered += row.Count
+		case model.DeliveryFailed, model.DeliveryDead:
+			st.Failed += row.Count
+		case model.DeliverySuppressed:
+			st.Suppressed += row.Count
+		case model.DeliveryHeld:
+			st.Held += row.Count
+		case model.DeliveryQueued:
+			st.Queued += row.Count
+		}
+	}
+
+	var days []struct {
+		RuleID uint
+		Day    string
+		Count  int64
+	}
+	err = matches().Select("rule_id, date(created_at) AS day, COUNT(*) AS count").Where("created_at >= ?", since).
+		Group("rule_id, date(created_at)").Order("rule_id, day").Scan(&days).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range days {
+		st := byRule[row.RuleID]
+		// some drivers scan a date as a time, keep its day
+		if len(row.Day) > len("2006-01-02") {
+			row.Day = row.Day[:len("2006-01-02")]
+		}
+		st.PerDay = append(st.PerDay, model.DayCount{Day: row.Day, Count: row.Count})
+	}
+
+	var last []model.Match
+	err = s.db.WithContext(ctx).Select("rule_id", "created_at").
+		Where("id IN (?)", matches().Select("MAX(id)").Group("rule_id")).Find(&last).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range last {
+		byRule[last[i].RuleID].LastMatch = &last[i].CreatedAt
+	}
+	return stats, nil
+}
@@ -0,0 +1,65 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMatchStats(t *testing.T) {
+	name := fmt.Sprintf("file:%s-%d?mode=memory&cache=shared", t.Name(), time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(name), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := model.Migration(db); err != nil {
+		t.Fatal(err)
+	}
+	msv := service.NewMatchService(db)
+	ctx := context.Background()
+
+	day := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, m := range []model.Match{
+		{RuleID: 1, Status: model.DeliveryDelivered, CreatedAt: day.Add(-48 * time.Hour)},
+		{RuleID: 1, Status: model.DeliveryDelivered, CreatedAt: day},
+		{RuleID: 1, Status: model.DeliveryDead, CreatedAt: day.Add(time.Hour)},
+		{RuleID: 1, Status: model.DeliveryHeld, CreatedAt: day.Add(24 * time.Hour)},
+		{RuleID: 2, Status: model.DeliveryQueued, CreatedAt: day},
+	} {
+		m := m
+		m.Mode = model.MatchLive
+		if err := msv.Record(ctx, &m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st, err := msv.Stats(ctx, 1, day.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Total != 3 || st.Delivered != 1 || st.Failed != 1 || st.Held != 1 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+	if len(st.PerDay) != 2 || st.PerDay[0] != (model.DayCount{Day: "2024-03-01", Count: 2}) ||
+		st.PerDay[1] != (model.DayCount{Day: "2024-03-02", Count: 1}) {
+		t.Fatalf("unexpected days: %+v", st.PerDay)
+	}
+	if st.LastMatch == nil || !st.LastMatch.Equal(day.Add(24*time.Hour)) {
+		t.Fatalf("unexpected last match: %v", st.LastMatch)
+	}
+
+	st, err = msv.Stats(ctx, 3, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Total != 0 || st.LastMatch != nil || len(st.PerDay) != 0 {
+		t.Fatalf("unexpected stats of a rule without match: %+v", st)
+	}
+}
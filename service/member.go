@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+
+	"github.com/samber/lo"
+	"github.com/strahe/suialert/model"
+)
+
+// Member is a member of a Discord server with its roles.
+type Member struct {
+	GuildID string
+	Roles   []string
+}
+
+type memberKey struct{}
+
+// WithMember returns a context acting as a member of a Discord server,
+// the rules of the server can then be managed by the members holding their role.
+func WithMember(ctx context.Context, m Member) context.Context {
+	return context.WithValue(ctx, memberKey{}, m)
+}
+
+// MemberFrom returns the member of the context, false if it does not act in a server.
+func MemberFrom(ctx context.Context) (Member, bool) {
+	m, ok := ctx.Value(memberKey{}).(Member)
+	return m, ok && m.GuildID != ""
+}
+
+// Manages reports whether the member holds the role managing a rule of their server.
+func (m Member) Manages(r *model.Rule) bool {
+	return r.GuildID != "" && r.RoleID != "" && r.GuildID == m.GuildID && lo.Contains(m.Roles, r.RoleID)
+}
+
+// canManage reports whether a user, or the member of the context, can manage a rule.
+func canManage(ctx context.Context, uid uint, r *model.Rule) bool {
+	if r.UserID == uid {
+		return true
+	}
+	m, ok := MemberFrom(ctx)
+	return ok && m.Manages(r)
+}
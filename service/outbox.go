@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/types"
+)
+
+// maxOutboxError is the length of the errors kept in the outbox
+const maxOutboxError = 1024
+
+type messageKey struct{}
+
+// WithMessage returns a context delivering a message of the outbox, the notifiers can identify
+// the deliveries of an alert with it, see MessageFrom.
+func WithMessage(ctx context.Context, m *model.OutboxMessage) context.Context {
+	return context.WithValue(ctx, messageKey{}, m)
+}
+
+// MessageFrom returns the message of the outbox delivered with the context, if any.
+func MessageFrom(ctx context.Context) (*model.OutboxMessage, bool) {
+	m, ok := ctx.Value(messageKey{}).(*model.OutboxMessage)
+	return m, ok
+}
+
+type OutboxService struct {
+	db *gorm.DB
+}
+
+func NewOutboxService(db *gorm.DB) *OutboxService {
+	return &OutboxService{db: db}
+}
+
+// Enqueue stores an alert to be delivered now, in the transaction of the context if any,
+// so that the alerts of an event are stored if and only if the event is.
+func (s *OutboxService) Enqueue(ctx context.Context, alert *model.Alert) (*model.OutboxMessage, error) {
+	m := &model.OutboxMessage{
+		Destination:   alert.Recipient(),
+		Alert:         *alert,
+		Status:        model.OutboxPending,
+		NextAttemptAt: time.Now().UTC(),
+	}
+	return m, Conn(ctx, s.db).Create(m).Error
+}
+
+// Due returns the oldest pending message of each destination whose next attempt is due at now, at most limit.
+// The later messages of a destination wait until it is delivered or dead-lettered.
+func (s *OutboxService) Due(ctx context.Context, now time.Time, limit int) ([]model.OutboxMessage, error) {
+	heads := s.db.Model(&model.OutboxMessage{}).Select("MIN(id)").
+		Where("status = ?", model.OutboxPending).Group("destination")
+	var msgs []model.OutboxMessage
+	err := s.db.WithContext(ctx).Where("id IN (?) AND next_attempt_at <= ?", heads, now.UTC()).
+		Order("id").Limit(limit).Find(&msgs).Error
+	return msgs, err
+}
+
+// Delivered removes a delivered message, and marks its match delivered.
+func (s *OutboxService) Delivered(ctx context.Context, m *model.OutboxMessage) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(m).Error; err != nil {
+			return err
+		}
+		return settleMatch(tx, &m.Alert, model.DeliveryDelivered, "")
+	})
+}
+
+// Retry records a failed attempt to deliver a message, it is attempted again at a time.
+func (s *OutboxService) Retry(ctx context.Context, m *model.OutboxMessage, cause error, at time.Time) error {
+	m.NextAttemptAt = at.UTC()
+	return s.failed(s.db.WithContext(ctx), m, cause)
+}
+
+// Dead records the last failed attempt to deliver a message, it is only attempted again once re-driven.
+// Its match is marked dead.
+func (s *OutboxService) Dead(ctx context.Context, m *model.OutboxMessage, cause error) error {
+	m.Status = model.OutboxDead
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.failed(tx, m, cause); err != nil {
+			return err
+		}
+		return settleMatch(tx, &m.Alert, model.DeliveryDead, m.LastError)
+	})
+}
+
+func (s *OutboxService) failed(db *gorm.DB, m *model.OutboxMessage, cause error) error {
+	m.Attempts++
+	m.LastError = cause.Error()
+	if len(m.LastError) > maxOutboxError {
+		m.LastError = m.LastError[:maxOutboxError]
+	}
+	return db.Model(m).Select("Status", "Attempts", "LastError", "NextAttemptAt").Updates(m).Error
+}
+
+// settleMatch sets the outcome of the delivery of an alert on its match, or on the matches summarized by a digest.
+// The alerts stored before their match ID was carried settle the latest match of their source for their event.
+// Only queued and held matches are settled, a dead-lettered alert also fails a match delivered by another
+// notification, and a re-driven alert can deliver a dead match.
+// The escalations are not the alerts of a match, they are left out.
+func settleMatch(tx *gorm.DB, alert *model.Alert, status model.DeliveryStatus, cause string) error {
+	if alert.Escalated {
+		return nil
+	}
+	from := []model.DeliveryStatus{model.DeliveryQueued, model.DeliveryHeld, model.DeliveryDead}
+	if status == model.DeliveryDead {
+		from = []model.DeliveryStatus{model.DeliveryQueued, model.DeliveryHeld, model.DeliveryDelivered}
+	}
+	ids := alert.Matches
+	if alert.MatchID != 0 {
+		ids = []uint{alert.MatchID}
+	}
+	if len(ids) == 0 {
+		if alert.Event == types.EventTypeDigest {
+			return nil
+		}
+		err := tx.Model(&model.Match{}).Where(map[string]interface{}{
+			"rule_id":     alert.RuleID,
+			"sequence_id": alert.SequenceID,
+			"static_rule": alert.StaticRule,
+			"check_id":    alert.CheckID,
+			"query_id":    alert.QueryID,
+			"tx_digest":   alert.TxDigest,
+			"event_seq":   alert.EventSeq,
+		}).Where("status IN ?", from).Order("id DESC").Limit(1).Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+	}
+	return tx.Model(&model.Match{}).Where("id IN ? AND status IN ?", ids, from).
+		Updates(map[string]interface{}{"status": status, "error": cause}).Error
+}
+
+// List returns the messages of a status, or of any status if empty, oldest first.
+func (s *OutboxService) List(ctx context.Context, status model.OutboxStatus, limit int) ([]model.OutboxMessage, error) {
+	db := s.db.WithContext(ctx)
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+	var msgs []model.OutboxMessage
+	err := db.Order("id").Limit(limit).Find(&msgs).Error
+	return msgs, err
+}
+
+// FindByID returns a message of the outbox.
+func (s *OutboxService) FindByID(ctx context.Context, id uint) (*model.OutboxMessage, error) {
+	var m model.OutboxMessage
+	err := s.db.WithContext(ctx).First(&m, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return &m, err
+}
+
+// Redrive makes dead-lettered messages pending again with no attempt, all of them if no id is given.
+// It returns the number of messages re-driven, they keep their place in the order of their destination.
+func (s *OutboxService) Redrive(ctx context.Context, ids ...uint) (int64, error) {
+	db := s.db.WithContext(ctx).Model(&model.OutboxMessage{}).Where("status = ?", model.OutboxDead)
+	if len(ids) > 0 {
+		db = db.Where("id IN ?", ids)
+	}
+	res := db.Updates(map[string]interface{}{
+		"status":          model.OutboxPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now().UTC(),
+	})
+	return res.RowsAffected, res.Error
+}
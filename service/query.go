@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/types"
+)
+
+// namedArg matches a named parameter of a query, e.g. @since_ms.
+var namedArg = regexp.MustCompile(`@[a-z][a-z0-9_]*`)
+
+type QueryService struct {
+	db *gorm.DB
+}
+
+func NewQueryService(db *gorm.DB) *QueryService {
+	return &QueryService{db: db}
+}
+
+func (s *QueryService) Create(r *model.QueryRule) error {
+	if r == nil {
+		return fmt.Errorf("query is nil")
+	}
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	return s.db.Create(r).Error
+}
+
+func (s *QueryService) FindByID(id uint) (*model.QueryRule, error) {
+	var rule model.QueryRule
+	err := s.db.First(&rule, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return &rule, err
+}
+
+func (s *QueryService) Delete(r *model.QueryRule) error {
+	if r == nil {
+		return fmt.Errorf("query is nil")
+	}
+	return s.db.Delete(r).Error
+}
+
+// FindAll returns all query rules
+func (s *QueryService) FindAll(ctx context.Context) ([]model.QueryRule, error) {
+	var rules []model.QueryRule
+	err := s.db.WithContext(ctx).Order("id").Find(&rules).Error
+	return rules, err
+}
+
+// MarkRun records the last time a query ran.
+func (s *QueryService) MarkRun(ctx context.Context, r *model.QueryRule, at time.Time) error {
+	r.LastRunAt = &at
+	return s.db.WithContext(ctx).Model(r).Update("last_run_at", at).Error
+}
+
+// Run executes the query of a rule over the window ending at now, reading at most maxRows rows.
+// The query runs in a read-only transaction which is always rolled back,
+// so nothing it may change is kept on the drivers ignoring the read-only option.
+func (s *QueryService) Run(ctx context.Context, r *model.QueryRule, now time.Time, timeout time.Duration, maxRows int) (*types.QueryResult, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	since := now.Add(-r.Window)
+	args := map[string]interface{}{
+		"since":    since,
+		"now":      now,
+		"since_ms": since.UnixMilli(),
+		"now_ms":   now.UnixMilli(),
+	}
+	for k, v := range r.Params {
+		args[k] = v
+	}
+	q := strings.TrimSuffix(strings.TrimSpace(r.Query), ";")
+	if maxRows > 0 {
+		q = fmt.Sprintf("SELECT * FROM (%s) q LIMIT %d", q, maxRows)
+	}
+
+	tx := s.db.WithContext(ctx).Begin(&sql.TxOptions{ReadOnly: true})
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	defer tx.Rollback()
+
+	// the arguments are only bound to queries using them, a literal "@" is not a parameter
+	var vars []interface{}
+	if namedArg.MatchString(q) {
+		vars = append(vars, args)
+	}
+	rows, err := tx.Raw(q, vars...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() // nolint: errcheck
+
+	res := &types.QueryResult{
+		Name:      r.Name,
+		Op:        string(r.Op),
+		Threshold: r.Threshold,
+	}
+	if res.Columns, err = rows.Columns(); err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		row := make([]interface{}, len(res.Columns))
+		ptrs := make([]interface{}, len(row))
+		for i := range row {
+			ptrs[i] = &row[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		for i, v := range row {
+			if b, ok := v.([]byte); ok {
+				row[i] = string(b)
+			}
+		}
+		res.Rows = append(res.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(res.Rows) > 0 && len(res.Columns) > 0 {
+		if res.Value, err = toFloat(res.Rows[0][0]); err != nil {
+			return nil, fmt.Errorf("column %s: %w", res.Columns[0], err)
+		}
+	}
+	return res, nil
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return float64(x), nil
+	case float64:
+		return x, nil
+	case bool:
+		if x {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		f, err := strconv.ParseFloat(x, 64)
+		if err != nil {
+			return 0, fmt.Errorf("not a number: %q", x)
+		}
+		return f, nil
+	}
+	return 0, fmt.Errorf("not a number: %v", v)
+}
@@ -0,0 +1,117 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pgcontrib/bigint"
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestQueryRun(t *testing.T) {
+	ctx := context.Background()
+	name := fmt.Sprintf("file:%s-%d?mode=memory&cache=shared", t.Name(), time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(name), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := model.Migration(db); err != nil {
+		t.Fatal(err)
+	}
+
+	const coin = "0x5::usdc::USDC"
+	now := time.Now()
+	// four changes of the coin within the last hour, one before
+	for i, ago := range []time.Duration{time.Minute, 10 * time.Minute, 20 * time.Minute, 30 * time.Minute, 2 * time.Hour} {
+		err := db.Create(&model.CoinBalanceChangeEvent{
+			TransactionDigest: fmt.Sprintf("tx%d", i),
+			Timestamp:         uint64(now.Add(-ago).UnixMilli()),
+			CoinType:          coin,
+			Amount:            bigint.FromInt64(1),
+		}).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	qsv := service.NewQueryService(db)
+	query := func(q string, threshold float64) *model.QueryRule {
+		return &model.QueryRule{
+			Query:     q,
+			Params:    map[string]string{"coin": coin},
+			Interval:  time.Minute,
+			Window:    time.Hour,
+			Op:        model.OpGreaterEqual,
+			Threshold: threshold,
+		}
+	}
+	count := "SELECT COUNT(*) FROM coin_balance_change_events WHERE coin_type = @coin AND timestamp >= @since_ms"
+
+	t.Run("threshold", func(t *testing.T) {
+		for _, tc := range []struct {
+			threshold float64
+			fires     bool
+		}{
+			{3, true},
+			{4, true},
+			{5, false},
+		} {
+			r := query(count, tc.threshold)
+			res, err := qsv.Run(ctx, r, now, time.Second, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Value != 4 {
+				t.Fatalf("value %g, want 4", res.Value)
+			}
+			if fires := r.Op.Compare(res.Value, r.Threshold); fires != tc.fires {
+				t.Errorf("threshold %g: fires %t, want %t", tc.threshold, fires, tc.fires)
+			}
+		}
+	})
+
+	t.Run("row limit", func(t *testing.T) {
+		res, err := qsv.Run(ctx, query("SELECT timestamp FROM coin_balance_change_events ORDER BY timestamp", 0), now, time.Second, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Rows) != 2 {
+			t.Errorf("%d rows, want 2", len(res.Rows))
+		}
+	})
+
+	t.Run("literal at sign", func(t *testing.T) {
+		// a query without parameters is not bound to the arguments
+		res, err := qsv.Run(ctx, query("SELECT COUNT(*) FROM coin_balance_change_events WHERE coin_type <> 'user@example'", 0), now, time.Second, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Value != 5 {
+			t.Errorf("value %g, want 5", res.Value)
+		}
+	})
+
+	t.Run("not a select", func(t *testing.T) {
+		for _, q := range []string{
+			"DELETE FROM coin_balance_change_events",
+			"SELECT 1; DELETE FROM coin_balance_change_events",
+		} {
+			if _, err := qsv.Run(ctx, query(q, 0), now, time.Second, 10); err == nil {
+				t.Errorf("%q accepted", q)
+			}
+		}
+		var n int64
+		if err := db.Model(&model.CoinBalanceChangeEvent{}).Count(&n).Error; err != nil {
+			t.Fatal(err)
+		}
+		if n != 5 {
+			t.Errorf("%d events left, want 5", n)
+		}
+	})
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/strahe/suialert/types"
 	"gorm.io/gorm"
@@ -13,17 +16,42 @@ import (
 
 type RuleService struct {
 	db *gorm.DB
+
+	lk       sync.RWMutex
+	onChange []func(rule *model.Rule)
 }
 
 func NewRuleService(db *gorm.DB) *RuleService {
 	return &RuleService{db: db}
 }
 
-func (s *RuleService) Create(r *model.Rule) error {
+func (s *RuleService) Create(ctx context.Context, r *model.Rule) error {
 	if r == nil {
 		return fmt.Errorf("rule is nil")
 	}
-	return s.db.Create(r).Error
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(r).Error; err != nil {
+			return err
+		}
+		return s.record(ctx, tx, model.ChangeCreated, nil, r)
+	})
+	if err != nil {
+		return err
+	}
+	s.changed(r)
+	return nil
+}
+
+func (s *RuleService) FindByID(ctx context.Context, id uint) (*model.Rule, error) {
+	var rule model.Rule
+	err := s.db.WithContext(ctx).First(&rule, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return &rule, err
 }
 
 func (s *RuleService) FindByPrimaryKey(uid uint, event types.EventType, addr types.Address) (*model.Rule, error) {
@@ -36,11 +64,253 @@ func (s *RuleService) FindByPrimaryKey(uid uint, event types.EventType, addr typ
 	return &rule, nil
 }
 
-func (s *RuleService) Update(rule *model.Rule) error {
+func (s *RuleService) Update(ctx context.Context, rule *model.Rule) error {
 	if rule == nil {
 		return fmt.Errorf("rule is nil")
 	}
-	return s.db.Select("Condition").Updates(rule).Error
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	return s.modify(ctx, rule, model.ChangeUpdated, func(tx *gorm.DB) error {
+		return tx.Select("Condition", "Salience", "Actions", "Severity", "Escalation").Updates(rule).Error
+	})
+}
+
+// Delete removes the rule, it can be restored from its history.
+func (s *RuleService) Delete(ctx context.Context, rule *model.Rule) error {
+	if rule == nil {
+		return fmt.Errorf("rule is nil")
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var old model.Rule
+		if err := tx.First(&old, rule.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&old).Error; err != nil {
+			return err
+		}
+		return s.record(ctx, tx, model.ChangeDeleted, &old, nil)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	s.changed(rule)
+	return nil
+}
+
+// Pause stops the rule from matching, without deleting it.
+func (s *RuleService) Pause(ctx context.Context, rule *model.Rule) error {
+	return s.setPaused(ctx, rule, true)
+}
+
+// Resume lets a paused rule match again.
+func (s *RuleService) Resume(ctx context.Context, rule *model.Rule) error {
+	return s.setPaused(ctx, rule, false)
+}
+
+func (s *RuleService) setPaused(ctx context.Context, rule *model.Rule, paused bool) error {
+	if rule == nil {
+		return fmt.Errorf("rule is nil")
+	}
+	change := model.ChangeResumed
+	if paused {
+		change = model.ChangePaused
+	}
+	rule.Paused = paused
+	return s.modify(ctx, rule, change, func(tx *gorm.DB) error {
+		return tx.Model(rule).Update("paused", paused).Error
+	})
+}
+
+// SetShadow makes the rule only record its matches, or go live again.
+func (s *RuleService) SetShadow(ctx context.Context, rule *model.Rule, shadow bool) error {
+	if rule == nil {
+		return fmt.Errorf("rule is nil")
+	}
+	rule.Shadow = shadow
+	return s.modify(ctx, rule, model.ChangeUpdated, func(tx *gorm.DB) error {
+		return tx.Model(rule).Update("shadow", shadow).Error
+	})
+}
+
+// SetExpiry sets the time after which the rule stops matching, nil never expires.
+func (s *RuleService) SetExpiry(ctx context.Context, rule *model.Rule, at *time.Time) error {
+	if rule == nil {
+		return fmt.Errorf("rule is nil")
+	}
+	rule.ExpiresAt = at
+	return s.modify(ctx, rule, model.ChangeUpdated, func(tx *gorm.DB) error {
+		return tx.Model(rule).Update("expires_at", at).Error
+	})
+}
+
+// Mute suppresses the notifications of the rule until a time, nil unmutes it.
+func (s *RuleService) Mute(ctx context.Context, rule *model.Rule, until *time.Time) error {
+	if rule == nil {
+		return fmt.Errorf("rule is nil")
+	}
+	rule.MutedUntil = until
+	return s.modify(ctx, rule, model.ChangeUpdated, func(tx *gorm.DB) error {
+		return tx.Model(rule).Update("muted_until", until).Error
+	})
+}
+
+// SetDigest sets the digest mode of the notifications of a rule, DigestDefault follows the mode of its owner.
+func (s *RuleService) SetDigest(ctx context.Context, rule *model.Rule, mode model.DigestMode) error {
+	if rule == nil {
+		return fmt.Errorf("rule is nil")
+	}
+	if !mode.Valid() {
+		return fmt.Errorf("unknown digest mode: %s", mode)
+	}
+	rule.Digest = mode
+	return s.modify(ctx, rule, model.ChangeUpdated, func(tx *gorm.DB) error {
+		return tx.Model(rule).Update("digest", mode).Error
+	})
+}
+
+// SetDestination sends the notifications of the rule to a channel, e.g. "slack:ops", empty sends them
+// to its owner again. The Discord server and role that managed the rule are cleared.
+func (s *RuleService) SetDestination(ctx context.Context, rule *model.Rule, dest string) error {
+	if rule == nil {
+		return fmt.Errorf("rule is nil")
+	}
+	if dest != "" {
+		if name, channel, ok := strings.Cut(dest, ":"); !ok || name == "" || channel == "" {
+			return fmt.Errorf("invalid destination %q, expected <backend>:<channel>", dest)
+		}
+	}
+	rule.Destination, rule.GuildID, rule.RoleID = dest, "", ""
+	return s.modify(ctx, rule, model.ChangeUpdated, func(tx *gorm.DB) error {
+		return tx.Model(rule).Select("Destination", "GuildID", "RoleID").Updates(rule).Error
+	})
+}
+
+// History returns the versions of a rule, oldest first.
+func (s *RuleService) History(ctx context.Context, ruleID uint) ([]model.RuleHistory, error) {
+	var history []model.RuleHistory
+	err := s.db.WithContext(ctx).Where("rule_id = ?", ruleID).Order("version").Find(&history).Error
+	return history, err
+}
+
+// Revision returns the id of the last change recorded in the history of the rules, 0 if none.
+func (s *RuleService) Revision(ctx context.Context) (uint, error) {
+	var rev uint
+	err := s.db.WithContext(ctx).Model(&model.RuleHistory{}).Select("COALESCE(MAX(id), 0)").Scan(&rev).Error
+	return rev, err
+}
+
+// ChangesSince returns the changes of the rules recorded after a revision, oldest first,
+// including the ones made by other processes sharing the database.
+func (s *RuleService) ChangesSince(ctx context.Context, rev uint) ([]model.RuleHistory, error) {
+	var history []model.RuleHistory
+	err := s.db.WithContext(ctx).Where("id > ?", rev).Order("id").Find(&history).Error
+	return history, err
+}
+
+// Restore brings a rule back to one of its versions, recreating it if it was deleted.
+func (s *RuleService) Restore(ctx context.Context, ruleID uint, version int) (*model.Rule, error) {
+	var h model.RuleHistory
+	err := s.db.WithContext(ctx).Where("rule_id = ? AND version = ?", ruleID, version).First(&h).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if h.Change == model.ChangeDeleted {
+		return nil, fmt.Errorf("version %d of rule %d is a deletion", version, ruleID)
+	}
+	rule := h.Snapshot
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var old model.Rule
+		res := tx.Limit(1).Find(&old, ruleID)
+		switch {
+		case res.Error != nil:
+			return res.Error
+		case res.RowsAffected == 0:
+			rule.CreatedAt = time.Time{}
+			rule.UpdatedAt = time.Time{}
+			if err := tx.Create(&rule).Error; err != nil {
+				return err
+			}
+			return s.record(ctx, tx, model.ChangeRestored, nil, &rule)
+		}
+		rule.CreatedAt = old.CreatedAt
+		err = tx.Model(&rule).Select("Condition", "Salience", "Actions", "Severity", "Escalation", "Paused", "Shadow",
+			"ExpiresAt", "MutedUntil", "Destination", "GuildID", "RoleID", "Digest").Updates(&rule).Error
+		if err != nil {
+			return err
+		}
+		var cur model.Rule
+		if err := tx.First(&cur, ruleID).Error; err != nil {
+			return err
+		}
+		rule = cur
+		return s.record(ctx, tx, model.ChangeRestored, &old, &cur)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.changed(&rule)
+	return &rule, nil
+}
+
+// modify applies fn to an existing rule and records the change.
+func (s *RuleService) modify(ctx context.Context, rule *model.Rule, change model.ChangeType, fn func(tx *gorm.DB) error) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var old model.Rule
+		if err := tx.First(&old, rule.ID).Error; err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			return err
+		}
+		var cur model.Rule
+		if err := tx.First(&cur, rule.ID).Error; err != nil {
+			return err
+		}
+		return s.record(ctx, tx, change, &old, &cur)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	s.changed(rule)
+	return nil
+}
+
+// record adds a version to the history of a rule, old is nil when created and cur when deleted.
+func (s *RuleService) record(ctx context.Context, tx *gorm.DB, change model.ChangeType, old, cur *model.Rule) error {
+	snapshot := cur
+	if snapshot == nil {
+		snapshot = old
+	}
+	var last int
+	err := tx.Model(&model.RuleHistory{}).Where("rule_id = ?", snapshot.ID).
+		Select("COALESCE(MAX(version), 0)").Scan(&last).Error
+	if err != nil {
+		return err
+	}
+	actor := ActorFrom(ctx)
+	return tx.Create(&model.RuleHistory{
+		RuleID:   snapshot.ID,
+		Version:  last + 1,
+		Change:   change,
+		Actor:    actor.Name,
+		Source:   actor.Source,
+		Diff:     model.DiffRules(old, cur),
+		Snapshot: *snapshot,
+	}).Error
 }
 
 func (s *RuleService) FindByAddress(addr types.Address) ([]model.Rule, error) {
@@ -51,6 +321,13 @@ func (s *RuleService) FindByAddress(addr types.Address) ([]model.Rule, error) {
 	return rules, nil
 }
 
+// FindByAddressAndEvent returns the rules of an address for one event type.
+func (s *RuleService) FindByAddressAndEvent(ctx context.Context, addr types.Address, event types.EventType) ([]model.Rule, error) {
+	var rules []model.Rule
+	err := s.db.WithContext(ctx).Where(&model.Rule{Address: addr, Event: event}, "Address", "Event").Find(&rules).Error
+	return rules, err
+}
+
 // FindAll returns all rules
 // todo: pagination
 func (s *RuleService) FindAll(ctx context.Context) ([]model.Rule, error) {
@@ -60,3 +337,18 @@ func (s *RuleService) FindAll(ctx context.Context) ([]model.Rule, error) {
 	}
 	return rules, nil
 }
+
+// Subscribe registers fn to be called after a rule was created, updated, deleted or restored.
+func (s *RuleService) Subscribe(fn func(rule *model.Rule)) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.onChange = append(s.onChange, fn)
+}
+
+func (s *RuleService) changed(rule *model.Rule) {
+	s.lk.RLock()
+	defer s.lk.RUnlock()
+	for _, fn := range s.onChange {
+		fn(rule)
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/strahe/suialert/model"
+)
+
+// RuleImport is the change an imported rule makes to the existing rules.
+type RuleImport struct {
+	Rule *model.Rule
+	// Change is ChangeCreated, ChangeUpdated, or empty if the rule is unchanged.
+	Change model.ChangeType
+	Diff   []model.FieldChange
+}
+
+// FindByUser returns the rules of a user.
+func (s *RuleService) FindByUser(ctx context.Context, uid uint) ([]model.Rule, error) {
+	var rules []model.Rule
+	err := s.db.WithContext(ctx).Where("user_id = ?", uid).Order("id").Find(&rules).Error
+	return rules, err
+}
+
+// PlanImport returns the changes importing the rules would make, without writing them.
+func (s *RuleService) PlanImport(ctx context.Context, rules []*model.Rule) ([]RuleImport, error) {
+	return s.planImport(s.db.WithContext(ctx), rules)
+}
+
+// Import creates or updates the rules, matched by user, event and address.
+// Nothing is written if any rule is invalid.
+func (s *RuleService) Import(ctx context.Context, rules []*model.Rule) ([]RuleImport, error) {
+	var plan []RuleImport
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if plan, err = s.planImport(tx, rules); err != nil {
+			return err
+		}
+		for _, p := range plan {
+			switch p.Change {
+			case model.ChangeCreated:
+				if err := tx.Create(p.Rule).Error; err != nil {
+					return err
+				}
+				if err := s.record(ctx, tx, model.ChangeCreated, nil, p.Rule); err != nil {
+					return err
+				}
+			case model.ChangeUpdated:
+				var old model.Rule
+				if err := tx.First(&old, p.Rule.ID).Error; err != nil {
+					return err
+				}
+				err := tx.Model(p.Rule).Select("Condition", "Salience", "Actions", "Severity", "Escalation", "Paused", "Shadow", "ExpiresAt",
+					"Destination", "GuildID", "RoleID", "Digest").
+					Updates(p.Rule).Error
+				if err != nil {
+					return err
+				}
+				if err := s.record(ctx, tx, model.ChangeUpdated, &old, p.Rule); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range plan {
+		if p.Change != "" {
+			s.changed(p.Rule)
+		}
+	}
+	return plan, nil
+}
+
+func (s *RuleService) planImport(tx *gorm.DB, rules []*model.Rule) ([]RuleImport, error) {
+	type key struct {
+		uid     uint
+		event   string
+		address string
+	}
+	seen := map[key]int{}
+	users := map[uint]bool{}
+	plan := make([]RuleImport, 0, len(rules))
+	for i, r := range rules {
+		if err := r.Validate(); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		k := key{uid: r.UserID, event: string(r.Event), address: r.Address.Hex()}
+		if j, ok := seen[k]; ok {
+			return nil, fmt.Errorf("rule %d: duplicate of rule %d", i, j)
+		}
+		seen[k] = i
+
+		if _, ok := users[r.UserID]; !ok {
+			var count int64
+			if err := tx.Model(&model.User{}).Where("id = ?", r.UserID).Count(&count).Error; err != nil {
+				return nil, err
+			}
+			users[r.UserID] = count > 0
+		}
+		if !users[r.UserID] {
+			return nil, fmt.Errorf("rule %d: user %d not found", i, r.UserID)
+		}
+
+		var existing []model.Rule
+		err := tx.Where(&model.Rule{UserID: r.UserID, Event: r.Event, Address: r.Address},
+			"UserID", "Event", "Address").Limit(1).Find(&existing).Error
+		if err != nil {
+			return nil, err
+		}
+		if len(existing) == 0 {
+			plan = append(plan, RuleImport{Rule: r, Change: model.ChangeCreated, Diff: model.DiffRules(nil, r)})
+			continue
+		}
+		old := existing[0]
+		r.ID = old.ID
+		r.CreatedAt = old.CreatedAt
+		// muting is runtime state, neither exported nor imported
+		r.MutedUntil = old.MutedUntil
+		p := RuleImport{Rule: r, Diff: model.DiffRules(&old, r)}
+		if len(p.Diff) > 0 {
+			p.Change = model.ChangeUpdated
+		}
+		plan = append(plan, p)
+	}
+	return plan, nil
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/strahe/suialert/model"
+	"gorm.io/gorm"
+)
+
+// The methods below act on the rules of a user on their behalf, and on the rules of the server
+// they manage when the context has a Member, see WithMember. The other rules are reported as not found.
+
+// ListByUser returns a page of the rules of a user ordered by id, and the number of their rules.
+func (s *RuleService) ListByUser(ctx context.Context, uid uint, offset, limit int) ([]model.Rule, int64, error) {
+	managed := func(db *gorm.DB) *gorm.DB {
+		db = db.Where("user_id = ?", uid)
+		if m, ok := MemberFrom(ctx); ok && len(m.Roles) > 0 {
+			db = db.Or("guild_id = ? AND role_id IN ?", m.GuildID, m.Roles)
+		}
+		return db
+	}
+	var total int64
+	err := s.db.WithContext(ctx).Model(&model.Rule{}).Scopes(managed).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	var rules []model.Rule
+	err = s.db.WithContext(ctx).Scopes(managed).Order("id").Offset(offset).Limit(limit).Find(&rules).Error
+	return rules, total, err
+}
+
+// FindOwned returns a rule of a user.
+func (s *RuleService) FindOwned(ctx context.Context, uid, id uint) (*model.Rule, error) {
+	r, err := s.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !canManage(ctx, uid, r) {
+		return nil, ErrNotFound
+	}
+	return r, nil
+}
+
+// EditOwned changes the condition and the severity of a rule of a user.
+func (s *RuleService) EditOwned(ctx context.Context, uid, id uint, condition string, severity model.Severity) (*model.Rule, error) {
+	r, err := s.FindOwned(ctx, uid, id)
+	if err != nil {
+		return nil, err
+	}
+	r.Condition = condition
+	r.Severity = severity
+	return r, s.Update(ctx, r)
+}
+
+// DeleteOwned removes a rule of a user.
+func (s *RuleService) DeleteOwned(ctx context.Context, uid, id uint) (*model.Rule, error) {
+	r, err := s.FindOwned(ctx, uid, id)
+	if err != nil {
+		return nil, err
+	}
+	return r, s.Delete(ctx, r)
+}
+
+// PauseOwned pauses a rule of a user.
+func (s *RuleService) PauseOwned(ctx context.Context, uid, id uint) (*model.Rule, error) {
+	r, err := s.FindOwned(ctx, uid, id)
+	if err != nil {
+		return nil, err
+	}
+	return r, s.Pause(ctx, r)
+}
+
+// ResumeOwned resumes a paused rule of a user.
+func (s *RuleService) ResumeOwned(ctx context.Context, uid, id uint) (*model.Rule, error) {
+	r, err := s.FindOwned(ctx, uid, id)
+	if err != nil {
+		return nil, err
+	}
+	return r, s.Resume(ctx, r)
+}
+
+// MuteOwned suppresses the notifications of a rule of a user until a time.
+func (s *RuleService) MuteOwned(ctx context.Context, uid, id uint, until time.Time) (*model.Rule, error) {
+	r, err := s.FindOwned(ctx, uid, id)
+	if err != nil {
+		return nil, err
+	}
+	return r, s.Mute(ctx, r, &until)
+}
+
+// SetDigestOwned sets the digest mode of a rule of a user.
+func (s *RuleService) SetDigestOwned(ctx context.Context, uid, id uint, mode model.DigestMode) (*model.Rule, error) {
+	r, err := s.FindOwned(ctx, uid, id)
+	if err != nil {
+		return nil, err
+	}
+	return r, s.SetDigest(ctx, r, mode)
+}
+
+// SetExpiryOwned sets the time after which a rule of a user stops matching, nil never expires.
+func (s *RuleService) SetExpiryOwned(ctx context.Context, uid, id uint, at *time.Time) (*model.Rule, error) {
+	r, err := s.FindOwned(ctx, uid, id)
+	if err != nil {
+		return nil, err
+	}
+	return r, s.SetExpiry(ctx, r, at)
+}
+
+// HistoryOwned returns the versions of a rule of a user, oldest first, also once the rule is deleted.
+func (s *RuleService) HistoryOwned(ctx context.Context, uid, id uint) ([]model.RuleHistory, error) {
+	history, err := s.History(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// the last version tells who manages the rule, also once deleted
+	if len(history) == 0 || !canManage(ctx, uid, &history[len(history)-1].Snapshot) {
+		return nil, ErrNotFound
+	}
+	return history, nil
+}
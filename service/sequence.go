@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/strahe/suialert/model"
+)
+
+type SequenceService struct {
+	db *gorm.DB
+
+	lk       sync.RWMutex
+	onChange []func(rule *model.SequenceRule)
+}
+
+func NewSequenceService(db *gorm.DB) *SequenceService {
+	return &SequenceService{db: db}
+}
+
+func (s *SequenceService) Create(r *model.SequenceRule) error {
+	if r == nil {
+		return fmt.Errorf("sequence is nil")
+	}
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	if err := s.db.Create(r).Error; err != nil {
+		return err
+	}
+	s.changed(r)
+	return nil
+}
+
+func (s *SequenceService) FindByID(id uint) (*model.SequenceRule, error) {
+	var rule model.SequenceRule
+	err := s.db.First(&rule, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return &rule, err
+}
+
+func (s *SequenceService) Delete(r *model.SequenceRule) error {
+	if r == nil {
+		return fmt.Errorf("sequence is nil")
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("sequence_id = ?", r.ID).Delete(&model.SequenceState{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(r).Error
+	})
+	if err != nil {
+		return err
+	}
+	s.changed(r)
+	return nil
+}
+
+// FindAll returns all sequence rules
+func (s *SequenceService) FindAll(ctx context.Context) ([]model.SequenceRule, error) {
+	var rules []model.SequenceRule
+	err := s.db.WithContext(ctx).Find(&rules).Error
+	return rules, err
+}
+
+// Revision changes when a sequence rule is created or deleted.
+func (s *SequenceService) Revision(ctx context.Context) (string, error) {
+	return revision(ctx, s.db, &model.SequenceRule{})
+}
+
+// LoadStates returns the checkpointed partial matches.
+func (s *SequenceService) LoadStates(ctx context.Context) ([]model.SequenceState, error) {
+	var states []model.SequenceState
+	err := s.db.WithContext(ctx).Find(&states).Error
+	return states, err
+}
+
+// SaveStates replaces the checkpointed partial matches.
+func (s *SequenceService) SaveStates(ctx context.Context, states []model.SequenceState) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&model.SequenceState{}).Error; err != nil {
+			return err
+		}
+		if len(states) == 0 {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&states).Error
+	})
+}
+
+// Subscribe registers fn to be called after a sequence rule was created or deleted.
+func (s *SequenceService) Subscribe(fn func(rule *model.SequenceRule)) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.onChange = append(s.onChange, fn)
+}
+
+func (s *SequenceService) changed(rule *model.SequenceRule) {
+	s.lk.RLock()
+	defer s.lk.RUnlock()
+	for _, fn := range s.onChange {
+		fn(rule)
+	}
+}
@@ -1,5 +1,22 @@
 package service
 
-import "fmt"
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"gorm.io/gorm"
+)
 
 var ErrNotFound = fmt.Errorf("not found")
+
+// revision identifies the rows of a table of rules, it changes when a rule is created or deleted,
+// also by another process sharing the database.
+func revision(ctx context.Context, db *gorm.DB, table interface{}) (string, error) {
+	var rev struct {
+		Count   int64
+		Created sql.NullString
+	}
+	err := db.WithContext(ctx).Model(table).Select("COUNT(*) AS count, MAX(created_at) AS created").Scan(&rev).Error
+	return fmt.Sprintf("%d/%s", rev.Count, rev.Created.String), err
+}
@@ -1,15 +1,41 @@
 package service
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
 	"errors"
 	"fmt"
+	"math/big"
+	"net/mail"
+	"strings"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"gopkg.in/telebot.v3"
 	"gorm.io/gorm"
 
 	"github.com/strahe/suialert/model"
 )
 
+var (
+	// ErrInvalidCode is returned when an email is verified with a wrong or expired code.
+	ErrInvalidCode = fmt.Errorf("invalid or expired code")
+	// ErrTooManyCodes is returned when verification codes are requested too often.
+	ErrTooManyCodes = fmt.Errorf("too many verification codes requested, please try again later")
+)
+
+const (
+	// emailCodeTTL is the time a verification code is valid for.
+	emailCodeTTL = time.Hour
+	// maxEmailAttempts is the number of wrong codes after which a code is cleared.
+	maxEmailAttempts = 5
+	// emailCodeInterval is the time a user waits before requesting another code.
+	emailCodeInterval = time.Minute
+	// maxCodesPerAddress is the number of users verifying the same address at once,
+	// so that an address is not flooded with codes by many users.
+	maxCodesPerAddress = 3
+)
+
 type UserService struct {
 	db *gorm.DB
 }
@@ -18,6 +44,15 @@ func NewUserService(db *gorm.DB) *UserService {
 	return &UserService{db: db}
 }
 
+func (s *UserService) FindByID(id uint) (*model.User, error) {
+	var user model.User
+	err := s.db.First(&user, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return &user, err
+}
+
 func (s *UserService) FindByDiscordID(id string) (*model.User, error) {
 	var user model.User
 	err := s.db.Where(&model.User{DiscordID: &id}, "DiscordID").First(&user).Error
@@ -45,3 +80,119 @@ func (s *UserService) FindOrCreateByDiscordUser(du *discordgo.User) (*model.User
 	}
 	return &u, s.db.Where(&model.User{DiscordID: &du.ID}, "DiscordID").FirstOrCreate(&u).Error
 }
+
+func (s *UserService) FindOrCreateByTelegramUser(tu *telebot.User) (*model.User, error) {
+	if tu == nil {
+		return nil, fmt.Errorf("telegram user is nil")
+	}
+	name := tu.Username
+	if name == "" {
+		name = strings.TrimSpace(tu.FirstName + " " + tu.LastName)
+	}
+	u := model.User{
+		TelegramID:   &tu.ID,
+		Name:         name,
+		TelegramInfo: tu,
+	}
+	return &u, s.db.Where(&model.User{TelegramID: &tu.ID}, "TelegramID").FirstOrCreate(&u).Error
+}
+
+// UpdateSchedule sets the timezone and the alert schedule of the user.
+func (s *UserService) UpdateSchedule(user *model.User, timezone string, schedule model.Schedule) error {
+	if user == nil {
+		return fmt.Errorf("user is nil")
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return fmt.Errorf("invalid timezone: %s", err)
+	}
+	if err := schedule.Validate(); err != nil {
+		return err
+	}
+	user.Timezone = timezone
+	user.Schedule = schedule
+	return s.db.Model(user).Select("Timezone", "Schedule").Updates(user).Error
+}
+
+// SetEmail sets an unverified email address of the user, and returns the code to verify it with.
+// A user requests a code at most every emailCodeInterval, and an address has at most maxCodesPerAddress
+// codes pending, ErrTooManyCodes is returned otherwise.
+func (s *UserService) SetEmail(user *model.User, address string) (string, error) {
+	if user == nil {
+		return "", fmt.Errorf("user is nil")
+	}
+	addr, err := mail.ParseAddress(address)
+	if err != nil {
+		return "", fmt.Errorf("invalid email address: %s", address)
+	}
+	now := time.Now()
+	if user.EmailCodeAt != nil && now.Sub(*user.EmailCodeAt) < emailCodeInterval {
+		return "", ErrTooManyCodes
+	}
+	var pending int64
+	err = s.db.Model(&model.User{}).Where("id <> ? AND email = ? AND email_code <> '' AND email_code_at > ?",
+		user.ID, addr.Address, now.Add(-emailCodeTTL)).Count(&pending).Error
+	if err != nil {
+		return "", err
+	}
+	if pending >= maxCodesPerAddress {
+		return "", ErrTooManyCodes
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	user.Email = &addr.Address
+	user.EmailVerified = false
+	user.EmailCode = fmt.Sprintf("%06d", n)
+	user.EmailCodeAt = &now
+	user.EmailAttempts = 0
+	err = s.db.Model(user).Select("Email", "EmailVerified", "EmailCode", "EmailCodeAt", "EmailAttempts").Updates(user).Error
+	return user.EmailCode, err
+}
+
+// VerifyEmail verifies the email address of the user with the code sent to it.
+func (s *UserService) VerifyEmail(user *model.User, code string) error {
+	if user == nil {
+		return fmt.Errorf("user is nil")
+	}
+	if user.Email == nil || user.EmailCode == "" || user.EmailCodeAt == nil || time.Since(*user.EmailCodeAt) > emailCodeTTL {
+		return ErrInvalidCode
+	}
+	if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(code)), []byte(user.EmailCode)) != 1 {
+		// the code is cleared after maxEmailAttempts, a new one must be requested
+		user.EmailAttempts++
+		if user.EmailAttempts >= maxEmailAttempts {
+			user.EmailCode = ""
+			user.EmailCodeAt = nil
+		}
+		if err := s.db.Model(user).Select("EmailCode", "EmailCodeAt", "EmailAttempts").Updates(user).Error; err != nil {
+			return err
+		}
+		return ErrInvalidCode
+	}
+	user.EmailVerified = true
+	user.EmailCode = ""
+	user.EmailCodeAt = nil
+	user.EmailAttempts = 0
+	return s.db.Model(user).Select("EmailVerified", "EmailCode", "EmailCodeAt", "EmailAttempts").Updates(user).Error
+}
+
+// SetVerifiedEmail sets the email address of the user without verification, nil removes it.
+func (s *UserService) SetVerifiedEmail(user *model.User, address *string) error {
+	if user == nil {
+		return fmt.Errorf("user is nil")
+	}
+	if address != nil {
+		addr, err := mail.ParseAddress(*address)
+		if err != nil {
+			return fmt.Errorf("invalid email address: %s", *address)
+		}
+		address = &addr.Address
+	}
+	user.Email = address
+	user.EmailVerified = address != nil
+	user.EmailCode = ""
+	user.EmailCodeAt = nil
+	user.EmailAttempts = 0
+	return s.db.Model(user).Select("Email", "EmailVerified", "EmailCode", "EmailCodeAt", "EmailAttempts").Updates(user).Error
+}
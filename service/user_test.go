@@ -0,0 +1,73 @@
+package service_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/strahe/suialert/model"
+	"github.com/strahe/suialert/service"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestVerifyEmailAttempts(t *testing.T) {
+	name := fmt.Sprintf("file:%s-%d?mode=memory&cache=shared", t.Name(), time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(name), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := model.Migration(db); err != nil {
+		t.Fatal(err)
+	}
+	usv := service.NewUserService(db)
+	newUser := func() *model.User {
+		u := &model.User{}
+		if err := usv.Create(u); err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+
+	t.Run("wrong codes", func(t *testing.T) {
+		u := newUser()
+		code, err := usv.SetEmail(u, "alice@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 5; i++ {
+			if err := usv.VerifyEmail(u, "wrong"); !errors.Is(err, service.ErrInvalidCode) {
+				t.Fatalf("attempt %d: err = %v, want %v", i, err, service.ErrInvalidCode)
+			}
+		}
+		// the code is cleared after too many attempts, also for the user read back
+		u, err = usv.FindByID(u.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := usv.VerifyEmail(u, code); !errors.Is(err, service.ErrInvalidCode) {
+			t.Fatalf("err = %v, want %v after too many attempts", err, service.ErrInvalidCode)
+		}
+	})
+
+	t.Run("rate limit", func(t *testing.T) {
+		u := newUser()
+		if _, err := usv.SetEmail(u, "bob@example.com"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := usv.SetEmail(u, "bob@example.com"); !errors.Is(err, service.ErrTooManyCodes) {
+			t.Fatalf("err = %v, want %v for a second code of the user", err, service.ErrTooManyCodes)
+		}
+		// the other users get a code for the address until it has too many pending
+		for i := 0; i < 2; i++ {
+			if _, err := usv.SetEmail(newUser(), "bob@example.com"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := usv.SetEmail(newUser(), "bob@example.com"); !errors.Is(err, service.ErrTooManyCodes) {
+			t.Fatalf("err = %v, want %v for too many codes of the address", err, service.ErrTooManyCodes)
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/strahe/suialert/model"
+)
+
+type WebhookService struct {
+	db *gorm.DB
+}
+
+func NewWebhookService(db *gorm.DB) *WebhookService {
+	return &WebhookService{db: db}
+}
+
+// Create registers a webhook, a random secret is generated if it has none.
+func (s *WebhookService) Create(ctx context.Context, w *model.Webhook) error {
+	if w == nil {
+		return fmt.Errorf("webhook is nil")
+	}
+	if w.Secret == "" {
+		secret, err := NewSecret()
+		if err != nil {
+			return err
+		}
+		w.Secret = secret
+	}
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Create(w).Error
+}
+
+func (s *WebhookService) FindByID(ctx context.Context, id uint) (*model.Webhook, error) {
+	var w model.Webhook
+	err := s.db.WithContext(ctx).First(&w, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return &w, err
+}
+
+// FindByUser returns the webhooks of a user, all of them if uid is 0.
+func (s *WebhookService) FindByUser(ctx context.Context, uid uint) ([]model.Webhook, error) {
+	var ws []model.Webhook
+	tx := s.db.WithContext(ctx).Order("id")
+	if uid != 0 {
+		tx = tx.Where("user_id = ?", uid)
+	}
+	return ws, tx.Find(&ws).Error
+}
+
+func (s *WebhookService) Delete(ctx context.Context, w *model.Webhook) error {
+	if w == nil {
+		return fmt.Errorf("webhook is nil")
+	}
+	return s.db.WithContext(ctx).Delete(w).Error
+}
+
+// RecordDelivery records an attempt to deliver an alert.
+func (s *WebhookService) RecordDelivery(ctx context.Context, d *model.WebhookDelivery) error {
+	return s.db.WithContext(ctx).Create(d).Error
+}
+
+// Deliveries returns the last delivery attempts of a webhook, the latest first.
+func (s *WebhookService) Deliveries(ctx context.Context, webhookID uint, limit int) ([]model.WebhookDelivery, error) {
+	var ds []model.WebhookDelivery
+	err := s.db.WithContext(ctx).Where("webhook_id = ?", webhookID).
+		Order("id DESC").Limit(limit).Find(&ds).Error
+	return ds, err
+}
+
+// NewSecret returns a random secret to sign the webhooks with.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,59 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+)
+
+// BigInt is an arbitrary-precision integer.
+// Sui serializes u128 and i128 values either as json numbers or strings, both are accepted.
+type BigInt big.Int
+
+func NewBigInt(x *big.Int) *BigInt {
+	return (*BigInt)(x)
+}
+
+func BigIntFromInt64(x int64) *BigInt {
+	return (*BigInt)(big.NewInt(x))
+}
+
+// BigIntFromString parses a base 10 integer.
+func BigIntFromString(s string) (*BigInt, error) {
+	x, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid integer: %q", s)
+	}
+	return (*BigInt)(x), nil
+}
+
+// Int returns the value as *big.Int, a nil BigInt is zero.
+func (b *BigInt) Int() *big.Int {
+	if b == nil {
+		return new(big.Int)
+	}
+	return (*big.Int)(b)
+}
+
+func (b *BigInt) String() string {
+	return b.Int().String()
+}
+
+// MarshalJSON always writes a string, json numbers can't hold u128 values in most decoders.
+func (b *BigInt) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + b.String() + `"`), nil
+}
+
+func (b *BigInt) UnmarshalJSON(data []byte) error {
+	data = bytes.Trim(data, `"`)
+	if len(data) == 0 || string(data) == "null" {
+		(*big.Int)(b).SetInt64(0)
+		return nil
+	}
+	x, ok := new(big.Int).SetString(string(data), 10)
+	if !ok {
+		return fmt.Errorf("invalid integer: %s", data)
+	}
+	(*big.Int)(b).Set(x)
+	return nil
+}
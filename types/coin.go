@@ -0,0 +1,82 @@
+package types
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+const (
+	SuiCoinType = "0x2::sui::SUI"
+)
+
+// CoinInfo describes how the amounts of a coin are displayed.
+type CoinInfo struct {
+	Symbol   string
+	Decimals uint8
+	// Static price, 0 if unknown
+	PriceUSD float64
+}
+
+var (
+	coinsLk sync.RWMutex
+	coins   = map[string]CoinInfo{
+		SuiCoinType: {Symbol: "SUI", Decimals: 9},
+	}
+)
+
+// RegisterCoin sets the symbol and the decimals of a coin type.
+func RegisterCoin(coinType string, info CoinInfo) {
+	coinsLk.Lock()
+	defer coinsLk.Unlock()
+	coins[coinType] = info
+}
+
+// LookupCoin returns the registered info of a coin type.
+// Unknown coins use the name of their struct as symbol and no decimals.
+func LookupCoin(coinType string) CoinInfo {
+	coinsLk.RLock()
+	info, ok := coins[coinType]
+	coinsLk.RUnlock()
+	if ok {
+		return info
+	}
+	symbol := coinType
+	if i := strings.LastIndex(coinType, "::"); i >= 0 {
+		symbol = coinType[i+2:]
+	}
+	return CoinInfo{Symbol: symbol}
+}
+
+// FormatAmount formats an amount of the smallest unit of a coin as a decimal number,
+// e.g. 1500000000 with 9 decimals is "1.5".
+func FormatAmount(x *big.Int, decimals uint8) string {
+	if x == nil {
+		x = new(big.Int)
+	}
+	s := new(big.Int).Abs(x).String()
+	if decimals > 0 {
+		if len(s) <= int(decimals) {
+			s = strings.Repeat("0", int(decimals)-len(s)+1) + s
+		}
+		whole, frac := s[:len(s)-int(decimals)], strings.TrimRight(s[len(s)-int(decimals):], "0")
+		s = whole
+		if frac != "" {
+			s += "." + frac
+		}
+	}
+	if x.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// ParseAmount parses a decimal number of units of a coin, e.g. "1.5".
+func ParseAmount(value string) (*big.Rat, error) {
+	x, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok {
+		return nil, fmt.Errorf("invalid amount: %q", value)
+	}
+	return x, nil
+}
@@ -7,7 +7,9 @@ import (
 	"errors"
 	"fmt"
 	"html"
+	"math/big"
 	"strings"
+	"time"
 )
 
 const (
@@ -18,6 +20,14 @@ const (
 	EventTypeNewObject         = EventType("NewObject")
 	EventTypeDeleteObject      = EventType("DeleteObject")
 	EventTypeMutateObject      = EventType("MutateObject")
+	// EventTypeAnomaly is not a sui event, it is raised by the anomaly detector
+	EventTypeAnomaly = EventType("Anomaly")
+	// EventTypeInactivity is not a sui event, it is raised by the scheduled checks
+	EventTypeInactivity = EventType("Inactivity")
+	// EventTypeQuery is not a sui event, it is raised by the query rules
+	EventTypeQuery = EventType("Query")
+	// EventTypeDigest is not a sui event, it summarizes the alerts of the users and the rules in digest mode
+	EventTypeDigest = EventType("Digest")
 )
 
 type EventType string
@@ -38,10 +48,57 @@ func (e EventType) Description() string {
 		return "Delete object"
 	case EventTypeMutateObject:
 		return "Mutate object"
+	case EventTypeAnomaly:
+		return "Unusual activity"
+	case EventTypeInactivity:
+		return "Expected activity missing"
+	case EventTypeQuery:
+		return "Query result"
+	case EventTypeDigest:
+		return "Summary of alerts"
 	}
 	return "Unknown event"
 }
 
+// Known reports whether e is one of the sui event types rules can watch.
+func (e EventType) Known() bool {
+	switch e {
+	case EventTypeMove, EventTypePublish, EventTypeCoinBalanceChange, EventTypeTransferObject,
+		EventTypeNewObject, EventTypeDeleteObject, EventTypeMutateObject:
+		return true
+	}
+	return false
+}
+
+// NewData returns a new value of the data of the events of type e, nil if unknown.
+func (e EventType) NewData() interface{} {
+	switch e {
+	case EventTypeMove:
+		return &MoveEvent{}
+	case EventTypePublish:
+		return &Publish{}
+	case EventTypeCoinBalanceChange:
+		return &CoinBalanceChange{}
+	case EventTypeTransferObject:
+		return &TransferObject{}
+	case EventTypeNewObject:
+		return &NewObject{}
+	case EventTypeDeleteObject:
+		return &DeleteObject{}
+	case EventTypeMutateObject:
+		return &MutateObject{}
+	case EventTypeAnomaly:
+		return &Anomaly{}
+	case EventTypeInactivity:
+		return &Inactivity{}
+	case EventTypeQuery:
+		return &QueryResult{}
+	case EventTypeDigest:
+		return &Summary{}
+	}
+	return nil
+}
+
 func EventFromSui(e string) EventType {
 	return EventType(strings.ToUpper(e[:1]) + e[1:])
 }
@@ -62,10 +119,97 @@ func (e EventType) Emoji() string {
 		return html.UnescapeString("&#10060;")
 	case EventTypeMutateObject:
 		return html.UnescapeString("&#10071;")
+	case EventTypeAnomaly:
+		return html.UnescapeString("&#128680;")
+	case EventTypeInactivity:
+		return html.UnescapeString("&#9200;")
+	case EventTypeQuery:
+		return html.UnescapeString("&#128202;")
+	case EventTypeDigest:
+		return html.UnescapeString("&#128203;")
 	}
 	return html.UnescapeString("&#10067;")
 }
 
+// Anomaly is an unusual activity of an address compared to its baseline.
+type Anomaly struct {
+	Metric string `json:"metric"`
+	// Coin type of an outflow
+	CoinType string  `json:"coin_type,omitempty"`
+	Value    float64 `json:"value"`
+	Mean     float64 `json:"mean"`
+	StdDev   float64 `json:"std_dev"`
+	ZScore   float64 `json:"z_score"`
+	// Days of history of the baseline
+	Window int `json:"window"`
+}
+
+// Subject names the metric, with the symbol of the coin of an outflow, e.g. "outflow of SUI".
+func (a *Anomaly) Subject() string {
+	if a.CoinType == "" {
+		return a.Metric
+	}
+	return a.Metric + " of " + LookupCoin(a.CoinType).Symbol
+}
+
+// Inactivity is an expected activity of an address which did not happen within a period.
+type Inactivity struct {
+	Check  string        `json:"check"`
+	Name   string        `json:"name,omitempty"`
+	Period time.Duration `json:"period"`
+	// Last time the activity was seen, nil if never
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+}
+
+// QueryResult is the result of a query rule meeting its threshold.
+type QueryResult struct {
+	Name      string  `json:"name,omitempty"`
+	Value     float64 `json:"value"`
+	Op        string  `json:"op"`
+	Threshold float64 `json:"threshold"`
+	// First rows of the result
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// Summary is the data of a digest, the alerts of a period sent at once instead of one by one.
+type Summary struct {
+	// Mode is "hourly" or "daily"
+	Mode  string    `json:"mode"`
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+	Count int       `json:"count"`
+	// Number of alerts of each source, most first
+	Sources []SummarySource `json:"sources"`
+	// Net amount of each coin of the balance changes
+	Coins []SummaryCoin `json:"coins,omitempty"`
+	// Transactions with the most alerts
+	Top []SummaryTx `json:"top,omitempty"`
+}
+
+type SummarySource struct {
+	Source string `json:"source"`
+	Count  int    `json:"count"`
+}
+
+type SummaryCoin struct {
+	CoinType string  `json:"coin_type"`
+	Amount   *BigInt `json:"amount"`
+	// Number of balance changes
+	Count int `json:"count"`
+}
+
+// FormatAmount formats the net amount with the decimals and the symbol of the coin.
+func (c *SummaryCoin) FormatAmount() string {
+	info := LookupCoin(c.CoinType)
+	return FormatAmount(c.Amount.Int(), info.Decimals) + " " + info.Symbol
+}
+
+type SummaryTx struct {
+	TxDigest string `json:"tx_digest"`
+	Count    int    `json:"count"`
+}
+
 type StructTag struct {
 	Address    string `json:"address"`
 	Module     string `json:"module"`
@@ -108,7 +252,49 @@ type CoinBalanceChange struct {
 	CoinType          string       `json:"coinType"`
 	CoinObjectId      string       `json:"coinObjectId"`
 	Version           int64        `json:"version"`
-	Amount            int64        `json:"amount"`
+	Amount            *BigInt      `json:"amount"`
+}
+
+// CompareAmount compares the amount with value, a decimal number in units of the coin.
+// It returns -1, 0 or +1, e.g. `Event.CompareAmount("1.5") > 0` matches more than 1.5 SUI.
+// It panics if value is invalid, the rule engine reports the panic as an error of the rule.
+func (c *CoinBalanceChange) CompareAmount(value string) int {
+	n, err := c.Cmp(value)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// Cmp compares the amount with value in units of the coin, see CompareAmount.
+func (c *CoinBalanceChange) Cmp(value string) (int, error) {
+	x, err := ParseAmount(value)
+	if err != nil {
+		return 0, err
+	}
+	return c.Value().Cmp(x), nil
+}
+
+// Value returns the amount in units of the coin.
+func (c *CoinBalanceChange) Value() *big.Rat {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(LookupCoin(c.CoinType).Decimals)), nil)
+	return new(big.Rat).SetFrac(c.Amount.Int(), scale)
+}
+
+// AmountGreaterThan reports whether the amount is greater than value in units of the coin.
+func (c *CoinBalanceChange) AmountGreaterThan(value string) bool {
+	return c.CompareAmount(value) == 1
+}
+
+// AmountLessThan reports whether the amount is less than value in units of the coin.
+func (c *CoinBalanceChange) AmountLessThan(value string) bool {
+	return c.CompareAmount(value) == -1
+}
+
+// FormatAmount formats the amount with the decimals and the symbol of the coin, e.g. "1.5 SUI".
+func (c *CoinBalanceChange) FormatAmount() string {
+	info := LookupCoin(c.CoinType)
+	return FormatAmount(c.Amount.Int(), info.Decimals) + " " + info.Symbol
 }
 
 type EventResult struct {
@@ -124,6 +124,21 @@ func BytesToAddress(b []byte) Address {
 // If s is larger than len(h), s will be cropped from the left.
 func HexToAddress(s string) Address { return BytesToAddress(FromHex(s)) }
 
+// IsHexAddress verifies whether a string can represent a valid hex-encoded address.
+func IsHexAddress(s string) bool {
+	if has0xPrefix(s) {
+		s = s[2:]
+	}
+	if len(s) == 0 || len(s) > 2*AddressLength {
+		return false
+	}
+	if len(s)%2 == 1 {
+		s = "0" + s
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
 // String implements fmt.Stringer.
 func (a Address) String() string {
 	return a.Hex()